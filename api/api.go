@@ -5,6 +5,7 @@ import (
 
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -23,6 +24,15 @@ type Config struct {
 	Authenticate         bool
 	ListenURL            string
 	MapboxAPIKey         string
+
+	// MapCenterLatitude, MapCenterLongitude, and MapZoom are where the map is shown when it loads.
+	MapCenterLatitude  float64
+	MapCenterLongitude float64
+	MapZoom            float64
+
+	// DisplayTimezone is the IANA name of the time zone that Location times are served in. Empty
+	// means UTC, which is how they are stored.
+	DisplayTimezone string
 }
 
 // API is responsible for configuring handlers for HTTP endpoints.
@@ -32,11 +42,22 @@ type API struct {
 	ms      shuttletracker.ModelService
 	msg     shuttletracker.MessageService
 	updater *updater.Updater
+
+	displayLocation *time.Location
 }
 
 // New initializes the application given a config and connects to backends.
 // It also seeds any needed information to the database.
 func New(cfg Config, ms shuttletracker.ModelService, msg shuttletracker.MessageService, us shuttletracker.UserService, updater *updater.Updater) (*API, error) {
+	err := validateMapConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	displayLocation, err := time.LoadLocation(cfg.DisplayTimezone)
+	if err != nil {
+		return nil, err
+	}
+
 	// Set up CAS authentication
 	url, err := url.Parse(cfg.CasURL)
 	if err != nil {
@@ -48,6 +69,8 @@ func New(cfg Config, ms shuttletracker.ModelService, msg shuttletracker.MessageS
 		ms:      ms,
 		msg:     msg,
 		updater: updater,
+
+		displayLocation: displayLocation,
 	}
 
 	r := chi.NewRouter()
@@ -103,6 +126,9 @@ func New(cfg Config, ms shuttletracker.ModelService, msg shuttletracker.MessageS
 		})
 	})
 
+	// Map
+	r.Get("/map", api.MapConfigHandler)
+
 	r.Get("/logout/", cli.logout)
 	// Admin
 	r.Route("/admin", func(r chi.Router) {
@@ -132,12 +158,19 @@ func New(cfg Config, ms shuttletracker.ModelService, msg shuttletracker.MessageS
 
 func NewConfig(v *viper.Viper) *Config {
 	cfg := &Config{
-		ListenURL:    "0.0.0.0:8080",
-		Authenticate: true,
+		ListenURL:          "0.0.0.0:8080",
+		Authenticate:       true,
+		MapCenterLatitude:  42.728172,
+		MapCenterLongitude: -73.678803,
+		MapZoom:            15.3,
 	}
 	v.SetDefault("api.listenurl", cfg.ListenURL)
 	v.SetDefault("api.casurl", cfg.CasURL)
 	v.SetDefault("api.authenticate", cfg.Authenticate)
+	v.SetDefault("api.mapcenterlatitude", cfg.MapCenterLatitude)
+	v.SetDefault("api.mapcenterlongitude", cfg.MapCenterLongitude)
+	v.SetDefault("api.mapzoom", cfg.MapZoom)
+	v.SetDefault("api.displaytimezone", cfg.DisplayTimezone)
 	return cfg
 }
 
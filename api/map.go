@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// maxMapZoom is the highest zoom level that map tiles are available at.
+const maxMapZoom = 22
+
+// MapConfig is where the map is shown when it loads.
+type MapConfig struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Zoom      float64 `json:"zoom"`
+}
+
+// validateMapConfig checks that the map center is a valid coordinate and the zoom is a valid level.
+func validateMapConfig(cfg Config) error {
+	if cfg.MapCenterLatitude < -90 || cfg.MapCenterLatitude > 90 {
+		return fmt.Errorf("map center latitude must be between -90 and 90, got %v", cfg.MapCenterLatitude)
+	}
+	if cfg.MapCenterLongitude < -180 || cfg.MapCenterLongitude > 180 {
+		return fmt.Errorf("map center longitude must be between -180 and 180, got %v", cfg.MapCenterLongitude)
+	}
+	if cfg.MapZoom < 0 || cfg.MapZoom > maxMapZoom {
+		return fmt.Errorf("map zoom must be between 0 and %d, got %v", maxMapZoom, cfg.MapZoom)
+	}
+	return nil
+}
+
+// MapConfig returns where the map is shown when it loads.
+func (api *API) MapConfig() MapConfig {
+	return MapConfig{
+		Latitude:  api.cfg.MapCenterLatitude,
+		Longitude: api.cfg.MapCenterLongitude,
+		Zoom:      api.cfg.MapZoom,
+	}
+}
+
+// MapConfigHandler sends where the map is shown when it loads so that the frontend doesn't hardcode it.
+func (api *API) MapConfigHandler(w http.ResponseWriter, r *http.Request) {
+	err := WriteJSON(w, api.MapConfig())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestMapConfigHandler(t *testing.T) {
+	cfg := Config{MapCenterLatitude: 42.728172, MapCenterLongitude: -73.678803, MapZoom: 15.3}
+	api, err := New(cfg, &mock.ModelService{}, &mock.MessageService{}, &mock.UserService{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	req, err := http.NewRequest("GET", "/map", nil)
+	if err != nil {
+		t.Fatalf("unable to create HTTP request: %s", err)
+	}
+	w := httptest.NewRecorder()
+	api.handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status code %d, expected 200", w.Code)
+	}
+	var mapConfig MapConfig
+	err = json.NewDecoder(w.Body).Decode(&mapConfig)
+	if err != nil {
+		t.Fatalf("unable to decode response: %s", err)
+	}
+	expected := MapConfig{Latitude: 42.728172, Longitude: -73.678803, Zoom: 15.3}
+	if mapConfig != expected {
+		t.Errorf("got %+v, expected %+v", mapConfig, expected)
+	}
+}
+
+func TestMapConfigValidation(t *testing.T) {
+	cfgs := []Config{
+		{MapCenterLatitude: 91},
+		{MapCenterLongitude: -181},
+		{MapZoom: -1},
+		{MapZoom: 23},
+	}
+	for _, cfg := range cfgs {
+		_, err := New(cfg, &mock.ModelService{}, &mock.MessageService{}, &mock.UserService{}, nil)
+		if err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// InDisplayTime returns a copy of location with its times in the configured display time zone. The
+// stored Location is left in UTC.
+func (api *API) InDisplayTime(location *shuttletracker.Location) *shuttletracker.Location {
+	loc := api.displayLocation
+	if loc == nil {
+		loc = time.UTC
+	}
+	served := *location
+	served.Time = location.Time.In(loc)
+	served.Created = location.Created.In(loc)
+	return &served
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestInDisplayTime(t *testing.T) {
+	api, err := New(Config{DisplayTimezone: "America/New_York"}, &mock.ModelService{}, &mock.MessageService{}, &mock.UserService{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	stored := time.Date(2018, time.March, 1, 17, 30, 0, 0, time.UTC)
+	location := &shuttletracker.Location{ID: 1, Time: stored, Created: stored}
+	served := api.InDisplayTime(location)
+	if !served.Time.Equal(stored) || !served.Created.Equal(stored) {
+		t.Errorf("got times %s and %s, expected the same instant as %s", served.Time, served.Created, stored)
+	}
+	if served.Time.Hour() != 12 || served.Time.Location().String() != "America/New_York" {
+		t.Errorf("got %s, expected 12:30 in America/New_York", served.Time)
+	}
+	if location.Time.Location() != time.UTC {
+		t.Errorf("stored Location's time was changed to %s", location.Time)
+	}
+
+	// without a display time zone, times are served in UTC
+	api = &API{}
+	served = api.InDisplayTime(&shuttletracker.Location{Time: stored.In(time.FixedZone("EST", -5*60*60))})
+	if served.Time.Location() != time.UTC {
+		t.Errorf("got %s, expected UTC", served.Time)
+	}
+}
+
+func TestDisplayTimezoneValidation(t *testing.T) {
+	_, err := New(Config{DisplayTimezone: "Not/AZone"}, &mock.ModelService{}, &mock.MessageService{}, &mock.UserService{}, nil)
+	if err == nil {
+		t.Error("expected an error for an unknown time zone")
+	}
+}
@@ -14,6 +14,18 @@ var (
 	lastUpdate time.Time
 )
 
+// publicVehicle is a Vehicle along with the name that riders should see.
+type publicVehicle struct {
+	*shuttletracker.Vehicle
+	PublicName string `json:"public_name"`
+}
+
+// publicUpdate is a vehicle's Location along with the name that riders should see for the vehicle.
+type publicUpdate struct {
+	*shuttletracker.Location
+	VehicleName string `json:"vehicle_name"`
+}
+
 // VehiclesHandler returns all the vehicles.
 func (api *API) VehiclesHandler(w http.ResponseWriter, r *http.Request) {
 	vehicles, err := api.ms.Vehicles()
@@ -21,7 +33,11 @@ func (api *API) VehiclesHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	WriteJSON(w, vehicles)
+	public := make([]publicVehicle, 0, len(vehicles))
+	for _, vehicle := range vehicles {
+		public = append(public, publicVehicle{Vehicle: vehicle, PublicName: vehicle.PublicName()})
+	}
+	WriteJSON(w, public)
 }
 
 // VehiclesCreateHandler adds a new vehicle.
@@ -40,17 +56,27 @@ func (api *API) VehiclesCreateHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *API) VehiclesEditHandler(w http.ResponseWriter, r *http.Request) {
-	vehicle := &shuttletracker.Vehicle{}
-	err := json.NewDecoder(r.Body).Decode(vehicle)
+	// display_name is decoded separately so that a request without it can be told apart from one
+	// that clears it.
+	request := struct {
+		shuttletracker.Vehicle
+		DisplayName *string `json:"display_name"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		log.WithError(err).Error("unable to decode vehicle")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	vehicle := &request.Vehicle
 
 	name := vehicle.Name
+	displayName := request.DisplayName
 	enabled := vehicle.Enabled
 	trackerID := vehicle.TrackerID
+	trackerIDs := vehicle.TrackerIDs
+	schedule := vehicle.Schedule
+	tags := vehicle.Tags
 	vehicle, err = api.ms.Vehicle(vehicle.ID)
 	if err != nil {
 		log.WithError(err).Error("unable to retrieve vehicle")
@@ -58,9 +84,30 @@ func (api *API) VehiclesEditHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Clients that only know about the primary tracker ID shouldn't drop the vehicle's other trackers.
+	if trackerIDs == nil {
+		trackerIDs = []string{}
+		for _, id := range vehicle.TrackerIDs {
+			if id != vehicle.TrackerID {
+				trackerIDs = append(trackerIDs, id)
+			}
+		}
+	}
+
 	vehicle.Name = name
 	vehicle.Enabled = enabled
 	vehicle.TrackerID = trackerID
+	vehicle.TrackerIDs = trackerIDs
+	// Likewise, clients that don't send a schedule keep the existing one.
+	if schedule != nil {
+		vehicle.Schedule = schedule
+	}
+	if tags != nil {
+		vehicle.Tags = tags
+	}
+	if displayName != nil {
+		vehicle.DisplayName = *displayName
+	}
 
 	err = api.ms.ModifyVehicle(vehicle)
 	if err != nil {
@@ -88,17 +135,19 @@ func (api *API) VehiclesDeleteHandler(w http.ResponseWriter, r *http.Request) {
 
 // UpdatesHandler gets the most recent update for each enabled vehicle.
 func (api *API) UpdatesHandler(w http.ResponseWriter, r *http.Request) {
-	vehicles, err := api.ms.EnabledVehicles()
+	// Vehicles that haven't reported recently aren't shown at their last-known position.
+	window := api.updater.LocationFreshness()
+	vehicles, err := api.ms.RecentlyActiveVehicles(window)
 	if err != nil {
-		log.WithError(err).Error("Unable to get enabled vehicles.")
+		log.WithError(err).Error("Unable to get recently active vehicles.")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// slice of capacity len(vehicles) and size zero
-	updates := make([]*shuttletracker.Location, 0, len(vehicles))
+	updates := make([]publicUpdate, 0, len(vehicles))
 	for _, vehicle := range vehicles {
-		since := time.Now().Add(time.Minute * -5)
+		since := time.Now().Add(-window)
 		vehicleUpdates, err := api.ms.LocationsSince(vehicle.ID, since)
 		if err != nil {
 			log.WithError(err).Error("Unable to get last vehicle update.")
@@ -108,7 +157,7 @@ func (api *API) UpdatesHandler(w http.ResponseWriter, r *http.Request) {
 
 		// if there is an update since the time, append it to all updates
 		if len(vehicleUpdates) > 0 {
-			updates = append(updates, vehicleUpdates[0])
+			updates = append(updates, publicUpdate{Location: api.InDisplayTime(vehicleUpdates[0]), VehicleName: vehicle.PublicName()})
 		}
 	}
 
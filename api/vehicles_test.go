@@ -12,6 +12,7 @@ import (
 
 	"github.com/wtg/shuttletracker"
 	"github.com/wtg/shuttletracker/mock"
+	"github.com/wtg/shuttletracker/updater"
 )
 
 func TestVehiclesHandlerNoVehicles(t *testing.T) {
@@ -59,7 +60,7 @@ func TestVehiclesHandlerNoVehicles(t *testing.T) {
 func vehiclesEqual(first, second *shuttletracker.Vehicle) bool {
 	// ensure that we are comparing all of the fields
 	val := reflect.ValueOf(*first)
-	if val.NumField() != 6 {
+	if val.NumField() != 10 {
 		return false
 	}
 
@@ -68,6 +69,8 @@ func vehiclesEqual(first, second *shuttletracker.Vehicle) bool {
 		return false
 	} else if first.Name != second.Name {
 		return false
+	} else if first.DisplayName != second.DisplayName {
+		return false
 	} else if !first.Created.Equal(second.Created) {
 		return false
 	} else if !first.Updated.Equal(second.Updated) {
@@ -76,6 +79,12 @@ func vehiclesEqual(first, second *shuttletracker.Vehicle) bool {
 		return false
 	} else if first.TrackerID != second.TrackerID {
 		return false
+	} else if !reflect.DeepEqual(first.Tags, second.Tags) {
+		return false
+	} else if !reflect.DeepEqual(first.TrackerIDs, second.TrackerIDs) {
+		return false
+	} else if !reflect.DeepEqual(first.Schedule, second.Schedule) {
+		return false
 	}
 
 	return true
@@ -192,18 +201,20 @@ func TestVehiclesEditHandler(t *testing.T) {
 	ms := &mock.ModelService{}
 	vehicleTime := time.Now()
 	existingVehicle := &shuttletracker.Vehicle{
-		ID:        4,
-		Name:      "Vehicle 2",
-		Enabled:   true,
-		TrackerID: "2",
-		Created:   vehicleTime,
+		ID:         4,
+		Name:       "Vehicle 2",
+		Enabled:    true,
+		TrackerID:  "2",
+		TrackerIDs: []string{"2", "backup"},
+		Created:    vehicleTime,
 	}
 	changedVehicle := &shuttletracker.Vehicle{
-		ID:        4,
-		Name:      "Vehicle 2 changed",
-		Enabled:   false,
-		TrackerID: "3",
-		Created:   vehicleTime,
+		ID:          4,
+		Name:        "Vehicle 2 changed",
+		DisplayName: "Shuttle 2",
+		Enabled:     false,
+		TrackerID:   "3",
+		Created:     vehicleTime,
 	}
 	ms.VehicleService.On("Vehicle", int64(4)).Return(existingVehicle, nil)
 
@@ -267,9 +278,15 @@ func TestVehiclesEditHandler(t *testing.T) {
 			if argVehicle.Name != changedVehicle.Name {
 				t.Error("got unexpected vehicle.Name value")
 			}
+			if argVehicle.DisplayName != changedVehicle.DisplayName {
+				t.Error("got unexpected vehicle.DisplayName value")
+			}
 			if argVehicle.TrackerID != changedVehicle.TrackerID {
 				t.Error("got unexpected vehicle.TrackerID value")
 			}
+			if !reflect.DeepEqual(argVehicle.TrackerIDs, []string{"backup"}) {
+				t.Errorf("got unexpected vehicle.TrackerIDs value %v", argVehicle.TrackerIDs)
+			}
 			if argVehicle.ID != changedVehicle.ID {
 				t.Error("got unexpected vehicle.ID value")
 			}
@@ -281,6 +298,76 @@ func TestVehiclesEditHandler(t *testing.T) {
 	}
 }
 
+func TestVehiclesEditHandlerKeepsDisplayName(t *testing.T) {
+	ms := &mock.ModelService{}
+	existingVehicle := &shuttletracker.Vehicle{ID: 4, Name: "Vehicle 2", DisplayName: "Shuttle 2", TrackerID: "2"}
+	ms.VehicleService.On("Vehicle", int64(4)).Return(existingVehicle, nil)
+	ms.VehicleService.On("ModifyVehicle", "mock.Anything").Return(nil)
+	api := API{
+		ms: ms,
+	}
+
+	// like the admin UI, the request doesn't include display_name
+	body := bytes.NewBufferString(`{"id": 4, "name": "Vehicle 2 changed", "enabled": true, "tracker_id": "2"}`)
+	req, err := http.NewRequest("POST", "", body)
+	if err != nil {
+		t.Fatalf("unable to create HTTP request: %s", err)
+	}
+	w := httptest.NewRecorder()
+	api.VehiclesEditHandler(w, req)
+	if w.Code != 200 {
+		t.Errorf("got status code %d, expected 200", w.Code)
+	}
+
+	ms.VehicleService.AssertNumberOfCalls(t, "ModifyVehicle", 1)
+	for _, call := range ms.VehicleService.Calls {
+		if call.Method != "ModifyVehicle" {
+			continue
+		}
+		argVehicle := call.Arguments[0].(*shuttletracker.Vehicle)
+		if argVehicle.Name != "Vehicle 2 changed" || argVehicle.DisplayName != "Shuttle 2" {
+			t.Errorf("got name %q and display name %q, expected the display name to be kept", argVehicle.Name, argVehicle.DisplayName)
+		}
+	}
+}
+
+func TestVehiclesHandlerPublicName(t *testing.T) {
+	ms := &mock.ModelService{}
+	vehicles := []*shuttletracker.Vehicle{
+		{ID: 1, Name: "Vehicle 1", DisplayName: "Shuttle 1"},
+		{ID: 2, Name: "Vehicle 2"},
+	}
+	ms.VehicleService.On("Vehicles").Return(vehicles, nil)
+	api := API{
+		ms: ms,
+	}
+
+	req, err := http.NewRequest("GET", "", nil)
+	if err != nil {
+		t.Fatalf("unable to create HTTP request: %s", err)
+	}
+	w := httptest.NewRecorder()
+	api.VehiclesHandler(w, req)
+
+	returned := []struct {
+		Name       string `json:"name"`
+		PublicName string `json:"public_name"`
+	}{}
+	err = json.NewDecoder(w.Result().Body).Decode(&returned)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"Shuttle 1", "Vehicle 2"}
+	if len(returned) != len(expected) {
+		t.Fatalf("got %d vehicles, expected %d", len(returned), len(expected))
+	}
+	for i, name := range expected {
+		if returned[i].PublicName != name || returned[i].Name != vehicles[i].Name {
+			t.Errorf("got %+v, expected public name %q", returned[i], name)
+		}
+	}
+}
+
 func TestVehiclesDeleteHandler(t *testing.T) {
 	ms := &mock.ModelService{}
 	vehicleID := int64(7)
@@ -316,3 +403,46 @@ func TestVehiclesDeleteHandler(t *testing.T) {
 	ms.VehicleService.AssertExpectations(t)
 	ms.VehicleService.AssertNumberOfCalls(t, "DeleteVehicle", 1)
 }
+
+func TestUpdatesHandlerOmitsStaleVehicles(t *testing.T) {
+	ms := &mock.ModelService{}
+	u, err := updater.New(updater.Config{UpdateInterval: "10s", LocationFreshness: "10m"}, ms)
+	if err != nil {
+		t.Fatalf("unable to create Updater: %s", err)
+	}
+	// the stale vehicle's latest Location is older than the configured freshness window, so it isn't
+	// recently active
+	fresh := &shuttletracker.Vehicle{ID: 1, Name: "Vehicle 1", Enabled: true}
+	ms.VehicleService.On("RecentlyActiveVehicles", time.Minute*10).Return([]*shuttletracker.Vehicle{fresh}, nil)
+	ms.LocationService.On("LocationsSince", int64(1)).Return([]*shuttletracker.Location{
+		{ID: 5, TrackerID: "1", Time: time.Now().Add(-time.Minute)},
+	}, nil)
+	api := API{
+		ms:      ms,
+		updater: u,
+	}
+
+	req, err := http.NewRequest("GET", "", nil)
+	if err != nil {
+		t.Fatalf("unable to create HTTP request: %s", err)
+	}
+	w := httptest.NewRecorder()
+	api.UpdatesHandler(w, req)
+	if w.Code != 200 {
+		t.Fatalf("got status code %d, expected 200", w.Code)
+	}
+
+	var updates []struct {
+		ID          int64  `json:"id"`
+		VehicleName string `json:"vehicle_name"`
+	}
+	err = json.NewDecoder(w.Body).Decode(&updates)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(updates) != 1 || updates[0].ID != 5 || updates[0].VehicleName != "Vehicle 1" {
+		t.Errorf("got updates %+v, expected only the fresh vehicle's update", updates)
+	}
+	ms.VehicleService.AssertExpectations(t)
+	ms.LocationService.AssertNumberOfCalls(t, "LocationsSince", 1)
+}
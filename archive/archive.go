@@ -0,0 +1,177 @@
+// Package archive exports vehicle Locations in a compact binary format for bulk archival.
+//
+// An archive starts with a header:
+//
+//	magic     4 bytes  "STLA"
+//	version   1 byte   currently 1
+//	vehicle   varint   ID of the Vehicle the Locations belong to
+//	count     uvarint  number of Locations that follow
+//
+// followed by count records, each of which is:
+//
+//	time      varint   microseconds since the previous record's time, or since the Unix epoch for the first record
+//	latitude  varint   change in latitude from the previous record, in units of 1e-7 degrees
+//	longitude varint   change in longitude from the previous record, in units of 1e-7 degrees
+//	heading   varint   heading in units of 0.01 degrees
+//	speed     varint   speed in units of 0.01 miles per hour
+//	route     uvarint  ID of the Route the Location was on, or 0 if it wasn't on one
+//
+// Integers use the varint encodings of Go's encoding/binary package: uvarints are unsigned base 128
+// integers, least significant group first, and varints are uvarints of the zigzag encoding of signed
+// integers. Coordinates are rounded to their units before the differences are taken, so decoding
+// doesn't accumulate rounding error.
+package archive
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// Version is the format version written by ExportLocationsBinary.
+const Version = 1
+
+const (
+	magic           = "STLA"
+	coordinateScale = 1e7
+	valueScale      = 100
+)
+
+// ErrInvalidArchive indicates that data does not start with an archive header.
+var ErrInvalidArchive = errors.New("not a location archive")
+
+// Service exports Locations as archives.
+type Service struct {
+	ms shuttletracker.ModelService
+}
+
+// New creates a Service backed by ms.
+func New(ms shuttletracker.ModelService) *Service {
+	return &Service{ms: ms}
+}
+
+// quantize rounds value to the nearest multiple of 1/scale and returns the number of multiples.
+func quantize(value, scale float64) int64 {
+	return int64(math.Floor(value*scale + 0.5))
+}
+
+// ExportLocationsBinary writes an archive to w of the Vehicle's Locations with tracker Times in
+// [start, end), in order.
+func (s *Service) ExportLocationsBinary(w io.Writer, vehicleID int64, start, end time.Time) error {
+	locations, err := s.ms.LocationsBetween(vehicleID, start, end, shuttletracker.Collapse{})
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, binary.MaxVarintLen64)
+	putVarint := func(v int64) {
+		n := binary.PutVarint(buf, v)
+		bw.Write(buf[:n]) // nolint: errcheck
+	}
+	putUvarint := func(v uint64) {
+		n := binary.PutUvarint(buf, v)
+		bw.Write(buf[:n]) // nolint: errcheck
+	}
+
+	// bufio.Writer remembers the first error, which Flush returns
+	bw.WriteString(magic) // nolint: errcheck
+	bw.WriteByte(Version) // nolint: errcheck
+	putVarint(vehicleID)
+	putUvarint(uint64(len(locations)))
+
+	var lastTime, lastLat, lastLng int64
+	for _, l := range locations {
+		t := l.Time.UnixNano() / int64(time.Microsecond)
+		lat := quantize(l.Latitude, coordinateScale)
+		lng := quantize(l.Longitude, coordinateScale)
+		putVarint(t - lastTime)
+		putVarint(lat - lastLat)
+		putVarint(lng - lastLng)
+		putVarint(quantize(l.Heading, valueScale))
+		putVarint(quantize(l.Speed, valueScale))
+		var routeID uint64
+		if l.RouteID != nil {
+			routeID = uint64(*l.RouteID)
+		}
+		putUvarint(routeID)
+		lastTime, lastLat, lastLng = t, lat, lng
+	}
+	return bw.Flush()
+}
+
+// Decode reads an archive written by ExportLocationsBinary and returns the Vehicle ID and Locations
+// in it. ErrInvalidArchive is returned if r doesn't contain an archive, and an error is returned for
+// versions that this package can't read.
+func Decode(r io.Reader) (int64, []*shuttletracker.Location, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return 0, nil, ErrInvalidArchive
+	}
+	if string(header[:len(magic)]) != magic {
+		return 0, nil, ErrInvalidArchive
+	}
+	if header[len(magic)] != Version {
+		return 0, nil, fmt.Errorf("unsupported location archive version %d", header[len(magic)])
+	}
+
+	vehicleID, err := binary.ReadVarint(br)
+	if err != nil {
+		return 0, nil, err
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	locations := []*shuttletracker.Location{}
+	var t, lat, lng int64
+	for i := uint64(0); i < count; i++ {
+		values := make([]int64, 5)
+		for j := range values {
+			values[j], err = binary.ReadVarint(br)
+			if err != nil {
+				return 0, nil, unexpectedEOF(err)
+			}
+		}
+		var routeID uint64
+		routeID, err = binary.ReadUvarint(br)
+		if err != nil {
+			return 0, nil, unexpectedEOF(err)
+		}
+		t += values[0]
+		lat += values[1]
+		lng += values[2]
+
+		vid := vehicleID
+		l := &shuttletracker.Location{
+			VehicleID: &vid,
+			Time:      time.Unix(0, t*int64(time.Microsecond)),
+			Latitude:  float64(lat) / coordinateScale,
+			Longitude: float64(lng) / coordinateScale,
+			Heading:   float64(values[3]) / valueScale,
+			Speed:     float64(values[4]) / valueScale,
+		}
+		if routeID != 0 {
+			id := int64(routeID)
+			l.RouteID = &id
+		}
+		locations = append(locations, l)
+	}
+	return vehicleID, locations, nil
+}
+
+// unexpectedEOF reports a truncated archive as such rather than as a clean end of input.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+// nolint: gocyclo
+func TestExportLocationsBinary(t *testing.T) {
+	routeID := int64(3)
+	start := time.Date(2018, time.April, 16, 8, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	locations := []*shuttletracker.Location{
+		{Latitude: 42.7294312, Longitude: -73.6754321, Heading: 92.5, Speed: 12.25, Time: start.Add(time.Microsecond * 1500)},
+		{Latitude: 42.7295, Longitude: -73.6753, Heading: 90, Speed: 0, Time: start.Add(time.Second * 5), RouteID: &routeID},
+		{Latitude: 42.7291, Longitude: -73.6759, Heading: 270, Speed: 30.5, Time: start.Add(time.Second * 10)},
+	}
+	ms := &mock.ModelService{}
+	ms.LocationService.On("LocationsBetween", int64(7), start, end, shuttletracker.Collapse{}).Return(locations, nil)
+
+	buf := &bytes.Buffer{}
+	err := New(ms).ExportLocationsBinary(buf, 7, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if buf.Len() > len(locations)*20 {
+		t.Errorf("archive is %d bytes, expected it to be compact", buf.Len())
+	}
+
+	vehicleID, decoded, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if vehicleID != 7 {
+		t.Errorf("got vehicle ID %d, expected 7", vehicleID)
+	}
+	if len(decoded) != len(locations) {
+		t.Fatalf("got %d Locations, expected %d", len(decoded), len(locations))
+	}
+	for i, l := range decoded {
+		e := locations[i]
+		if math.Abs(l.Latitude-e.Latitude) > 1e-7 || math.Abs(l.Longitude-e.Longitude) > 1e-7 {
+			t.Errorf("got position %f, %f for Location %d, expected %f, %f", l.Latitude, l.Longitude, i, e.Latitude, e.Longitude)
+		}
+		if l.Heading != e.Heading || l.Speed != e.Speed {
+			t.Errorf("got heading %f and speed %f for Location %d, expected %f and %f", l.Heading, l.Speed, i, e.Heading, e.Speed)
+		}
+		if !l.Time.Equal(e.Time) {
+			t.Errorf("got time %s for Location %d, expected %s", l.Time, i, e.Time)
+		}
+		if (l.RouteID == nil) != (e.RouteID == nil) || (l.RouteID != nil && *l.RouteID != *e.RouteID) {
+			t.Errorf("got route %v for Location %d, expected %v", l.RouteID, i, e.RouteID)
+		}
+		if l.VehicleID == nil || *l.VehicleID != 7 {
+			t.Errorf("got vehicle %v for Location %d, expected 7", l.VehicleID, i)
+		}
+	}
+
+	// truncating the archive is reported
+	_, _, err = Decode(bytes.NewReader(buf.Bytes()[:buf.Len()-2]))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("got error %v for a truncated archive, expected io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, _, err := Decode(bytes.NewReader([]byte("not an archive")))
+	if err != ErrInvalidArchive {
+		t.Errorf("got error %v, expected ErrInvalidArchive", err)
+	}
+	_, _, err = Decode(bytes.NewReader(nil))
+	if err != ErrInvalidArchive {
+		t.Errorf("got error %v, expected ErrInvalidArchive", err)
+	}
+	_, _, err = Decode(bytes.NewReader([]byte(magic + "\x02\x00\x00")))
+	if err == nil || err == ErrInvalidArchive {
+		t.Errorf("got error %v, expected an unsupported version", err)
+	}
+}
@@ -56,8 +56,9 @@ var rootCmd = &cobra.Command{
 		}
 		runner.Add(updater)
 
-		// Make API server
-		api, err := api.New(*cfg.API, ms, msg, us, updater)
+		// Make API server. Its reads may come from a replica, but the updater's must not since it
+		// stores data derived from them.
+		api, err := api.New(*cfg.API, pg.ReadReplica(), msg, us, updater)
 		if err != nil {
 			log.WithError(err).Error("Could not create API server.")
 			return
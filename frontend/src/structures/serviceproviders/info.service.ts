@@ -13,12 +13,13 @@ export default class InfoServiceProvider {
             data.forEach((element: {
                 id: number,
                 name: string,
+                public_name: string,
                 created: string,
                 updated: string,
                 enabled: boolean,
                 tracker_id: string,
             }) => {
-                ret.push(new Vehicle(element.id, element.name,
+                ret.push(new Vehicle(element.id, element.public_name || element.name,
                     new Date(element.created), new Date(element.updated), element.enabled));
             });
             return ret;
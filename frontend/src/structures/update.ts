@@ -10,4 +10,5 @@ export default interface Update {
     created: string;
     route_id: number | null;
     vehicle_id: number;
+    vehicle_name: string;
 }
@@ -0,0 +1,164 @@
+// Package geojson converts between GeoJSON documents and shuttletracker's models.
+package geojson
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// Service imports and exports shuttletracker's models as GeoJSON.
+type Service struct {
+	ms shuttletracker.ModelService
+}
+
+// New creates a Service backed by ms.
+func New(ms shuttletracker.ModelService) *Service {
+	return &Service{ms: ms}
+}
+
+type feature struct {
+	Type       string          `json:"type"`
+	Geometry   geometry        `json:"geometry"`
+	Properties routeProperties `json:"properties"`
+}
+
+type geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+type routeProperties struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Color   string `json:"color"`
+	Width   int64  `json:"width"`
+}
+
+// ImportRouteGeoJSON reads a GeoJSON Feature with a LineString geometry from r and creates a Route
+// from it. The Route's name and enabled flag are taken from the Feature's properties, and its
+// Points follow the order of the LineString's coordinates.
+func (s *Service) ImportRouteGeoJSON(r io.Reader) (*shuttletracker.Route, error) {
+	f := feature{}
+	err := json.NewDecoder(r).Decode(&f)
+	if err != nil {
+		return nil, err
+	}
+	if f.Type != "Feature" {
+		return nil, fmt.Errorf("expected a GeoJSON Feature, got \"%s\"", f.Type)
+	}
+	if f.Geometry.Type != "LineString" {
+		return nil, fmt.Errorf("expected a LineString geometry, got \"%s\"", f.Geometry.Type)
+	}
+	if f.Properties.Name == "" {
+		return nil, errors.New("route name is required")
+	}
+
+	// GeoJSON positions are [longitude, latitude], optionally followed by an elevation.
+	coordinates := [][]float64{}
+	err = json.Unmarshal(f.Geometry.Coordinates, &coordinates)
+	if err != nil {
+		return nil, err
+	}
+	if len(coordinates) < 2 {
+		return nil, errors.New("a LineString must have at least two positions")
+	}
+	points := make([]shuttletracker.Point, 0, len(coordinates))
+	for i, position := range coordinates {
+		if len(position) < 2 {
+			return nil, fmt.Errorf("position %d has fewer than two coordinates", i)
+		}
+		points = append(points, shuttletracker.Point{Latitude: position[1], Longitude: position[0]})
+	}
+
+	route := &shuttletracker.Route{
+		Name:     f.Properties.Name,
+		Enabled:  f.Properties.Enabled,
+		Color:    f.Properties.Color,
+		Width:    f.Properties.Width,
+		Points:   points,
+		StopIDs:  []int64{},
+		Schedule: shuttletracker.RouteSchedule{},
+	}
+	if route.Color == "" {
+		route.Color = "#ffffff"
+	}
+	if route.Width == 0 {
+		route.Width = 4
+	}
+
+	err = s.ms.CreateRoute(route)
+	if err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+type locationFeature struct {
+	Type       string             `json:"type"`
+	Geometry   pointGeometry      `json:"geometry"`
+	Properties locationProperties `json:"properties"`
+}
+
+type pointGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type locationProperties struct {
+	Time    time.Time `json:"time"`
+	Speed   float64   `json:"speed"`
+	Heading float64   `json:"heading"`
+	RouteID *int64    `json:"route_id"`
+}
+
+// LocationsGeoJSON writes a vehicle's path between start and end to w as a GeoJSON FeatureCollection
+// with a Point Feature for each Location, oldest first. Each Feature's properties hold the Location's
+// time, speed, heading, and route ID. Features are written as they are encoded, and a vehicle without
+// any Locations in the window results in a FeatureCollection without any Features.
+func (s *Service) LocationsGeoJSON(w io.Writer, vehicleID int64, start, end time.Time) error {
+	locations, err := s.ms.LocationsBetween(vehicleID, start, end, shuttletracker.Collapse{})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, `{"type":"FeatureCollection","features":[`)
+	if err != nil {
+		return err
+	}
+	for i, location := range locations {
+		if i > 0 {
+			_, err = io.WriteString(w, ",")
+			if err != nil {
+				return err
+			}
+		}
+		f := locationFeature{
+			Type: "Feature",
+			Geometry: pointGeometry{
+				Type:        "Point",
+				Coordinates: [2]float64{location.Longitude, location.Latitude},
+			},
+			Properties: locationProperties{
+				Time:    location.Time,
+				Speed:   location.Speed,
+				Heading: location.Heading,
+				RouteID: location.RouteID,
+			},
+		}
+		b, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, "]}")
+	return err
+}
@@ -0,0 +1,112 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/wtg/shuttletracker"
+	stmock "github.com/wtg/shuttletracker/mock"
+)
+
+func TestImportRouteGeoJSON(t *testing.T) {
+	ms := &stmock.ModelService{}
+	ms.RouteService.On("CreateRoute", mock.AnythingOfType("*shuttletracker.Route")).Return(nil)
+	s := New(ms)
+
+	doc := `{
+	"type": "Feature",
+	"properties": {"name": "West", "enabled": true},
+	"geometry": {
+		"type": "LineString",
+		"coordinates": [[-73.67964, 42.72283], [-73.67948, 42.72297], [-73.6793, 42.7231, 12.5]]
+	}
+}`
+	route, err := s.ImportRouteGeoJSON(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ms.RouteService.AssertExpectations(t)
+
+	if route.Name != "West" || !route.Enabled {
+		t.Errorf("got name %s and enabled %t", route.Name, route.Enabled)
+	}
+	expected := []shuttletracker.Point{
+		{Latitude: 42.72283, Longitude: -73.67964},
+		{Latitude: 42.72297, Longitude: -73.67948},
+		{Latitude: 42.7231, Longitude: -73.6793},
+	}
+	if len(route.Points) != len(expected) {
+		t.Fatalf("got %d points, expected %d", len(route.Points), len(expected))
+	}
+	for i := range expected {
+		if route.Points[i] != expected[i] {
+			t.Errorf("point %d: got %+v, expected %+v", i, route.Points[i], expected[i])
+		}
+	}
+}
+
+func TestImportRouteGeoJSONRejectsOtherGeometries(t *testing.T) {
+	ms := &stmock.ModelService{}
+	s := New(ms)
+
+	doc := `{"type": "Feature", "properties": {"name": "Stop"}, "geometry": {"type": "Point", "coordinates": [-73.67964, 42.72283]}}`
+	_, err := s.ImportRouteGeoJSON(strings.NewReader(doc))
+	if err == nil {
+		t.Fatal("expected an error for a Point geometry")
+	}
+	ms.RouteService.AssertNotCalled(t, "CreateRoute", mock.Anything)
+}
+
+func TestLocationsGeoJSON(t *testing.T) {
+	start := time.Date(2018, time.April, 16, 8, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	routeID := int64(3)
+	ms := &stmock.ModelService{}
+	ms.LocationService.On("LocationsBetween", int64(1), start, end, shuttletracker.Collapse{}).Return([]*shuttletracker.Location{
+		{Latitude: 42.72283, Longitude: -73.67964, Speed: 12, Heading: 90, Time: start, RouteID: &routeID},
+		{Latitude: 42.72297, Longitude: -73.67948, Time: start.Add(time.Minute)},
+	}, nil)
+	ms.LocationService.On("LocationsBetween", int64(2), start, end, shuttletracker.Collapse{}).Return([]*shuttletracker.Location{}, nil)
+	s := New(ms)
+
+	b := &bytes.Buffer{}
+	err := s.LocationsGeoJSON(b, 1, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	collection := struct {
+		Type     string            `json:"type"`
+		Features []locationFeature `json:"features"`
+	}{}
+	err = json.Unmarshal(b.Bytes(), &collection)
+	if err != nil {
+		t.Fatalf("unable to decode %s: %s", b.String(), err)
+	}
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 2 {
+		t.Fatalf("got %s, expected a FeatureCollection with two Features", b.String())
+	}
+	first := collection.Features[0]
+	if first.Geometry.Coordinates != [2]float64{-73.67964, 42.72283} {
+		t.Errorf("got coordinates %v, expected longitude first", first.Geometry.Coordinates)
+	}
+	if first.Properties.Speed != 12 || first.Properties.Heading != 90 || first.Properties.RouteID == nil || *first.Properties.RouteID != routeID {
+		t.Errorf("got properties %+v", first.Properties)
+	}
+	if collection.Features[1].Properties.RouteID != nil {
+		t.Errorf("got route ID %d for an off-route Location", *collection.Features[1].Properties.RouteID)
+	}
+
+	b.Reset()
+	err = s.LocationsGeoJSON(b, 2, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if b.String() != `{"type":"FeatureCollection","features":[]}` {
+		t.Errorf("got %s, expected an empty FeatureCollection", b.String())
+	}
+}
@@ -0,0 +1,98 @@
+// Package kml exports shuttletracker's models as KML documents for GIS tools such as Google Earth.
+package kml
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// defaultColor is the KML color (aabbggrr) of paths that aren't on a Route.
+const defaultColor = "ff0000ff"
+
+// Service exports shuttletracker's models as KML.
+type Service struct {
+	ms shuttletracker.ModelService
+}
+
+// New creates a Service backed by ms.
+func New(ms shuttletracker.ModelService) *Service {
+	return &Service{ms: ms}
+}
+
+// kmlColor converts a "#rrggbb" or "#rrggbbaa" color into KML's "aabbggrr" format.
+func kmlColor(color string) (string, bool) {
+	color = strings.TrimPrefix(color, "#")
+	alpha := "ff"
+	switch len(color) {
+	case 6:
+	case 8:
+		alpha = color[6:8]
+	default:
+		return "", false
+	}
+	return strings.ToLower(alpha + color[4:6] + color[2:4] + color[0:2]), true
+}
+
+// ExportPathKML writes a KML document to w containing a LineString of the Vehicle's Locations with
+// tracker Times in [start, end), in order. The line is drawn in the color of the Route that the first
+// routed Location was on, if any. If there are no Locations, a valid document without a path is written.
+func (s *Service) ExportPathKML(w io.Writer, vehicleID int64, start, end time.Time) error {
+	vehicle, err := s.ms.Vehicle(vehicleID)
+	if err != nil {
+		return err
+	}
+	locations, err := s.ms.LocationsBetween(vehicleID, start, end, shuttletracker.Collapse{})
+	if err != nil {
+		return err
+	}
+
+	color := defaultColor
+	for _, location := range locations {
+		if location.RouteID == nil {
+			continue
+		}
+		route, err := s.ms.Route(*location.RouteID)
+		if err == shuttletracker.ErrRouteNotFound {
+			break
+		} else if err != nil {
+			return err
+		}
+		if c, ok := kmlColor(route.Color); ok {
+			color = c
+		}
+		break
+	}
+
+	return writeDocument(w, vehicle.Name, color, locations)
+}
+
+// writeDocument writes a KML document with a single path named name.
+func writeDocument(w io.Writer, name, color string, locations []*shuttletracker.Location) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, xml.Header)
+	fmt.Fprint(bw, `<kml xmlns="http://www.opengis.net/kml/2.2">`+"\n<Document>\n<name>")
+	err := xml.EscapeText(bw, []byte(name))
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(bw, "</name>\n")
+
+	if len(locations) > 0 {
+		fmt.Fprintf(bw, "<Style id=\"path\"><LineStyle><color>%s</color><width>4</width></LineStyle></Style>\n", color)
+		fmt.Fprint(bw, "<Placemark>\n<styleUrl>#path</styleUrl>\n<LineString>\n<tessellate>1</tessellate>\n<coordinates>\n")
+		for _, location := range locations {
+			// KML coordinates are longitude first
+			fmt.Fprintf(bw, "%f,%f\n", location.Longitude, location.Latitude)
+		}
+		fmt.Fprint(bw, "</coordinates>\n</LineString>\n</Placemark>\n")
+	}
+
+	fmt.Fprint(bw, "</Document>\n</kml>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,91 @@
+package kml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+// validXML returns an error if b is not well-formed XML.
+func validXML(b []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func TestKMLColor(t *testing.T) {
+	color, ok := kmlColor("#FF8000")
+	if !ok || color != "ff0080ff" {
+		t.Errorf("got %s, expected ff0080ff", color)
+	}
+	color, ok = kmlColor("#ff800080")
+	if !ok || color != "800080ff" {
+		t.Errorf("got %s, expected 800080ff", color)
+	}
+	if _, ok = kmlColor("red"); ok {
+		t.Error("expected an invalid color to be rejected")
+	}
+}
+
+func TestExportPathKML(t *testing.T) {
+	routeID := int64(3)
+	start := time.Now().Add(-time.Hour)
+	end := time.Now()
+	locations := []*shuttletracker.Location{
+		{Latitude: 42.73, Longitude: -73.68},
+		{Latitude: 42.74, Longitude: -73.67, RouteID: &routeID},
+	}
+	ms := &mock.ModelService{}
+	ms.VehicleService.On("Vehicle", int64(1)).Return(&shuttletracker.Vehicle{ID: 1, Name: "Bus <1>"}, nil)
+	ms.LocationService.On("LocationsBetween", int64(1), start, end, shuttletracker.Collapse{}).Return(locations, nil)
+	ms.RouteService.On("Route", routeID).Return(&shuttletracker.Route{ID: routeID, Color: "#00ff00"}, nil)
+	s := New(ms)
+
+	buf := &bytes.Buffer{}
+	err := s.ExportPathKML(buf, 1, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	doc := buf.String()
+	if err := validXML(buf.Bytes()); err != nil {
+		t.Errorf("document is not valid XML: %s", err)
+	}
+	for _, expected := range []string{"<name>Bus &lt;1&gt;</name>", "<color>ff00ff00</color>", "-73.680000,42.730000\n-73.670000,42.740000"} {
+		if !strings.Contains(doc, expected) {
+			t.Errorf("document does not contain %q:\n%s", expected, doc)
+		}
+	}
+}
+
+func TestExportPathKMLEmpty(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	end := time.Now()
+	ms := &mock.ModelService{}
+	ms.VehicleService.On("Vehicle", int64(1)).Return(&shuttletracker.Vehicle{ID: 1, Name: "Bus 1"}, nil)
+	ms.LocationService.On("LocationsBetween", int64(1), start, end, shuttletracker.Collapse{}).Return([]*shuttletracker.Location{}, nil)
+	s := New(ms)
+
+	buf := &bytes.Buffer{}
+	err := s.ExportPathKML(buf, 1, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := validXML(buf.Bytes()); err != nil {
+		t.Errorf("document is not valid XML: %s", err)
+	}
+	if strings.Contains(buf.String(), "<LineString>") {
+		t.Error("empty path should not have a LineString")
+	}
+}
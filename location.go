@@ -21,14 +21,94 @@ type Location struct {
 
 	// RouteID is a pointer to an int64 because it may be null.
 	RouteID *int64 `json:"route_id"`
+
+	// TripID identifies the run of Locations that this Location is part of. It is null when the
+	// vehicle is not on a route.
+	TripID *int64 `json:"trip_id"`
+
+	// RouteVersion is the Version of the Route's geometry that this Location was matched against. It
+	// is null when the Location isn't on a route or was stored before routes had versions.
+	RouteVersion *int64 `json:"route_version"`
+
+	// SpeedDerived is true when Speed was computed from the distance and time since the previous
+	// Location because the tracker reported a speed of zero while moving.
+	SpeedDerived bool `json:"speed_derived"`
+
+	// ReportedSpeed is the speed in miles per hour that the tracker reported, which differs from Speed
+	// when SpeedDerived is true. It is a pointer because it is null for Locations stored before
+	// reported speeds were kept.
+	ReportedSpeed *float64 `json:"reported_speed"`
 }
 
 // LocationService is an interface for interacting with information about vehicle positions.
 type LocationService interface {
 	CreateLocation(location *Location) error
+	CreateHistoricalLocations(locations []*Location) error
 	DeleteLocationsBefore(before time.Time) (int, error)
+	ClearVehicleLocations(vehicleID int64) (int64, error)
 	LocationsSince(vehicleID int64, since time.Time) ([]*Location, error)
+	LocationsBetween(vehicleID int64, start, end time.Time, collapse Collapse) ([]*Location, error)
+	LocationsDownsampled(vehicleID int64, start, end time.Time, bucket time.Duration) ([]*Location, error)
+	LocationHistory(query LocationQuery) ([]*Location, int, error)
 	LatestLocation(vehicleID int64) (*Location, error)
+	LatestLocations() (map[int64]*Location, error)
+	ExistsLocation(trackerID string, t time.Time) (bool, error)
+	EarliestLocation(vehicleID int64, since time.Time) (*Location, error)
+	RecentLocations(vehicleID int64, n int) ([]*Location, error)
+	LocationsWithoutRoute(start, end time.Time) ([]*Location, error)
+	SpeedingEvents(start, end time.Time) ([]SpeedingEvent, error)
+	SpeedStats(since time.Time) (SpeedStats, error)
+	NewTripID() (int64, error)
+	LocationsForTrip(tripID int64) ([]*Location, error)
+}
+
+// LocationQuery selects a page of a Vehicle's Locations for LocationHistory. Zero values don't filter.
+type LocationQuery struct {
+	VehicleID int64
+
+	// Start and End bound the Locations' times. Start is inclusive and End is exclusive.
+	Start time.Time
+	End   time.Time
+
+	// OnRoute selects only Locations that are on a route if true, or only those that aren't if false.
+	OnRoute *bool
+
+	// RouteID selects only Locations on a certain route.
+	RouteID *int64
+
+	// MinSpeed is the slowest speed in miles per hour that is selected.
+	MinSpeed float64
+
+	// Limit is the most Locations that are returned, and Offset is how many are skipped first. A Limit of
+	// zero returns every Location after the Offset.
+	Limit  int
+	Offset int
+
+	// Collapse merges near-duplicate Locations before Limit and Offset are applied, so pages and the
+	// total count collapsed Locations.
+	Collapse Collapse
+}
+
+// Collapse merges each run of consecutive Locations that stay within Distance meters and Within of
+// the run's oldest Location into that Location, e.g. while a vehicle idles. Stored Locations are
+// unchanged. A Distance of zero doesn't collapse, and a Within of zero doesn't limit how long a run
+// may last.
+type Collapse struct {
+	Distance float64
+	Within   time.Duration
+}
+
+// SpeedingEvent is a Location whose speed exceeded the speed limit of the Route it was on.
+type SpeedingEvent struct {
+	Location   *Location `json:"location"`
+	SpeedLimit float64   `json:"speed_limit"`
+}
+
+// SpeedStats summarizes the speeds of a set of Locations in miles per hour.
+type SpeedStats struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+	Max   float64 `json:"max"`
 }
 
 var (
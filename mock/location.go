@@ -19,20 +19,104 @@ func (ls *LocationService) CreateLocation(location *shuttletracker.Location) err
 	return args.Error(0)
 }
 
+// CreateHistoricalLocations creates Locations with the times and creation times they already have.
+func (ls *LocationService) CreateHistoricalLocations(locations []*shuttletracker.Location) error {
+	args := ls.Called(locations)
+	return args.Error(0)
+}
+
 // DeleteLocationsBefore deletes Locations from before a certain time.
 func (ls *LocationService) DeleteLocationsBefore(before time.Time) (int, error) {
 	args := ls.Called(before)
 	return args.Int(0), args.Error(1)
 }
 
+// ClearVehicleLocations deletes every Location of a Vehicle.
+func (ls *LocationService) ClearVehicleLocations(vehicleID int64) (int64, error) {
+	args := ls.Called(vehicleID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 // LocationsSince gets Locations since a time for a certain Vehicle.
 func (ls *LocationService) LocationsSince(vehicleID int64, since time.Time) ([]*shuttletracker.Location, error) {
 	args := ls.Called(vehicleID)
 	return args.Get(0).([]*shuttletracker.Location), args.Error(1)
 }
 
+// LocationsBetween gets Locations between two times for a certain Vehicle.
+func (ls *LocationService) LocationsBetween(vehicleID int64, start, end time.Time, collapse shuttletracker.Collapse) ([]*shuttletracker.Location, error) {
+	args := ls.Called(vehicleID, start, end, collapse)
+	return args.Get(0).([]*shuttletracker.Location), args.Error(1)
+}
+
+// LocationsDownsampled gets at most one Location per bucket between two times for a certain Vehicle.
+func (ls *LocationService) LocationsDownsampled(vehicleID int64, start, end time.Time, bucket time.Duration) ([]*shuttletracker.Location, error) {
+	args := ls.Called(vehicleID, start, end, bucket)
+	return args.Get(0).([]*shuttletracker.Location), args.Error(1)
+}
+
+// LocationHistory gets a page of a Vehicle's Locations matching a query and the number that match.
+func (ls *LocationService) LocationHistory(query shuttletracker.LocationQuery) ([]*shuttletracker.Location, int, error) {
+	args := ls.Called(query)
+	return args.Get(0).([]*shuttletracker.Location), args.Int(1), args.Error(2)
+}
+
 // LatestLocation returns the most recent Location for a Vehicle.
 func (ls *LocationService) LatestLocation(vehicleID int64) (*shuttletracker.Location, error) {
 	args := ls.Called(vehicleID)
 	return args.Get(0).(*shuttletracker.Location), args.Error(1)
 }
+
+// LatestLocations returns the most recent Location of every Vehicle.
+func (ls *LocationService) LatestLocations() (map[int64]*shuttletracker.Location, error) {
+	args := ls.Called()
+	return args.Get(0).(map[int64]*shuttletracker.Location), args.Error(1)
+}
+
+// EarliestLocation returns the first Location for a Vehicle since a time.
+func (ls *LocationService) EarliestLocation(vehicleID int64, since time.Time) (*shuttletracker.Location, error) {
+	args := ls.Called(vehicleID, since)
+	return args.Get(0).(*shuttletracker.Location), args.Error(1)
+}
+
+// ExistsLocation returns whether a Location exists for a tracker at a time.
+func (ls *LocationService) ExistsLocation(trackerID string, t time.Time) (bool, error) {
+	args := ls.Called(trackerID, t)
+	return args.Bool(0), args.Error(1)
+}
+
+// RecentLocations returns the n most recent Locations for a Vehicle.
+func (ls *LocationService) RecentLocations(vehicleID int64, n int) ([]*shuttletracker.Location, error) {
+	args := ls.Called(vehicleID, n)
+	return args.Get(0).([]*shuttletracker.Location), args.Error(1)
+}
+
+// LocationsWithoutRoute gets Locations without a Route between two times.
+func (ls *LocationService) LocationsWithoutRoute(start, end time.Time) ([]*shuttletracker.Location, error) {
+	args := ls.Called(start, end)
+	return args.Get(0).([]*shuttletracker.Location), args.Error(1)
+}
+
+// SpeedingEvents gets SpeedingEvents between two times.
+func (ls *LocationService) SpeedingEvents(start, end time.Time) ([]shuttletracker.SpeedingEvent, error) {
+	args := ls.Called(start, end)
+	return args.Get(0).([]shuttletracker.SpeedingEvent), args.Error(1)
+}
+
+// SpeedStats summarizes the speeds of Locations created since a time.
+func (ls *LocationService) SpeedStats(since time.Time) (shuttletracker.SpeedStats, error) {
+	args := ls.Called(since)
+	return args.Get(0).(shuttletracker.SpeedStats), args.Error(1)
+}
+
+// NewTripID reserves an ID for a new trip.
+func (ls *LocationService) NewTripID() (int64, error) {
+	args := ls.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// LocationsForTrip gets the Locations belonging to a trip.
+func (ls *LocationService) LocationsForTrip(tripID int64) ([]*shuttletracker.Location, error) {
+	args := ls.Called(tripID)
+	return args.Get(0).([]*shuttletracker.Location), args.Error(1)
+}
@@ -1,6 +1,8 @@
 package mock
 
 import (
+	"time"
+
 	"github.com/stretchr/testify/mock"
 
 	"github.com/wtg/shuttletracker"
@@ -23,6 +25,54 @@ func (rs *RouteService) DeleteRoute(id int64) error {
 	return args.Error(0)
 }
 
+// RoutesForStop gets all Routes that serve a Stop.
+func (rs *RouteService) RoutesForStop(stopID int64) ([]*shuttletracker.Route, error) {
+	args := rs.Called(stopID)
+	return args.Get(0).([]*shuttletracker.Route), args.Error(1)
+}
+
+// RoutesWithVehicleCounts gets all Routes along with how many vehicles are on each.
+func (rs *RouteService) RoutesWithVehicleCounts(within time.Duration) ([]shuttletracker.RouteWithCount, error) {
+	args := rs.Called(within)
+	return args.Get(0).([]shuttletracker.RouteWithCount), args.Error(1)
+}
+
+// CurrentRoute gets the Route that a Vehicle is currently on.
+func (rs *RouteService) CurrentRoute(vehicleID int64) (*shuttletracker.Route, error) {
+	args := rs.Called(vehicleID)
+	return args.Get(0).(*shuttletracker.Route), args.Error(1)
+}
+
+// SetCurrentRoute records the Route that a Vehicle is currently on.
+func (rs *RouteService) SetCurrentRoute(vehicleID int64, routeID *int64, confidence float64) error {
+	args := rs.Called(vehicleID, routeID, confidence)
+	return args.Error(0)
+}
+
+// ScheduledRoutes gets the Route that each Vehicle is scheduled to run.
+func (rs *RouteService) ScheduledRoutes() (map[int64]int64, error) {
+	args := rs.Called()
+	return args.Get(0).(map[int64]int64), args.Error(1)
+}
+
+// SetScheduledRoute records the Route that a Vehicle is scheduled to run.
+func (rs *RouteService) SetScheduledRoute(vehicleID int64, routeID *int64) error {
+	args := rs.Called(vehicleID, routeID)
+	return args.Error(0)
+}
+
+// Timetable gets the Timetable of a Route.
+func (rs *RouteService) Timetable(routeID int64) (shuttletracker.Timetable, error) {
+	args := rs.Called(routeID)
+	return args.Get(0).(shuttletracker.Timetable), args.Error(1)
+}
+
+// SetTimetable replaces the Timetable of a Route.
+func (rs *RouteService) SetTimetable(routeID int64, timetable shuttletracker.Timetable) error {
+	args := rs.Called(routeID, timetable)
+	return args.Error(0)
+}
+
 // Route gets a Route.
 func (rs *RouteService) Route(id int64) (*shuttletracker.Route, error) {
 	args := rs.Called(id)
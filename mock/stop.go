@@ -16,6 +16,12 @@ func (ss *StopService) CreateStop(stop *shuttletracker.Stop) error {
 	return args.Error(0)
 }
 
+// ModifyStop modifies an existing Stop.
+func (ss *StopService) ModifyStop(stop *shuttletracker.Stop) error {
+	args := ss.Called(stop)
+	return args.Error(0)
+}
+
 // DeleteStop deletes a Stop.
 func (ss *StopService) DeleteStop(id int64) error {
 	args := ss.Called(id)
@@ -27,3 +33,27 @@ func (ss *StopService) Stops() ([]*shuttletracker.Stop, error) {
 	args := ss.Called()
 	return args.Get(0).([]*shuttletracker.Stop), args.Error(1)
 }
+
+// UpdateStopCoordinates moves Stops to corrected coordinates.
+func (ss *StopService) UpdateStopCoordinates(updates []shuttletracker.StopCoordinateUpdate) error {
+	args := ss.Called(updates)
+	return args.Error(0)
+}
+
+// FindDuplicateStops groups Stops that are within a radius of each other.
+func (ss *StopService) FindDuplicateStops(radius float64) ([][]*shuttletracker.Stop, error) {
+	args := ss.Called(radius)
+	return args.Get(0).([][]*shuttletracker.Stop), args.Error(1)
+}
+
+// MergeStops replaces Stops with another Stop.
+func (ss *StopService) MergeStops(keepID int64, mergeIDs []int64) error {
+	args := ss.Called(keepID, mergeIDs)
+	return args.Error(0)
+}
+
+// OrphanStops gets the Stops that no active Route serves.
+func (ss *StopService) OrphanStops() ([]*shuttletracker.Stop, error) {
+	args := ss.Called()
+	return args.Get(0).([]*shuttletracker.Stop), args.Error(1)
+}
@@ -1,6 +1,8 @@
 package mock
 
 import (
+	"time"
+
 	"github.com/stretchr/testify/mock"
 
 	"github.com/wtg/shuttletracker"
@@ -47,8 +49,44 @@ func (vs *VehicleService) EnabledVehicles() ([]*shuttletracker.Vehicle, error) {
 	return args.Get(0).([]*shuttletracker.Vehicle), args.Error(1)
 }
 
+// RecentlyActiveVehicles gets all enabled Vehicles with a Location within a duration of now.
+func (vs *VehicleService) RecentlyActiveVehicles(within time.Duration) ([]*shuttletracker.Vehicle, error) {
+	args := vs.Called(within)
+	return args.Get(0).([]*shuttletracker.Vehicle), args.Error(1)
+}
+
+// VehiclesByRecentActivity gets all enabled Vehicles and their latest Locations, most recently active first.
+func (vs *VehicleService) VehiclesByRecentActivity() ([]shuttletracker.VehicleActivity, error) {
+	args := vs.Called()
+	return args.Get(0).([]shuttletracker.VehicleActivity), args.Error(1)
+}
+
+// VehiclesModifiedSince gets all Vehicles created or modified after a time.
+func (vs *VehicleService) VehiclesModifiedSince(t time.Time) ([]*shuttletracker.Vehicle, error) {
+	args := vs.Called(t)
+	return args.Get(0).([]*shuttletracker.Vehicle), args.Error(1)
+}
+
+// VehiclesWithTag gets all Vehicles that have a tag.
+func (vs *VehicleService) VehiclesWithTag(tag string) ([]*shuttletracker.Vehicle, error) {
+	args := vs.Called(tag)
+	return args.Get(0).([]*shuttletracker.Vehicle), args.Error(1)
+}
+
 // ModifyVehicle modifies a Vehicle.
 func (vs *VehicleService) ModifyVehicle(vehicle *shuttletracker.Vehicle) error {
 	args := vs.Called(vehicle)
 	return args.Error(0)
 }
+
+// MergeVehicles merges one Vehicle into another.
+func (vs *VehicleService) MergeVehicles(keepID, mergeID int64) error {
+	args := vs.Called(keepID, mergeID)
+	return args.Error(0)
+}
+
+// RepairVehicleTimestamps fixes Vehicles whose updated time is missing or before their created time.
+func (vs *VehicleService) RepairVehicleTimestamps() (int64, error) {
+	args := vs.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -1,6 +1,8 @@
 package shuttletracker
 
 // ModelService is a collection of interfaces related to vehicles, routes, stops, and their locations.
+// Every time.Time that its services return is in UTC; convert times to a display time zone only when
+// serving them.
 type ModelService interface {
 	VehicleService
 	RouteService
@@ -2,20 +2,29 @@ package postgres
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
 )
 
 // LocationService implements shuttletracker.LocationService.
 type LocationService struct {
 	db *sql.DB
+
+	// read is where queries that may lag behind writes are sent, which is the replica for the
+	// Postgres returned by ReadReplica and the primary otherwise. ExistsLocation always reads from
+	// the primary since it guards against storing duplicates.
+	read *sql.DB
 }
 
 // Initializes how the data is represented in the Postgres database
 func (ls *LocationService) initializeSchema(db *sql.DB) error {
 	// Postgres command that creates a location table in the database
 	ls.db = db
+	ls.read = db
 	schema := `
 CREATE TABLE IF NOT EXISTS locations (
 	id serial PRIMARY KEY,
@@ -28,7 +37,13 @@ CREATE TABLE IF NOT EXISTS locations (
 	route_id integer,
 	created timestamp with time zone NOT NULL DEFAULT now(),
 	UNIQUE (tracker_id, time)
-);`
+);
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS trip_id bigint;
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS route_version integer;
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS speed_derived boolean NOT NULL DEFAULT false;
+ALTER TABLE locations ADD COLUMN IF NOT EXISTS reported_speed real;
+CREATE INDEX IF NOT EXISTS locations_trip_id_idx ON locations (trip_id);
+CREATE SEQUENCE IF NOT EXISTS trips_id_seq;`
 	_, err := ls.db.Exec(schema)
 	return err
 }
@@ -44,20 +59,71 @@ WITH location AS (
 		heading,
 		speed,
 		time,
-		route_id
-	) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		route_id,
+		trip_id,
+		route_version,
+		speed_derived,
+		reported_speed
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	RETURNING id, tracker_id, created)
 SELECT
 	location.id AS location_id,
-	vehicles.id AS vehicle_id,
+	vehicle_trackers.vehicle_id,
 	location.created
 FROM location
-LEFT JOIN vehicles ON vehicles.tracker_id = location.tracker_id;`
-	row := ls.db.QueryRow(query, l.TrackerID, l.Latitude, l.Longitude, l.Heading, l.Speed, l.Time, l.RouteID)
-	err := row.Scan(&l.ID, &l.VehicleID, &l.Created)
+LEFT JOIN vehicle_trackers ON vehicle_trackers.tracker_id = location.tracker_id;`
+	row := ls.db.QueryRow(query, l.TrackerID, l.Latitude, l.Longitude, l.Heading, l.Speed, l.Time, l.RouteID, l.TripID, l.RouteVersion, l.SpeedDerived, l.ReportedSpeed)
+	err := row.Scan(&l.ID, &l.VehicleID, scanUTC{&l.Created})
 	return err
 }
 
+// CreateHistoricalLocations creates previously captured Locations in a single transaction, keeping
+// their times. Locations without a Created time are given their Time instead of now so that they sort
+// as they originally would have. Locations that duplicate a stored tracker ID and time are skipped and
+// keep a zero ID; the rest have their ID and Created set.
+func (ls *LocationService) CreateHistoricalLocations(locations []*shuttletracker.Location) error {
+	tx, err := ls.db.Begin()
+	if err != nil {
+		return err
+	}
+	// We can't really do anything if rolling back a transaction fails.
+	// nolint: errcheck
+	defer tx.Rollback()
+
+	err = createHistoricalLocations(tx, locations)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createHistoricalLocations creates previously captured Locations within tx as described for
+// CreateHistoricalLocations.
+func createHistoricalLocations(tx *sql.Tx, locations []*shuttletracker.Location) error {
+	statement, err := tx.Prepare("INSERT INTO locations (tracker_id, latitude, longitude, heading, speed, time, route_id, trip_id, route_version, speed_derived, reported_speed, created)" +
+		" VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)" +
+		" ON CONFLICT (tracker_id, time) DO NOTHING RETURNING id, created;")
+	if err != nil {
+		return err
+	}
+	defer statement.Close()
+
+	for _, l := range locations {
+		created := l.Created
+		if created.IsZero() {
+			created = l.Time
+		}
+		row := statement.QueryRow(l.TrackerID, l.Latitude, l.Longitude, l.Heading, l.Speed, l.Time, l.RouteID, l.TripID, l.RouteVersion, l.SpeedDerived, l.ReportedSpeed, created)
+		err = row.Scan(&l.ID, scanUTC{&l.Created})
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // DeleteLocationsBefore deletes all Locations in the database with tracker times before the provided Time.
 func (ls *LocationService) DeleteLocationsBefore(before time.Time) (int, error) {
 	statement := "DELETE FROM locations WHERE time < $1;"
@@ -75,12 +141,211 @@ func (ls *LocationService) DeleteLocationsBefore(before time.Time) (int, error)
 	return int(n), nil
 }
 
+// ClearVehicleLocations deletes every Location reported by any of a Vehicle's trackers and returns the
+// number deleted. The Vehicle itself and other Vehicles' Locations are left alone.
+func (ls *LocationService) ClearVehicleLocations(vehicleID int64) (int64, error) {
+	tx, err := ls.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// We can't really do anything if rolling back a transaction fails.
+	// nolint: errcheck
+	defer tx.Rollback()
+
+	var exists bool
+	row := tx.QueryRow("SELECT true FROM vehicles WHERE id = $1 FOR UPDATE;", vehicleID)
+	err = row.Scan(&exists)
+	if err == sql.ErrNoRows {
+		return 0, shuttletracker.ErrVehicleNotFound
+	} else if err != nil {
+		return 0, err
+	}
+
+	statement := "DELETE FROM locations WHERE tracker_id IN " +
+		"(SELECT tracker_id FROM vehicle_trackers WHERE vehicle_id = $1);"
+	res, err := tx.Exec(statement, vehicleID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
 // LocationsSince returns all Locations since a tracker Time for a certain Vehicle, ordered newest to oldest.
 func (ls *LocationService) LocationsSince(vehicleID int64, since time.Time) ([]*shuttletracker.Location, error) {
 	locations := []*shuttletracker.Location{}
-	query := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.created " +
-		"FROM locations l, vehicles v WHERE l.tracker_id = v.tracker_id AND v.id = $1 AND l.time > $2 ORDER BY l.created DESC;"
-	rows, err := ls.db.Query(query, vehicleID, since)
+	query := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created " +
+		"FROM locations l, vehicle_trackers t WHERE l.tracker_id = t.tracker_id AND t.vehicle_id = $1 AND l.time > $2 ORDER BY l.created DESC;"
+	rows, err := ls.read.Query(query, vehicleID, since)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		l := &shuttletracker.Location{
+			VehicleID: &vehicleID,
+		}
+		err := rows.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created})
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, nil
+}
+
+// LocationsBetween returns all Locations with tracker Times in [start, end) for a certain Vehicle, ordered oldest to newest,
+// with near-duplicate Locations merged as collapse describes.
+func (ls *LocationService) LocationsBetween(vehicleID int64, start, end time.Time, collapse shuttletracker.Collapse) ([]*shuttletracker.Location, error) {
+	if err := validateCollapse(collapse); err != nil {
+		return nil, err
+	}
+	locations := []*shuttletracker.Location{}
+	query := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created " +
+		"FROM locations l, vehicle_trackers t WHERE l.tracker_id = t.tracker_id AND t.vehicle_id = $1 " +
+		"AND l.time >= $2 AND l.time < $3 ORDER BY l.time ASC;"
+	rows, err := ls.read.Query(query, vehicleID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		l := &shuttletracker.Location{
+			VehicleID: &vehicleID,
+		}
+		err := rows.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created})
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return spatial.CollapseLocations(locations, collapse.Distance, collapse.Within), nil
+}
+
+// validateCollapse returns an error if collapse's thresholds are negative.
+func validateCollapse(collapse shuttletracker.Collapse) error {
+	if collapse.Distance < 0 || collapse.Within < 0 {
+		return fmt.Errorf("collapse distance (%v) and time (%s) must not be negative", collapse.Distance, collapse.Within)
+	}
+	return nil
+}
+
+// LocationsDownsampled returns the first Location in each bucket-long interval from start until end
+// for a certain Vehicle, ordered oldest to newest. Intervals without a Location are skipped.
+func (ls *LocationService) LocationsDownsampled(vehicleID int64, start, end time.Time, bucket time.Duration) ([]*shuttletracker.Location, error) {
+	if bucket <= 0 {
+		return nil, fmt.Errorf("bucket must be positive, got %s", bucket)
+	}
+	locations := []*shuttletracker.Location{}
+	query := "SELECT DISTINCT ON (floor(extract(epoch FROM l.time - $2::timestamptz) / $4::double precision)) " +
+		"l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created " +
+		"FROM locations l, vehicle_trackers t WHERE l.tracker_id = t.tracker_id AND t.vehicle_id = $1 " +
+		"AND l.time >= $2 AND l.time < $3 ORDER BY floor(extract(epoch FROM l.time - $2::timestamptz) / $4::double precision), l.time ASC;"
+	rows, err := ls.read.Query(query, vehicleID, start, end, bucket.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		l := &shuttletracker.Location{
+			VehicleID: &vehicleID,
+		}
+		err := rows.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created})
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, nil
+}
+
+// LocationHistory returns the page of a Vehicle's Locations selected by query, ordered newest to
+// oldest, along with the number of Locations that match the query's filters regardless of its Limit and
+// Offset. When query collapses Locations, every matching Location is read and collapsed before the page
+// is taken, and the total counts collapsed Locations.
+func (ls *LocationService) LocationHistory(query shuttletracker.LocationQuery) ([]*shuttletracker.Location, int, error) {
+	if query.Limit < 0 || query.Offset < 0 {
+		return nil, 0, fmt.Errorf("limit (%d) and offset (%d) must not be negative", query.Limit, query.Offset)
+	}
+	if err := validateCollapse(query.Collapse); err != nil {
+		return nil, 0, err
+	}
+
+	args := []interface{}{query.VehicleID}
+	conditions := []string{"l.tracker_id = t.tracker_id", "t.vehicle_id = $1"}
+	// filter adds a condition comparing a column to a value, which is passed as a parameter
+	filter := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if !query.Start.IsZero() {
+		filter("l.time >= $%d", query.Start)
+	}
+	if !query.End.IsZero() {
+		filter("l.time < $%d", query.End)
+	}
+	if query.OnRoute != nil {
+		if *query.OnRoute {
+			conditions = append(conditions, "l.route_id IS NOT NULL")
+		} else {
+			conditions = append(conditions, "l.route_id IS NULL")
+		}
+	}
+	if query.RouteID != nil {
+		filter("l.route_id = $%d", *query.RouteID)
+	}
+	if query.MinSpeed > 0 {
+		filter("l.speed >= $%d", query.MinSpeed)
+	}
+	where := " FROM locations l, vehicle_trackers t WHERE " + strings.Join(conditions, " AND ")
+	columns := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created"
+
+	if query.Collapse.Distance > 0 {
+		// collapse oldest to newest so that each run keeps its oldest Location
+		locations, err := ls.locationHistoryRows(query.VehicleID, columns+where+" ORDER BY l.time ASC, l.id ASC;", args...)
+		if err != nil {
+			return nil, 0, err
+		}
+		collapsed := spatial.CollapseLocations(locations, query.Collapse.Distance, query.Collapse.Within)
+		for i, j := 0, len(collapsed)-1; i < j; i, j = i+1, j-1 {
+			collapsed[i], collapsed[j] = collapsed[j], collapsed[i]
+		}
+		total := len(collapsed)
+		if query.Offset >= total {
+			return []*shuttletracker.Location{}, total, nil
+		}
+		collapsed = collapsed[query.Offset:]
+		if query.Limit > 0 && query.Limit < len(collapsed) {
+			collapsed = collapsed[:query.Limit]
+		}
+		return collapsed, total, nil
+	}
+
+	var total int
+	err := ls.read.QueryRow("SELECT count(*)"+where+";", args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	statement := columns + where + " ORDER BY l.time DESC, l.id DESC"
+	if query.Limit > 0 {
+		args = append(args, query.Limit)
+		statement += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	args = append(args, query.Offset)
+	statement += fmt.Sprintf(" OFFSET $%d;", len(args))
+
+	locations, err := ls.locationHistoryRows(query.VehicleID, statement, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return locations, total, nil
+}
+
+// locationHistoryRows runs a LocationHistory statement and scans the Locations it selects.
+func (ls *LocationService) locationHistoryRows(vehicleID int64, statement string, args ...interface{}) ([]*shuttletracker.Location, error) {
+	locations := []*shuttletracker.Location{}
+	rows, err := ls.read.Query(statement, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -88,7 +353,7 @@ func (ls *LocationService) LocationsSince(vehicleID int64, since time.Time) ([]*
 		l := &shuttletracker.Location{
 			VehicleID: &vehicleID,
 		}
-		err := rows.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, &l.Time, &l.RouteID, &l.Created)
+		err := rows.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created})
 		if err != nil {
 			return nil, err
 		}
@@ -97,16 +362,18 @@ func (ls *LocationService) LocationsSince(vehicleID int64, since time.Time) ([]*
 	return locations, nil
 }
 
-// LatestLocation returns the most recent Location created for a Vehicle.
+// LatestLocation returns the Location with the latest tracker Time for a Vehicle. When read from a
+// replica, this is the latest Location that the replica has received, which may be a cycle behind the
+// data feed.
 func (ls *LocationService) LatestLocation(vehicleID int64) (*shuttletracker.Location, error) {
 	l := &shuttletracker.Location{
 		VehicleID: &vehicleID,
 	}
-	query := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.created " +
-		"FROM locations l, vehicles v WHERE l.tracker_id = v.tracker_id AND v.id = $1 " +
-		"ORDER BY l.created DESC LIMIT 1;"
-	row := ls.db.QueryRow(query, vehicleID)
-	err := row.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, &l.Time, &l.RouteID, &l.Created)
+	query := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created " +
+		"FROM locations l, vehicle_trackers t WHERE l.tracker_id = t.tracker_id AND t.vehicle_id = $1 " +
+		"ORDER BY l.time DESC LIMIT 1;"
+	row := ls.read.QueryRow(query, vehicleID)
+	err := row.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created})
 	if err == sql.ErrNoRows {
 		return nil, shuttletracker.ErrLocationNotFound
 	} else if err != nil {
@@ -114,3 +381,167 @@ func (ls *LocationService) LatestLocation(vehicleID int64) (*shuttletracker.Loca
 	}
 	return l, nil
 }
+
+// LatestLocations returns the Location with the latest tracker Time for every Vehicle that has one,
+// keyed by Vehicle ID. Each is the Location that LatestLocation returns for its Vehicle.
+func (ls *LocationService) LatestLocations() (map[int64]*shuttletracker.Location, error) {
+	locations := map[int64]*shuttletracker.Location{}
+	query := "SELECT DISTINCT ON (t.vehicle_id) t.vehicle_id, " +
+		"l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created " +
+		"FROM locations l, vehicle_trackers t WHERE l.tracker_id = t.tracker_id " +
+		"ORDER BY t.vehicle_id, l.time DESC;"
+	rows, err := ls.read.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var vehicleID int64
+		l := &shuttletracker.Location{}
+		err := rows.Scan(&vehicleID, &l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created})
+		if err != nil {
+			return nil, err
+		}
+		l.VehicleID = &vehicleID
+		locations[vehicleID] = l
+	}
+	return locations, nil
+}
+
+// ExistsLocation returns whether a Location with the provided tracker ID and tracker Time has been stored.
+func (ls *LocationService) ExistsLocation(trackerID string, t time.Time) (bool, error) {
+	var exists bool
+	query := "SELECT EXISTS (SELECT 1 FROM locations WHERE tracker_id = $1 AND time = $2);"
+	err := ls.db.QueryRow(query, trackerID, t).Scan(&exists)
+	return exists, err
+}
+
+// EarliestLocation returns the Location with the earliest tracker Time after since for a Vehicle.
+func (ls *LocationService) EarliestLocation(vehicleID int64, since time.Time) (*shuttletracker.Location, error) {
+	l := &shuttletracker.Location{
+		VehicleID: &vehicleID,
+	}
+	query := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created " +
+		"FROM locations l, vehicle_trackers t WHERE l.tracker_id = t.tracker_id AND t.vehicle_id = $1 AND l.time > $2 " +
+		"ORDER BY l.time ASC LIMIT 1;"
+	row := ls.read.QueryRow(query, vehicleID, since)
+	err := row.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created})
+	if err == sql.ErrNoRows {
+		return nil, shuttletracker.ErrLocationNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// RecentLocations returns the n most recent Locations created for a Vehicle, ordered newest to oldest.
+func (ls *LocationService) RecentLocations(vehicleID int64, n int) ([]*shuttletracker.Location, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("n (%d) must not be negative", n)
+	}
+	locations := []*shuttletracker.Location{}
+	if n == 0 {
+		return locations, nil
+	}
+	query := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created " +
+		"FROM locations l, vehicle_trackers t WHERE l.tracker_id = t.tracker_id AND t.vehicle_id = $1 " +
+		"ORDER BY l.created DESC LIMIT $2;"
+	rows, err := ls.read.Query(query, vehicleID, n)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		l := &shuttletracker.Location{
+			VehicleID: &vehicleID,
+		}
+		err := rows.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created})
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, nil
+}
+
+// LocationsWithoutRoute returns all Locations from known Vehicles with tracker Times in [start, end)
+// that have no Route, ordered oldest to newest.
+func (ls *LocationService) LocationsWithoutRoute(start, end time.Time) ([]*shuttletracker.Location, error) {
+	locations := []*shuttletracker.Location{}
+	query := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created, t.vehicle_id " +
+		"FROM locations l, vehicle_trackers t WHERE l.tracker_id = t.tracker_id AND l.route_id IS NULL " +
+		"AND l.time >= $1 AND l.time < $2 ORDER BY l.time ASC;"
+	rows, err := ls.read.Query(query, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		l := &shuttletracker.Location{}
+		err := rows.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created}, &l.VehicleID)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, nil
+}
+
+// SpeedingEvents returns all Locations with tracker Times in [start, end) whose speed exceeded the
+// speed limit of their Route, ordered oldest to newest. Locations without a Route and Routes without
+// a speed limit are never considered speeding.
+func (ls *LocationService) SpeedingEvents(start, end time.Time) ([]shuttletracker.SpeedingEvent, error) {
+	events := []shuttletracker.SpeedingEvent{}
+	query := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created, t.vehicle_id, r.speed_limit " +
+		"FROM locations l JOIN routes r ON l.route_id = r.id LEFT JOIN vehicle_trackers t ON l.tracker_id = t.tracker_id " +
+		"WHERE r.speed_limit > 0 AND l.speed > r.speed_limit AND l.time >= $1 AND l.time < $2 ORDER BY l.time ASC;"
+	rows, err := ls.read.Query(query, start, end)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		l := &shuttletracker.Location{}
+		event := shuttletracker.SpeedingEvent{Location: l}
+		err := rows.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created}, &l.VehicleID, &event.SpeedLimit)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// SpeedStats summarizes the speeds of the Locations from known vehicles created since a time.
+// The mean and max are zero if there are no such Locations.
+func (ls *LocationService) SpeedStats(since time.Time) (shuttletracker.SpeedStats, error) {
+	stats := shuttletracker.SpeedStats{}
+	query := "SELECT count(*), coalesce(avg(l.speed), 0), coalesce(max(l.speed), 0) " +
+		"FROM locations l, vehicle_trackers t WHERE l.tracker_id = t.tracker_id AND l.created >= $1;"
+	err := ls.read.QueryRow(query, since).Scan(&stats.Count, &stats.Mean, &stats.Max)
+	return stats, err
+}
+
+// NewTripID reserves an ID for a new trip.
+func (ls *LocationService) NewTripID() (int64, error) {
+	var id int64
+	err := ls.db.QueryRow("SELECT nextval('trips_id_seq');").Scan(&id)
+	return id, err
+}
+
+// LocationsForTrip returns the Locations belonging to a trip, ordered from oldest to newest.
+func (ls *LocationService) LocationsForTrip(tripID int64) ([]*shuttletracker.Location, error) {
+	locations := []*shuttletracker.Location{}
+	query := "SELECT l.id, l.tracker_id, l.latitude, l.longitude, l.heading, l.speed, l.time, l.route_id, l.trip_id, l.route_version, l.speed_derived, l.reported_speed, l.created, t.vehicle_id " +
+		"FROM locations l LEFT JOIN vehicle_trackers t ON l.tracker_id = t.tracker_id " +
+		"WHERE l.trip_id = $1 ORDER BY l.time ASC;"
+	rows, err := ls.read.Query(query, tripID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		l := &shuttletracker.Location{}
+		err := rows.Scan(&l.ID, &l.TrackerID, &l.Latitude, &l.Longitude, &l.Heading, &l.Speed, scanUTC{&l.Time}, &l.RouteID, &l.TripID, &l.RouteVersion, &l.SpeedDerived, &l.ReportedSpeed, scanUTC{&l.Created}, &l.VehicleID)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, nil
+}
@@ -27,6 +27,7 @@ func TestCreateLocation(t *testing.T) {
 		t.Fatalf("unable to create Vehicle: %s", err)
 	}
 
+	reportedSpeed := 0.0
 	location := &shuttletracker.Location{
 		TrackerID: "tracker1",
 		Latitude:  1.1,
@@ -35,6 +36,9 @@ func TestCreateLocation(t *testing.T) {
 		Speed:     1.4,
 		RouteID:   nil,
 		Time:      time.Now(),
+
+		SpeedDerived:  true,
+		ReportedSpeed: &reportedSpeed,
 	}
 	err = pg.CreateLocation(location)
 	if err != nil {
@@ -68,6 +72,12 @@ func TestCreateLocation(t *testing.T) {
 	if location.RouteID != actual.RouteID {
 		t.Errorf("got route ID %d, expected %d", actual.RouteID, location.RouteID)
 	}
+	if !actual.SpeedDerived {
+		t.Error("expected the speed to be marked as derived")
+	}
+	if actual.ReportedSpeed == nil || *actual.ReportedSpeed != reportedSpeed {
+		t.Errorf("got reported speed %v, expected %f", actual.ReportedSpeed, reportedSpeed)
+	}
 	if location.Time.Sub(actual.Time).Nanoseconds() > 1000 {
 		t.Errorf("got time %v, expected %v", actual.Time, location.Time)
 	}
@@ -166,3 +176,806 @@ func TestDeleteLocationsBefore(t *testing.T) {
 		t.Fatalf("got %d Locations, expected 1", len(actuals))
 	}
 }
+
+func TestClearVehicleLocations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:       "test vehicle",
+		TrackerID:  "primary",
+		TrackerIDs: []string{"backup"},
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	other := &shuttletracker.Vehicle{
+		Name:      "other vehicle",
+		TrackerID: "other",
+	}
+	err = pg.CreateVehicle(other)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+
+	now := time.Now()
+	for i, trackerID := range []string{"primary", "backup", "primary", "other"} {
+		err = pg.CreateLocation(&shuttletracker.Location{TrackerID: trackerID, Time: now.Add(time.Second * time.Duration(i))})
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	n, err := pg.ClearVehicleLocations(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to clear Locations: %s", err)
+	}
+	if n != 3 {
+		t.Errorf("got %d deleted Locations, expected 3", n)
+	}
+	_, err = pg.LatestLocation(vehicle.ID)
+	if err != shuttletracker.ErrLocationNotFound {
+		t.Errorf("got error %v, expected %v", err, shuttletracker.ErrLocationNotFound)
+	}
+	// the Vehicle and other Vehicles' Locations remain
+	_, err = pg.Vehicle(vehicle.ID)
+	if err != nil {
+		t.Errorf("unable to get Vehicle: %s", err)
+	}
+	_, err = pg.LatestLocation(other.ID)
+	if err != nil {
+		t.Errorf("unable to get other Vehicle's Location: %s", err)
+	}
+
+	_, err = pg.ClearVehicleLocations(other.ID + 100)
+	if err != shuttletracker.ErrVehicleNotFound {
+		t.Errorf("got error %v, expected %v", err, shuttletracker.ErrVehicleNotFound)
+	}
+}
+
+func TestLatestLocations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{Name: "test vehicle", TrackerID: "primary", TrackerIDs: []string{"backup"}}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	unseen := &shuttletracker.Vehicle{Name: "unseen vehicle", TrackerID: "unseen"}
+	err = pg.CreateVehicle(unseen)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+
+	now := time.Now()
+	latest := now.Add(time.Minute)
+	for _, l := range []*shuttletracker.Location{
+		{TrackerID: "primary", Time: now},
+		{TrackerID: "backup", Time: latest},
+		// a late-arriving record is created last but isn't the latest
+		{TrackerID: "primary", Time: now.Add(-time.Minute)},
+	} {
+		err = pg.CreateLocation(l)
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	locations, err := pg.LatestLocations()
+	if err != nil {
+		t.Fatalf("unable to get latest Locations: %s", err)
+	}
+	if len(locations) != 1 {
+		t.Fatalf("got %d Locations, expected 1", len(locations))
+	}
+	location := locations[vehicle.ID]
+	if location == nil || location.TrackerID != "backup" {
+		t.Fatalf("got %+v, expected the backup tracker's Location", location)
+	}
+	if d := location.Time.Sub(latest); d > time.Microsecond || d < -time.Microsecond {
+		t.Errorf("got time %v, expected %v", location.Time, latest)
+	}
+	// LatestLocation agrees
+	single, err := pg.LatestLocation(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to get latest Location: %s", err)
+	}
+	if single.ID != location.ID {
+		t.Errorf("got Location %d, expected %d from LatestLocation", location.ID, single.ID)
+	}
+}
+
+func TestRecentLocations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{Name: "test vehicle", TrackerID: "tracker1"}
+	sparse := &shuttletracker.Vehicle{Name: "sparse vehicle", TrackerID: "tracker2"}
+	for _, v := range []*shuttletracker.Vehicle{vehicle, sparse} {
+		err := pg.CreateVehicle(v)
+		if err != nil {
+			t.Fatalf("unable to create Vehicle: %s", err)
+		}
+	}
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		err := pg.CreateLocation(&shuttletracker.Location{TrackerID: "tracker1", Time: start.Add(time.Minute * time.Duration(i)), Speed: float64(i)})
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+	err := pg.CreateLocation(&shuttletracker.Location{TrackerID: "tracker2", Time: start, Speed: 10})
+	if err != nil {
+		t.Fatalf("unable to create Location: %s", err)
+	}
+
+	locations, err := pg.RecentLocations(vehicle.ID, 3)
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	expected := []float64{4, 3, 2}
+	if len(locations) != len(expected) {
+		t.Fatalf("got %d Locations, expected %d", len(locations), len(expected))
+	}
+	for i, location := range locations {
+		if location.Speed != expected[i] {
+			t.Errorf("got speed %f, expected %f", location.Speed, expected[i])
+		}
+	}
+
+	locations, err = pg.RecentLocations(sparse.ID, 3)
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	if len(locations) != 1 || locations[0].Speed != 10 {
+		t.Errorf("got %+v, expected the sparse vehicle's only Location", locations)
+	}
+
+	locations, err = pg.RecentLocations(vehicle.ID, 0)
+	if err != nil || len(locations) != 0 {
+		t.Errorf("got %d Locations and error %v, expected none", len(locations), err)
+	}
+	_, err = pg.RecentLocations(vehicle.ID, -1)
+	if err == nil {
+		t.Error("expected an error for a negative n")
+	}
+}
+
+func TestEarliestLocation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "test vehicle",
+		Enabled:   false,
+		TrackerID: "tracker1",
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	_, err = pg.EarliestLocation(vehicle.ID, start)
+	if err != shuttletracker.ErrLocationNotFound {
+		t.Errorf("got error %v, expected %v", err, shuttletracker.ErrLocationNotFound)
+	}
+
+	// insert out of order so that creation order differs from tracker time order
+	times := []time.Time{start.Add(time.Minute * 30), start.Add(time.Minute * 10), start.Add(-time.Minute)}
+	for _, locationTime := range times {
+		err = pg.CreateLocation(&shuttletracker.Location{TrackerID: "tracker1", Time: locationTime})
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	earliest, err := pg.EarliestLocation(vehicle.ID, start)
+	if err != nil {
+		t.Fatalf("unable to get earliest Location: %s", err)
+	}
+	if d := earliest.Time.Sub(times[1]); d > time.Microsecond || d < -time.Microsecond {
+		t.Errorf("got time %v, expected %v", earliest.Time, times[1])
+	}
+}
+
+func TestLocationsWithoutRoute(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "test vehicle",
+		Enabled:   false,
+		TrackerID: "tracker1",
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	route := &shuttletracker.Route{Name: "test route", Width: 4, Color: "#ffffff"}
+	err = pg.CreateRoute(route)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	end := start.Add(time.Minute * 30)
+	locations, err := pg.LocationsWithoutRoute(start, end)
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	if locations == nil || len(locations) != 0 {
+		t.Errorf("got %v, expected an empty slice", locations)
+	}
+
+	inWindow := start.Add(time.Minute * 10)
+	err = pg.CreateLocation(&shuttletracker.Location{TrackerID: "tracker1", Time: inWindow})
+	if err != nil {
+		t.Fatalf("unable to create Location: %s", err)
+	}
+	err = pg.CreateLocation(&shuttletracker.Location{TrackerID: "tracker1", Time: start.Add(time.Minute * 20), RouteID: &route.ID})
+	if err != nil {
+		t.Fatalf("unable to create Location: %s", err)
+	}
+	err = pg.CreateLocation(&shuttletracker.Location{TrackerID: "tracker1", Time: end.Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("unable to create Location: %s", err)
+	}
+
+	locations, err = pg.LocationsWithoutRoute(start, end)
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	if len(locations) != 1 {
+		t.Fatalf("got %d Locations, expected 1", len(locations))
+	}
+	if d := locations[0].Time.Sub(inWindow); d > time.Microsecond || d < -time.Microsecond {
+		t.Errorf("got time %v, expected %v", locations[0].Time, inWindow)
+	}
+	if locations[0].VehicleID == nil || *locations[0].VehicleID != vehicle.ID {
+		t.Errorf("got vehicle ID %v, expected %d", locations[0].VehicleID, vehicle.ID)
+	}
+}
+
+// nolint: gocyclo
+func TestSpeedingEvents(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "test vehicle",
+		Enabled:   false,
+		TrackerID: "tracker1",
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	limited := &shuttletracker.Route{Name: "limited", Width: 4, Color: "#ffffff", SpeedLimit: 20}
+	err = pg.CreateRoute(limited)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+	unlimited := &shuttletracker.Route{Name: "unlimited", Width: 4, Color: "#ffffff"}
+	err = pg.CreateRoute(unlimited)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	locations := []*shuttletracker.Location{
+		{TrackerID: "tracker1", Time: start.Add(time.Minute), Speed: 25, RouteID: &limited.ID},
+		{TrackerID: "tracker1", Time: start.Add(time.Minute * 2), Speed: 15, RouteID: &limited.ID},
+		{TrackerID: "tracker1", Time: start.Add(time.Minute * 3), Speed: 40, RouteID: &unlimited.ID},
+		{TrackerID: "tracker1", Time: start.Add(time.Minute * 4), Speed: 40},
+	}
+	for _, location := range locations {
+		err = pg.CreateLocation(location)
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	events, err := pg.SpeedingEvents(start, start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unable to get speeding events: %s", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d speeding events, expected 1", len(events))
+	}
+	if events[0].Location.ID != locations[0].ID {
+		t.Errorf("got Location %d, expected %d", events[0].Location.ID, locations[0].ID)
+	}
+	if events[0].SpeedLimit != limited.SpeedLimit {
+		t.Errorf("got speed limit %f, expected %f", events[0].SpeedLimit, limited.SpeedLimit)
+	}
+}
+
+func TestExistsLocation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	locationTime := time.Now().Truncate(time.Second)
+	exists, err := pg.ExistsLocation("tracker1", locationTime)
+	if err != nil {
+		t.Fatalf("unable to check for Location: %s", err)
+	}
+	if exists {
+		t.Error("got true before creating Location, expected false")
+	}
+
+	err = pg.CreateLocation(&shuttletracker.Location{TrackerID: "tracker1", Time: locationTime})
+	if err != nil {
+		t.Fatalf("unable to create Location: %s", err)
+	}
+	exists, err = pg.ExistsLocation("tracker1", locationTime)
+	if err != nil {
+		t.Fatalf("unable to check for Location: %s", err)
+	}
+	if !exists {
+		t.Error("got false after creating Location, expected true")
+	}
+
+	exists, err = pg.ExistsLocation("tracker2", locationTime)
+	if err != nil {
+		t.Fatalf("unable to check for Location: %s", err)
+	}
+	if exists {
+		t.Error("got true for a different tracker, expected false")
+	}
+}
+
+func TestLocationsBetween(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "test vehicle",
+		Enabled:   false,
+		TrackerID: "tracker1",
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	end := start.Add(time.Minute * 30)
+	times := []time.Time{start.Add(time.Minute * 20), start.Add(-time.Minute), start, end}
+	for _, locationTime := range times {
+		err = pg.CreateLocation(&shuttletracker.Location{TrackerID: "tracker1", Time: locationTime})
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	locations, err := pg.LocationsBetween(vehicle.ID, start, end, shuttletracker.Collapse{})
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	expected := []time.Time{start, start.Add(time.Minute * 20)}
+	if len(locations) != len(expected) {
+		t.Fatalf("got %d Locations, expected %d", len(locations), len(expected))
+	}
+	for i, location := range locations {
+		if d := location.Time.Sub(expected[i]); d > time.Microsecond || d < -time.Microsecond {
+			t.Errorf("got time %v, expected %v", location.Time, expected[i])
+		}
+	}
+}
+
+func TestLocationsDownsampled(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "test vehicle",
+		Enabled:   false,
+		TrackerID: "tracker1",
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	end := start.Add(time.Minute * 5)
+	offsets := []time.Duration{0, time.Second * 20, time.Second * 59, time.Second * 61, time.Minute * 3, time.Minute * 6}
+	for _, offset := range offsets {
+		err = pg.CreateLocation(&shuttletracker.Location{TrackerID: "tracker1", Time: start.Add(offset)})
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	locations, err := pg.LocationsDownsampled(vehicle.ID, start, end, time.Minute)
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	// the first Location in each minute, and the minute without any is skipped
+	expected := []time.Time{start, start.Add(time.Second * 61), start.Add(time.Minute * 3)}
+	if len(locations) != len(expected) {
+		t.Fatalf("got %d Locations, expected %d", len(locations), len(expected))
+	}
+	for i, location := range locations {
+		if d := location.Time.Sub(expected[i]); d > time.Microsecond || d < -time.Microsecond {
+			t.Errorf("got time %v, expected %v", location.Time, expected[i])
+		}
+	}
+
+	_, err = pg.LocationsDownsampled(vehicle.ID, start, end, 0)
+	if err == nil {
+		t.Error("expected an error for an empty bucket")
+	}
+}
+
+func TestSpeedStats(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	since := time.Now().Add(-time.Minute)
+	stats, err := pg.SpeedStats(since)
+	if err != nil {
+		t.Fatalf("unable to get speed stats: %s", err)
+	}
+	if stats.Count != 0 || stats.Mean != 0 || stats.Max != 0 {
+		t.Errorf("got %+v, expected zeros", stats)
+	}
+
+	vehicle := &shuttletracker.Vehicle{Name: "Vehicle", TrackerID: "1234"}
+	err = pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	for i, speed := range []float64{10, 20, 0} {
+		err = pg.CreateLocation(&shuttletracker.Location{TrackerID: "1234", Speed: speed, Time: time.Now().Add(time.Duration(i) * time.Second)})
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+	// locations from unknown trackers are not part of the fleet
+	err = pg.CreateLocation(&shuttletracker.Location{TrackerID: "5678", Speed: 90, Time: time.Now()})
+	if err != nil {
+		t.Fatalf("unable to create Location: %s", err)
+	}
+
+	stats, err = pg.SpeedStats(since)
+	if err != nil {
+		t.Fatalf("unable to get speed stats: %s", err)
+	}
+	if stats.Count != 3 || stats.Mean != 10 || stats.Max != 20 {
+		t.Errorf("got %+v, expected 3 Locations with mean 10 and max 20", stats)
+	}
+}
+
+func TestLocationsForTrip(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	tripID, err := pg.NewTripID()
+	if err != nil {
+		t.Fatalf("unable to reserve trip ID: %s", err)
+	}
+	otherTripID, err := pg.NewTripID()
+	if err != nil {
+		t.Fatalf("unable to reserve trip ID: %s", err)
+	}
+	if otherTripID == tripID {
+		t.Fatalf("got trip ID %d twice", tripID)
+	}
+
+	now := time.Now()
+	locations := []*shuttletracker.Location{
+		{TrackerID: "1234", Time: now.Add(time.Second), TripID: &tripID},
+		{TrackerID: "1234", Time: now, TripID: &tripID},
+		{TrackerID: "1234", Time: now.Add(time.Second * 2), TripID: &otherTripID},
+		{TrackerID: "1234", Time: now.Add(time.Second * 3)},
+	}
+	for _, l := range locations {
+		err = pg.CreateLocation(l)
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	trip, err := pg.LocationsForTrip(tripID)
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	if len(trip) != 2 || trip[0].ID != locations[1].ID || trip[1].ID != locations[0].ID {
+		t.Fatalf("got %+v, expected the trip's two Locations oldest first", trip)
+	}
+	if trip[0].TripID == nil || *trip[0].TripID != tripID {
+		t.Errorf("got trip ID %v, expected %d", trip[0].TripID, tripID)
+	}
+}
+
+func TestLocationHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "test vehicle",
+		Enabled:   false,
+		TrackerID: "tracker1",
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	route := &shuttletracker.Route{Name: "test route"}
+	err = pg.CreateRoute(route)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 10; i++ {
+		location := &shuttletracker.Location{TrackerID: "tracker1", Time: start.Add(time.Minute * time.Duration(i)), Speed: float64(i)}
+		if i%2 == 0 {
+			location.RouteID = &route.ID
+		}
+		err = pg.CreateLocation(location)
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	onRoute := true
+	locations, total, err := pg.LocationHistory(shuttletracker.LocationQuery{
+		VehicleID: vehicle.ID,
+		Start:     start.Add(time.Minute),
+		OnRoute:   &onRoute,
+		MinSpeed:  3,
+		Limit:     2,
+		Offset:    1,
+	})
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	// on-route Locations at or after the fourth minute are 4, 6, and 8, newest first
+	if total != 3 {
+		t.Errorf("got total %d, expected 3", total)
+	}
+	expected := []float64{6, 4}
+	if len(locations) != len(expected) {
+		t.Fatalf("got %d Locations, expected %d", len(locations), len(expected))
+	}
+	for i, location := range locations {
+		if location.Speed != expected[i] {
+			t.Errorf("got speed %f, expected %f", location.Speed, expected[i])
+		}
+	}
+
+	offRoute := false
+	locations, total, err = pg.LocationHistory(shuttletracker.LocationQuery{VehicleID: vehicle.ID, OnRoute: &offRoute})
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	if total != 5 || len(locations) != 5 {
+		t.Errorf("got %d of %d Locations, expected all 5 off-route Locations", len(locations), total)
+	}
+
+	_, _, err = pg.LocationHistory(shuttletracker.LocationQuery{VehicleID: vehicle.ID, Offset: -1})
+	if err == nil {
+		t.Error("expected an error for a negative offset")
+	}
+	_, _, err = pg.LocationHistory(shuttletracker.LocationQuery{VehicleID: vehicle.ID, Collapse: shuttletracker.Collapse{Distance: -1}})
+	if err == nil {
+		t.Error("expected an error for a negative collapse distance")
+	}
+}
+
+func TestLocationHistoryCollapse(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "test vehicle",
+		Enabled:   false,
+		TrackerID: "tracker1",
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+
+	// idle for the first four minutes, then move about 100 meters each minute
+	start := time.Now().Add(-time.Hour)
+	latitudes := []float64{42.73, 42.73, 42.73, 42.73, 42.731, 42.732}
+	for i, latitude := range latitudes {
+		location := &shuttletracker.Location{
+			TrackerID: "tracker1",
+			Latitude:  latitude,
+			Longitude: -73.68,
+			Time:      start.Add(time.Minute * time.Duration(i)),
+			Speed:     float64(i),
+		}
+		err = pg.CreateLocation(location)
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+	collapse := shuttletracker.Collapse{Distance: 10}
+
+	locations, total, err := pg.LocationHistory(shuttletracker.LocationQuery{
+		VehicleID: vehicle.ID,
+		Limit:     2,
+		Offset:    1,
+		Collapse:  collapse,
+	})
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	// collapsed Locations are 5, 4, and 0, newest first
+	if total != 3 {
+		t.Errorf("got total %d, expected 3", total)
+	}
+	expected := []float64{4, 0}
+	if len(locations) != len(expected) {
+		t.Fatalf("got %d Locations, expected %d", len(locations), len(expected))
+	}
+	for i, location := range locations {
+		if location.Speed != expected[i] {
+			t.Errorf("got speed %f, expected %f", location.Speed, expected[i])
+		}
+	}
+
+	locations, err = pg.LocationsBetween(vehicle.ID, start, start.Add(time.Hour), collapse)
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	expected = []float64{0, 4, 5}
+	if len(locations) != len(expected) {
+		t.Fatalf("got %d Locations, expected %d", len(locations), len(expected))
+	}
+	for i, location := range locations {
+		if location.Speed != expected[i] {
+			t.Errorf("got speed %f, expected %f", location.Speed, expected[i])
+		}
+	}
+
+	_, err = pg.LocationsBetween(vehicle.ID, start, start.Add(time.Hour), shuttletracker.Collapse{Within: -time.Second})
+	if err == nil {
+		t.Error("expected an error for a negative collapse time")
+	}
+}
+
+func TestCreateHistoricalLocations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "test vehicle",
+		Enabled:   false,
+		TrackerID: "tracker1",
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+
+	start := time.Now().AddDate(0, 0, -1)
+	locations := []*shuttletracker.Location{
+		{TrackerID: "tracker1", Time: start},
+		{TrackerID: "tracker1", Time: start.Add(time.Minute)},
+		// a duplicate of the first Location
+		{TrackerID: "tracker1", Time: start},
+	}
+	err = pg.CreateHistoricalLocations(locations)
+	if err != nil {
+		t.Fatalf("unable to create Locations: %s", err)
+	}
+	if locations[0].ID == 0 || locations[1].ID == 0 || locations[2].ID != 0 {
+		t.Errorf("got IDs %d, %d, and %d, expected only the duplicate to be skipped", locations[0].ID, locations[1].ID, locations[2].ID)
+	}
+
+	latest, err := pg.LatestLocation(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to get latest Location: %s", err)
+	}
+	if latest.ID != locations[1].ID {
+		t.Errorf("got latest Location %d, expected %d", latest.ID, locations[1].ID)
+	}
+	if d := latest.Created.Sub(locations[1].Time); d > time.Microsecond || d < -time.Microsecond {
+		t.Errorf("got created %v, expected the Location's time %v", latest.Created, locations[1].Time)
+	}
+}
+
+func TestLocationTimesUTC(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "test vehicle",
+		Enabled:   false,
+		TrackerID: "tracker1",
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+
+	// a time parsed from a feed in another time zone comes back as the same instant in UTC
+	reported := time.Now().Truncate(time.Second).In(time.FixedZone("EST", -5*60*60))
+	location := &shuttletracker.Location{TrackerID: "tracker1", Time: reported}
+	err = pg.CreateLocation(location)
+	if err != nil {
+		t.Fatalf("unable to create Location: %s", err)
+	}
+	if location.Created.Location() != time.UTC {
+		t.Errorf("got created %v, expected UTC", location.Created)
+	}
+
+	actual, err := pg.LatestLocation(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to get latest Location: %s", err)
+	}
+	if !actual.Time.Equal(reported) || actual.Time.Location() != time.UTC {
+		t.Errorf("got time %v, expected %v in UTC", actual.Time, reported)
+	}
+	if actual.Created.Location() != time.UTC {
+		t.Errorf("got created %v, expected UTC", actual.Created)
+	}
+}
@@ -32,7 +32,7 @@ func (ms *MessageService) Message() (*shuttletracker.Message, error) {
 	query := "SELECT message, enabled, created, updated FROM messages;"
 	row := ms.db.QueryRow(query)
 	message := &shuttletracker.Message{}
-	err := row.Scan(&message.Message, &message.Enabled, &message.Created, &message.Updated)
+	err := row.Scan(&message.Message, &message.Enabled, scanUTC{&message.Created}, scanUTC{&message.Updated})
 	if err == sql.ErrNoRows {
 		return nil, shuttletracker.ErrMessageNotFound
 	} else if err != nil {
@@ -47,5 +47,5 @@ func (ms *MessageService) SetMessage(message *shuttletracker.Message) error {
 		" ON CONFLICT (id) DO UPDATE SET message = excluded.message, enabled = excluded.enabled, updated = excluded.updated" +
 		" RETURNING created, updated;"
 	row := ms.db.QueryRow(statement, message.Message, message.Enabled)
-	return row.Scan(&message.Created, &message.Updated)
+	return row.Scan(scanUTC{&message.Created}, scanUTC{&message.Updated})
 }
@@ -2,8 +2,11 @@ package postgres
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/wtg/shuttletracker/log"
 )
 
 /*
@@ -18,13 +21,51 @@ type Postgres struct {
 	LocationService
 	MessageService
 	UserService
+
+	db      *sql.DB
+	replica *sql.DB
+}
+
+// rowQuerier is implemented by both *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 // Config contains database connection information.
 type Config struct {
 	URL string
+
+	// MaxOpenConns limits the number of connections open to the database at once, so that the
+	// updater's concurrent record processing waits for a connection instead of opening one per record.
+	// Zero means no limit.
+	MaxOpenConns int
+
+	// MaxIdleConns is the number of idle connections kept for reuse. Zero keeps database/sql's default.
+	MaxIdleConns int
+
+	// ConnMaxLifetime is how long a connection may be reused before it is closed, e.g. "30m".
+	// Empty means connections are reused forever.
+	ConnMaxLifetime string
+
+	// StartupWait is how long New keeps retrying to reach the database before giving up, e.g. "2m" for
+	// deployments where the database may start after shuttletracker. Empty means New fails right away.
+	StartupWait string
+
+	// ReplicaURL is the connection string of a read-only replica that the Postgres returned by
+	// ReadReplica sends lists of Vehicles and queries of Locations to, so that API and reporting
+	// queries don't compete with the updater's writes. Empty makes ReadReplica use URL. The replica may
+	// lag behind the primary, so its reads may not yet include what was just stored. It uses the same
+	// pool settings as the primary.
+	ReplicaURL string
 }
 
+const (
+	// minPingRetry and maxPingRetry bound the delay between attempts to reach the database at startup.
+	// The delay doubles after each failed attempt.
+	minPingRetry = time.Millisecond * 250
+	maxPingRetry = time.Second * 5
+)
+
 // New returns a configured Postgres.
 func New(cfg Config) (*Postgres, error) {
 	db, err := sql.Open("postgres", cfg.URL)
@@ -32,12 +73,24 @@ func New(cfg Config) (*Postgres, error) {
 		return nil, err
 	}
 
-	err = db.Ping()
+	err = configurePool(db, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var wait time.Duration
+	if cfg.StartupWait != "" {
+		wait, err = time.ParseDuration(cfg.StartupWait)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = waitForDatabase(db.Ping, wait)
 	if err != nil {
 		return nil, err
 	}
 
-	pg := &Postgres{}
+	pg := &Postgres{db: db}
 
 	// Initializes every table by calling initialize on all go structures
 	err = pg.VehicleService.initializeSchema(db)
@@ -64,16 +117,105 @@ func New(cfg Config) (*Postgres, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if cfg.ReplicaURL != "" {
+		replica, err := sql.Open("postgres", cfg.ReplicaURL)
+		if err != nil {
+			return nil, err
+		}
+		err = configurePool(replica, cfg)
+		if err != nil {
+			return nil, err
+		}
+		err = waitForDatabase(replica.Ping, wait)
+		if err != nil {
+			return nil, err
+		}
+		pg.replica = replica
+	}
+
 	// The nil represents the error
 	return pg, nil
 }
 
+// ReadReplica returns a Postgres that reads lists of Vehicles and queries of Locations from the
+// replica set by Config.ReplicaURL. Everything else, including writes and reads of a single Vehicle,
+// still goes to the primary. It is meant for callers like the API that can tolerate replication lag;
+// anything that stores data derived from what it reads, like the updater, should use pg itself.
+// Without a replica, pg is returned.
+func (pg *Postgres) ReadReplica() *Postgres {
+	if pg.replica == nil {
+		return pg
+	}
+	replica := *pg
+	replica.VehicleService.read = pg.replica
+	replica.LocationService.read = pg.replica
+	return &replica
+}
+
+// waitForDatabase calls ping until it succeeds or wait has passed, and returns the last error. Each
+// failed attempt is logged along with how much longer it will keep trying.
+func waitForDatabase(ping func() error, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	delay := minPingRetry
+	for {
+		err := ping()
+		if err == nil {
+			return nil
+		}
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			return err
+		}
+		if delay > remaining {
+			delay = remaining
+		}
+		log.WithError(err).WithField("remaining", remaining).Warnf("Unable to reach database; retrying in %s.", delay)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxPingRetry {
+			delay = maxPingRetry
+		}
+	}
+}
+
+// configurePool applies the connection pool settings from cfg to db.
+func configurePool(db *sql.DB, cfg Config) error {
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime != "" {
+		lifetime, err := time.ParseDuration(cfg.ConnMaxLifetime)
+		if err != nil {
+			return err
+		}
+		db.SetConnMaxLifetime(lifetime)
+	}
+	return nil
+}
+
+// Stats returns statistics about the database connection pool.
+func (pg *Postgres) Stats() sql.DBStats {
+	return pg.db.Stats()
+}
+
 // NewConfig creates a new Config.
 func NewConfig(v *viper.Viper) (*Config, error) {
 	cfg := &Config{
-		URL: "postgres://localhost/shuttletracker?sslmode=disable",
+		URL:             "postgres://localhost/shuttletracker?sslmode=disable",
+		MaxOpenConns:    20,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: "30m",
 	}
 	v.SetDefault("postgres.url", cfg.URL)
+	v.SetDefault("postgres.maxopenconns", cfg.MaxOpenConns)
+	v.SetDefault("postgres.maxidleconns", cfg.MaxIdleConns)
+	v.SetDefault("postgres.connmaxlifetime", cfg.ConnMaxLifetime)
+	v.SetDefault("postgres.startupwait", cfg.StartupWait)
+	v.SetDefault("postgres.replicaurl", cfg.ReplicaURL)
 
 	// Allow DATABASE_URL to set the Postgres connection string for ease of deployment.
 	err := v.BindEnv("postgres.url", "DATABASE_URL")
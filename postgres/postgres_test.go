@@ -1,10 +1,15 @@
 package postgres
 
 import (
+	"database/sql"
+	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/wtg/shuttletracker"
 )
 
 func TestConfigDatabaseURL(t *testing.T) {
@@ -34,3 +39,111 @@ func TestConfigDatabaseURL(t *testing.T) {
 		t.Errorf("URL is %s; expected %s", cfg.URL, testVal)
 	}
 }
+
+func TestConfigurePool(t *testing.T) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer db.Close()
+
+	err = configurePool(db, Config{MaxOpenConns: 10, MaxIdleConns: 2, ConnMaxLifetime: "5m"})
+	if err != nil {
+		t.Error("unexpected error:", err)
+	}
+	err = configurePool(db, Config{ConnMaxLifetime: "forever"})
+	if err == nil {
+		t.Error("expected an error for an invalid connection lifetime")
+	}
+}
+
+func TestWaitForDatabase(t *testing.T) {
+	errUnreachable := errors.New("unreachable")
+
+	// the database becomes reachable on the third attempt
+	attempts := 0
+	ping := func() error {
+		attempts++
+		if attempts < 3 {
+			return errUnreachable
+		}
+		return nil
+	}
+	err := waitForDatabase(ping, time.Second*5)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if attempts != 3 {
+		t.Errorf("got %d attempts, expected 3", attempts)
+	}
+
+	// without a wait, the first failure is returned
+	attempts = 0
+	err = waitForDatabase(func() error {
+		attempts++
+		return errUnreachable
+	}, 0)
+	if err != errUnreachable || attempts != 1 {
+		t.Errorf("got error %v after %d attempts, expected a single failed attempt", err, attempts)
+	}
+
+	// attempts stop once the wait has passed
+	start := time.Now()
+	err = waitForDatabase(func() error { return errUnreachable }, time.Millisecond*600)
+	if err != errUnreachable {
+		t.Errorf("got error %v, expected the ping error", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second*2 {
+		t.Errorf("waited %s, expected to give up after about 600ms", elapsed)
+	}
+}
+
+func TestReplica(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	// the test database stands in for its own replica
+	pg, err := New(Config{URL: url, ReplicaURL: url})
+	if err != nil {
+		t.Fatalf("unable to create Postgres: %s", err)
+	}
+	// the primary Postgres keeps reading from the primary
+	if pg.VehicleService.read != pg.VehicleService.db || pg.LocationService.read != pg.LocationService.db {
+		t.Fatal("expected the primary Postgres to read from the primary")
+	}
+	replica := pg.ReadReplica()
+	if replica.VehicleService.read == replica.VehicleService.db || replica.LocationService.read == replica.LocationService.db {
+		t.Fatal("expected reads to use a separate connection")
+	}
+
+	vehicle := &shuttletracker.Vehicle{Name: "Vehicle 1", Enabled: true, TrackerID: "1"}
+	err = pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	vehicles, err := replica.Vehicles()
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	if len(vehicles) != 1 || vehicles[0].ID != vehicle.ID {
+		t.Errorf("got %+v, expected the created Vehicle", vehicles)
+	}
+
+	// without a replica, the Postgres itself is used
+	primary, err := New(Config{URL: url})
+	if err != nil {
+		t.Fatalf("unable to create Postgres: %s", err)
+	}
+	if primary.ReadReplica() != primary {
+		t.Error("expected ReadReplica to return the Postgres without a replica")
+	}
+
+	_, err = New(Config{URL: url, ReplicaURL: "postgres://localhost:1/unreachable?sslmode=disable"})
+	if err == nil {
+		t.Error("expected an error for an unreachable replica")
+	}
+}
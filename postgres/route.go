@@ -6,8 +6,10 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/lib/pq"
 	"github.com/wtg/shuttletracker"
@@ -35,6 +37,9 @@ CREATE TABLE IF NOT EXISTS routes (
 	color varchar(9) NOT NULL DEFAULT '#ffffff',
 	points path
 );
+ALTER TABLE routes ADD COLUMN IF NOT EXISTS speed_limit real NOT NULL DEFAULT 0;
+ALTER TABLE routes ADD COLUMN IF NOT EXISTS elevations double precision[];
+ALTER TABLE routes ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
 CREATE TABLE IF NOT EXISTS routes_stops (
 	id serial PRIMARY KEY,
 	route_id integer REFERENCES routes ON DELETE CASCADE NOT NULL,
@@ -42,6 +47,30 @@ CREATE TABLE IF NOT EXISTS routes_stops (
 	"order" integer NOT NULL,
 	UNIQUE (route_id, "order")
 );
+CREATE TABLE IF NOT EXISTS route_segments (
+	id serial PRIMARY KEY,
+	route_id integer REFERENCES routes ON DELETE CASCADE NOT NULL,
+	name text NOT NULL,
+	points path
+);
+CREATE TABLE IF NOT EXISTS vehicle_routes (
+	vehicle_id integer PRIMARY KEY REFERENCES vehicles ON DELETE CASCADE,
+	route_id integer REFERENCES routes ON DELETE SET NULL,
+	confidence double precision NOT NULL DEFAULT 0,
+	updated timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS vehicle_scheduled_routes (
+	vehicle_id integer PRIMARY KEY REFERENCES vehicles ON DELETE CASCADE,
+	route_id integer REFERENCES routes ON DELETE CASCADE NOT NULL,
+	updated timestamp with time zone NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS route_timetables (
+	id serial PRIMARY KEY,
+	route_id integer REFERENCES routes ON DELETE CASCADE NOT NULL,
+	stop_id integer REFERENCES stops ON DELETE CASCADE NOT NULL,
+	day smallint NOT NULL CHECK (day >= 0 AND day < 7),
+	time time NOT NULL
+);
 CREATE TABLE IF NOT EXISTS route_schedules (
 	id serial PRIMARY KEY,
 	route_id integer REFERENCES routes ON DELETE CASCADE NOT NULL,
@@ -126,9 +155,11 @@ func (p *scanPoints) Scan(src interface{}) error {
 		if err != nil {
 			return err
 		}
+		index := len(p.points)
 		point := shuttletracker.Point{
 			Latitude:  lat,
 			Longitude: lon,
+			Index:     &index,
 		}
 		p.points = append(p.points, point)
 	}
@@ -151,7 +182,7 @@ func (rs *RouteService) Routes() ([]*shuttletracker.Route, error) {
 	idsToRoute := map[int64]*shuttletracker.Route{}
 
 	query := `
-SELECT r.id, r.name, r.created, r.updated, r.enabled, r.width, r.color, r.points,
+SELECT r.id, r.name, r.created, r.updated, r.enabled, r.width, r.color, r.points, r.elevations, r.speed_limit, r.version,
 	array_remove(array_agg(rs.stop_id ORDER BY rs.order ASC), NULL) as stop_ids,
 	route_is_active(r.id) as active
 FROM
@@ -166,12 +197,14 @@ GROUP BY r.id;
 	for rows.Next() {
 		r := &shuttletracker.Route{}
 		p := scanPoints{}
-		err = rows.Scan(&r.ID, &r.Name, &r.Created, &r.Updated, &r.Enabled, &r.Width, &r.Color, &p, pq.Array(&r.StopIDs), &r.Active)
+		elevations := []sql.NullFloat64{}
+		err = rows.Scan(&r.ID, &r.Name, scanUTC{&r.Created}, scanUTC{&r.Updated}, &r.Enabled, &r.Width, &r.Color, &p, pq.Array(&elevations), &r.SpeedLimit, &r.Version, pq.Array(&r.StopIDs), &r.Active)
 		if err != nil {
 			return nil, err
 		}
-		r.Points = p.points
+		r.Points = withElevations(p.points, elevations)
 		r.Schedule = shuttletracker.RouteSchedule{}
+		r.Branches = []shuttletracker.RouteBranch{}
 		routes = append(routes, r)
 		idsToRoute[r.ID] = r
 	}
@@ -194,6 +227,27 @@ GROUP BY r.id;
 		route.Schedule = append(route.Schedule, interval)
 	}
 
+	query = "SELECT b.id, b.route_id, b.name, b.points FROM route_segments b ORDER BY b.id;"
+	rows, err = tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		branch := shuttletracker.RouteBranch{}
+		var routeID int64
+		p := scanPoints{}
+		err = rows.Scan(&branch.ID, &routeID, &branch.Name, &p)
+		if err != nil {
+			return nil, err
+		}
+		branch.Points = p.points
+		route, ok := idsToRoute[routeID]
+		if !ok {
+			return nil, shuttletracker.ErrRouteNotFound
+		}
+		route.Branches = append(route.Branches, branch)
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
@@ -201,6 +255,68 @@ GROUP BY r.id;
 	return routes, nil
 }
 
+// RoutesForStop returns all Routes that include the Stop with the provided ID.
+func (rs *RouteService) RoutesForStop(stopID int64) ([]*shuttletracker.Route, error) {
+	routes, err := rs.Routes()
+	if err != nil {
+		return nil, err
+	}
+
+	served := []*shuttletracker.Route{}
+	for _, route := range routes {
+		for _, id := range route.StopIDs {
+			if id == stopID {
+				served = append(served, route)
+				break
+			}
+		}
+	}
+	return served, nil
+}
+
+// RoutesWithVehicleCounts returns all Routes along with the number of enabled vehicles currently on
+// each, in a single query instead of one per Route. A vehicle is on the Route of its latest Location if
+// that Location's tracker time is within the provided duration of now. The Routes don't include their
+// schedules or branches.
+func (rs *RouteService) RoutesWithVehicleCounts(within time.Duration) ([]shuttletracker.RouteWithCount, error) {
+	query := `
+SELECT r.id, r.name, r.created, r.updated, r.enabled, r.width, r.color, r.points, r.elevations, r.speed_limit, r.version,
+	ARRAY(SELECT rs.stop_id FROM routes_stops rs WHERE rs.route_id = r.id ORDER BY rs.order ASC) as stop_ids,
+	route_is_active(r.id) as active,
+	count(latest.vehicle_id)
+FROM routes r
+LEFT JOIN (
+	SELECT DISTINCT ON (t.vehicle_id) t.vehicle_id, l.route_id
+	FROM locations l
+	JOIN vehicle_trackers t ON l.tracker_id = t.tracker_id
+	JOIN vehicles v ON v.id = t.vehicle_id
+	WHERE v.enabled = true AND l.time > $1
+	ORDER BY t.vehicle_id, l.time DESC
+) latest ON latest.route_id = r.id
+GROUP BY r.id
+ORDER BY r.id;`
+	rows, err := rs.db.Query(query, time.Now().Add(-within))
+	if err != nil {
+		return nil, err
+	}
+	withCounts := []shuttletracker.RouteWithCount{}
+	for rows.Next() {
+		r := &shuttletracker.Route{}
+		p := scanPoints{}
+		elevations := []sql.NullFloat64{}
+		var count int
+		err = rows.Scan(&r.ID, &r.Name, scanUTC{&r.Created}, scanUTC{&r.Updated}, &r.Enabled, &r.Width, &r.Color, &p, pq.Array(&elevations), &r.SpeedLimit, &r.Version, pq.Array(&r.StopIDs), &r.Active, &count)
+		if err != nil {
+			return nil, err
+		}
+		r.Points = withElevations(p.points, elevations)
+		r.Schedule = shuttletracker.RouteSchedule{}
+		r.Branches = []shuttletracker.RouteBranch{}
+		withCounts = append(withCounts, shuttletracker.RouteWithCount{Route: r, VehicleCount: count})
+	}
+	return withCounts, nil
+}
+
 // Route returns the Route with the provided ID.
 func (rs *RouteService) Route(id int64) (*shuttletracker.Route, error) {
 	tx, err := rs.db.Begin()
@@ -211,7 +327,7 @@ func (rs *RouteService) Route(id int64) (*shuttletracker.Route, error) {
 	// nolint: errcheck
 	defer tx.Rollback()
 
-	query := "SELECT r.name, r.created, r.updated, r.enabled, r.width, r.color, r.points," +
+	query := "SELECT r.name, r.created, r.updated, r.enabled, r.width, r.color, r.points, r.elevations, r.speed_limit, r.version," +
 		" array_remove(array_agg(rs.stop_id ORDER BY rs.order ASC), NULL) as stop_ids," +
 		" route_is_active(r.id) as active" +
 		" FROM routes r LEFT JOIN routes_stops rs" +
@@ -222,11 +338,12 @@ func (rs *RouteService) Route(id int64) (*shuttletracker.Route, error) {
 		Schedule: shuttletracker.RouteSchedule{},
 	}
 	p := scanPoints{}
-	err = row.Scan(&r.Name, &r.Created, &r.Updated, &r.Enabled, &r.Width, &r.Color, &p, pq.Array(&r.StopIDs), &r.Active)
+	elevations := []sql.NullFloat64{}
+	err = row.Scan(&r.Name, scanUTC{&r.Created}, scanUTC{&r.Updated}, &r.Enabled, &r.Width, &r.Color, &p, pq.Array(&elevations), &r.SpeedLimit, &r.Version, pq.Array(&r.StopIDs), &r.Active)
 	if err != nil {
 		return nil, err
 	}
-	r.Points = p.points
+	r.Points = withElevations(p.points, elevations)
 
 	query = "SELECT s.id, s.start_day, s.start_time, s.end_day, s.end_time" +
 		" FROM route_schedules s WHERE s.route_id = $1;"
@@ -245,6 +362,11 @@ func (rs *RouteService) Route(id int64) (*shuttletracker.Route, error) {
 		r.Schedule = append(r.Schedule, interval)
 	}
 
+	r.Branches, err = routeBranches(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return nil, err
@@ -252,6 +374,45 @@ func (rs *RouteService) Route(id int64) (*shuttletracker.Route, error) {
 	return r, nil
 }
 
+// routeBranches returns the Branches of the Route with the provided ID in the order they were created.
+func routeBranches(tx *sql.Tx, routeID int64) ([]shuttletracker.RouteBranch, error) {
+	branches := []shuttletracker.RouteBranch{}
+	query := "SELECT b.id, b.name, b.points FROM route_segments b WHERE b.route_id = $1 ORDER BY b.id;"
+	rows, err := tx.Query(query, routeID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		branch := shuttletracker.RouteBranch{}
+		p := scanPoints{}
+		err = rows.Scan(&branch.ID, &branch.Name, &p)
+		if err != nil {
+			return nil, err
+		}
+		branch.Points = p.points
+		branches = append(branches, branch)
+	}
+	return branches, nil
+}
+
+// setBranches replaces the stored Branches of a Route with its current ones and sets their IDs.
+func setBranches(tx *sql.Tx, route *shuttletracker.Route) error {
+	_, err := tx.Exec("DELETE FROM route_segments WHERE route_id = $1;", route.ID)
+	if err != nil {
+		return err
+	}
+	for i := range route.Branches {
+		branch := &route.Branches[i]
+		statement := "INSERT INTO route_segments (route_id, name, points) VALUES ($1, $2, $3) RETURNING id;"
+		row := tx.QueryRow(statement, route.ID, branch.Name, valuePoints(branch.Points))
+		err = row.Scan(&branch.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // TODO: document this
 type valuePoints []shuttletracker.Point
 
@@ -286,6 +447,77 @@ func (p valuePoints) Value() (driver.Value, error) {
 	return buf.Bytes(), nil
 }
 
+// valueElevations stores the elevations of a Route's points as an array parallel to its path.
+type valueElevations []shuttletracker.Point
+
+// Value returns NULL if no point has an elevation, so Routes without elevation data stay as they were.
+func (p valueElevations) Value() (driver.Value, error) {
+	elevations := make([]sql.NullFloat64, len(p))
+	known := false
+	for i, point := range p {
+		if point.Elevation != nil {
+			elevations[i] = sql.NullFloat64{Float64: *point.Elevation, Valid: true}
+			known = true
+		}
+	}
+	if !known {
+		return nil, nil
+	}
+	return pq.GenericArray{A: elevations}.Value()
+}
+
+// withElevations sets the elevation of each point from the corresponding element of elevations.
+// Points without one, including every point of a Route stored before elevations were, have none.
+func withElevations(points []shuttletracker.Point, elevations []sql.NullFloat64) []shuttletracker.Point {
+	for i := range points {
+		if i < len(elevations) && elevations[i].Valid {
+			elevation := elevations[i].Float64
+			points[i].Elevation = &elevation
+		}
+	}
+	return points
+}
+
+// coordinateTolerance is how far apart in degrees two coordinates may be and still be the same.
+// valuePoints stores coordinates with six decimal places, so a stored Route's coordinates may differ
+// from the ones it was saved with by up to half of a millionth of a degree, and comparing them exactly
+// would find a change in every Route that is saved again unmodified. A millionth of a degree is about
+// 11 centimeters.
+const coordinateTolerance = 0.000001
+
+// sameGeometry returns whether two polylines have the same coordinates, within coordinateTolerance,
+// and the same elevations.
+func sameGeometry(a, b []shuttletracker.Point) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i].Latitude-b[i].Latitude) > coordinateTolerance || math.Abs(a[i].Longitude-b[i].Longitude) > coordinateTolerance {
+			return false
+		}
+		if (a[i].Elevation == nil) != (b[i].Elevation == nil) {
+			return false
+		}
+		if a[i].Elevation != nil && *a[i].Elevation != *b[i].Elevation {
+			return false
+		}
+	}
+	return true
+}
+
+// sameBranches returns whether two sets of Branches have the same names and geometry in the same order.
+func sameBranches(a, b []shuttletracker.RouteBranch) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || !sameGeometry(a[i].Points, b[i].Points) {
+			return false
+		}
+	}
+	return true
+}
+
 // CreateRoute creates a Route.
 func (rs *RouteService) CreateRoute(route *shuttletracker.Route) error {
 	tx, err := rs.db.Begin()
@@ -296,11 +528,20 @@ func (rs *RouteService) CreateRoute(route *shuttletracker.Route) error {
 	// nolint: errcheck
 	defer tx.Rollback()
 
+	err = createRoute(tx, route)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createRoute creates a Route along with its stop ordering, schedule, and branches within tx.
+func createRoute(tx *sql.Tx, route *shuttletracker.Route) error {
 	// insert route
-	statement := "INSERT INTO routes (name, enabled, width, color, points)" +
-		" VALUES ($1, $2, $3, $4, $5) RETURNING id, created, updated;"
-	row := tx.QueryRow(statement, route.Name, route.Enabled, route.Width, route.Color, valuePoints(route.Points))
-	err = row.Scan(&route.ID, &route.Created, &route.Updated)
+	statement := "INSERT INTO routes (name, enabled, width, color, points, elevations, speed_limit)" +
+		" VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created, updated, version;"
+	row := tx.QueryRow(statement, route.Name, route.Enabled, route.Width, route.Color, valuePoints(route.Points), valueElevations(route.Points), route.SpeedLimit)
+	err := row.Scan(&route.ID, scanUTC{&route.Created}, scanUTC{&route.Updated}, &route.Version)
 	if err != nil {
 		return err
 	}
@@ -326,14 +567,14 @@ func (rs *RouteService) CreateRoute(route *shuttletracker.Route) error {
 		interval.RouteID = route.ID
 	}
 
-	// Determine if route is active. Must happen after inserting the route schedule.
-	row = tx.QueryRow("SELECT route_is_active($1);", route.ID)
-	err = row.Scan(&route.Active)
+	err = setBranches(tx, route)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	// Determine if route is active. Must happen after inserting the route schedule.
+	row = tx.QueryRow("SELECT route_is_active($1);", route.ID)
+	return row.Scan(&route.Active)
 }
 
 // DeleteRoute deletes a Route.
@@ -365,11 +606,27 @@ func (rs *RouteService) ModifyRoute(route *shuttletracker.Route) error {
 	// nolint: errcheck
 	defer tx.Rollback()
 
+	// Locations matched against the old geometry keep referring to the old version.
+	p := scanPoints{}
+	elevations := []sql.NullFloat64{}
+	row := tx.QueryRow("SELECT points, elevations, version FROM routes WHERE id = $1 FOR UPDATE;", route.ID)
+	err = row.Scan(&p, pq.Array(&elevations), &route.Version)
+	if err != nil {
+		return err
+	}
+	branches, err := routeBranches(tx, route.ID)
+	if err != nil {
+		return err
+	}
+	if !sameGeometry(withElevations(p.points, elevations), route.Points) || !sameBranches(branches, route.Branches) {
+		route.Version++
+	}
+
 	// update route
-	statement := "UPDATE routes SET name = $1, enabled = $2, width = $3, color = $4, points = $5, updated = now()" +
-		" WHERE id = $6 RETURNING updated;"
-	row := tx.QueryRow(statement, route.Name, route.Enabled, route.Width, route.Color, valuePoints(route.Points), route.ID)
-	err = row.Scan(&route.Updated)
+	statement := "UPDATE routes SET name = $1, enabled = $2, width = $3, color = $4, points = $5, elevations = $6, speed_limit = $7, version = $8, updated = now()" +
+		" WHERE id = $9 RETURNING updated;"
+	row = tx.QueryRow(statement, route.Name, route.Enabled, route.Width, route.Color, valuePoints(route.Points), valueElevations(route.Points), route.SpeedLimit, route.Version, route.ID)
+	err = row.Scan(scanUTC{&route.Updated})
 	if err != nil {
 		return err
 	}
@@ -407,5 +664,119 @@ func (rs *RouteService) ModifyRoute(route *shuttletracker.Route) error {
 		interval.RouteID = route.ID
 	}
 
+	err = setBranches(tx, route)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CurrentRoute returns the Route that a Vehicle was most recently recorded to be on. It returns nil if
+// the Vehicle is not on a Route or nothing has been recorded for it.
+func (rs *RouteService) CurrentRoute(vehicleID int64) (*shuttletracker.Route, error) {
+	var routeID sql.NullInt64
+	query := "SELECT route_id FROM vehicle_routes WHERE vehicle_id = $1;"
+	err := rs.db.QueryRow(query, vehicleID).Scan(&routeID)
+	if err == sql.ErrNoRows || (err == nil && !routeID.Valid) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return rs.Route(routeID.Int64)
+}
+
+// SetCurrentRoute records the Route that a Vehicle is on along with the confidence of the guess.
+// A nil routeID records that the Vehicle is not on any Route.
+func (rs *RouteService) SetCurrentRoute(vehicleID int64, routeID *int64, confidence float64) error {
+	query := "INSERT INTO vehicle_routes (vehicle_id, route_id, confidence, updated) VALUES ($1, $2, $3, now())" +
+		" ON CONFLICT (vehicle_id) DO UPDATE SET route_id = excluded.route_id," +
+		" confidence = excluded.confidence, updated = excluded.updated;"
+	_, err := rs.db.Exec(query, vehicleID, routeID, confidence)
+	return err
+}
+
+// ScheduledRoutes returns the ID of the Route that each Vehicle is scheduled to run, keyed by Vehicle ID.
+// Vehicles without a scheduled Route are not included.
+func (rs *RouteService) ScheduledRoutes() (map[int64]int64, error) {
+	rows, err := rs.db.Query("SELECT vehicle_id, route_id FROM vehicle_scheduled_routes;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scheduled := map[int64]int64{}
+	for rows.Next() {
+		var vehicleID, routeID int64
+		err := rows.Scan(&vehicleID, &routeID)
+		if err != nil {
+			return nil, err
+		}
+		scheduled[vehicleID] = routeID
+	}
+	return scheduled, rows.Err()
+}
+
+// SetScheduledRoute records the Route that a Vehicle is scheduled to run. A nil routeID removes the
+// Vehicle's scheduled Route.
+func (rs *RouteService) SetScheduledRoute(vehicleID int64, routeID *int64) error {
+	if routeID == nil {
+		_, err := rs.db.Exec("DELETE FROM vehicle_scheduled_routes WHERE vehicle_id = $1;", vehicleID)
+		return err
+	}
+	query := "INSERT INTO vehicle_scheduled_routes (vehicle_id, route_id, updated) VALUES ($1, $2, now())" +
+		" ON CONFLICT (vehicle_id) DO UPDATE SET route_id = excluded.route_id, updated = excluded.updated;"
+	_, err := rs.db.Exec(query, vehicleID, *routeID)
+	return err
+}
+
+// Timetable returns the Timetable of a Route ordered by day and time.
+func (rs *RouteService) Timetable(routeID int64) (shuttletracker.Timetable, error) {
+	query := "SELECT t.stop_id, t.day, to_char(t.time, 'HH24:MI') FROM route_timetables t" +
+		" WHERE t.route_id = $1 ORDER BY t.day ASC, t.time ASC, t.id ASC;"
+	rows, err := rs.db.Query(query, routeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	timetable := shuttletracker.Timetable{}
+	for rows.Next() {
+		entry := shuttletracker.TimetableEntry{}
+		err = rows.Scan(&entry.StopID, &entry.Day, &entry.Time)
+		if err != nil {
+			return nil, err
+		}
+		timetable = append(timetable, entry)
+	}
+	return timetable, rows.Err()
+}
+
+// SetTimetable replaces the Timetable of a Route.
+func (rs *RouteService) SetTimetable(routeID int64, timetable shuttletracker.Timetable) error {
+	err := timetable.Validate()
+	if err != nil {
+		return err
+	}
+
+	tx, err := rs.db.Begin()
+	if err != nil {
+		return err
+	}
+	// We can't really do anything if rolling back a transaction fails.
+	// nolint: errcheck
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM route_timetables WHERE route_id = $1;", routeID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range timetable {
+		statement := "INSERT INTO route_timetables (route_id, stop_id, day, time) VALUES ($1, $2, $3, $4::time);"
+		_, err = tx.Exec(statement, routeID, entry.StopID, entry.Day, entry.Time)
+		if err != nil {
+			return err
+		}
+	}
 	return tx.Commit()
 }
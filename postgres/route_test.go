@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
@@ -288,3 +289,480 @@ func TestActiveTransition(t *testing.T) {
 		t.Error("route is active")
 	}
 }
+
+func TestRoutesForStop(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	served := &shuttletracker.Stop{Latitude: 42.73, Longitude: -73.68}
+	err := pg.CreateStop(served)
+	if err != nil {
+		t.Fatalf("unable to create Stop: %s", err)
+	}
+	unserved := &shuttletracker.Stop{Latitude: 42.74, Longitude: -73.67}
+	err = pg.CreateStop(unserved)
+	if err != nil {
+		t.Fatalf("unable to create Stop: %s", err)
+	}
+
+	west := &shuttletracker.Route{Name: "West", StopIDs: []int64{served.ID}, Schedule: shuttletracker.RouteSchedule{}}
+	east := &shuttletracker.Route{Name: "East", StopIDs: []int64{served.ID}, Schedule: shuttletracker.RouteSchedule{}}
+	other := &shuttletracker.Route{Name: "Other", StopIDs: []int64{}, Schedule: shuttletracker.RouteSchedule{}}
+	for _, route := range []*shuttletracker.Route{west, east, other} {
+		err = pg.CreateRoute(route)
+		if err != nil {
+			t.Fatalf("unable to create Route: %s", err)
+		}
+	}
+
+	routes, err := pg.RoutesForStop(served.ID)
+	if err != nil {
+		t.Fatalf("unable to get Routes: %s", err)
+	}
+	if len(routes) != 2 {
+		t.Errorf("got %d Routes, expected 2", len(routes))
+	}
+	for _, route := range routes {
+		if route.ID != west.ID && route.ID != east.ID {
+			t.Errorf("got unexpected Route %s", route.Name)
+		}
+	}
+
+	routes, err = pg.RoutesForStop(unserved.ID)
+	if err != nil {
+		t.Fatalf("unable to get Routes: %s", err)
+	}
+	if routes == nil || len(routes) != 0 {
+		t.Errorf("got %v, expected an empty slice", routes)
+	}
+}
+
+func TestCurrentRoute(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{Name: "Vehicle", TrackerID: "1234"}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	route := &shuttletracker.Route{Name: "West", StopIDs: []int64{}, Schedule: shuttletracker.RouteSchedule{}}
+	err = pg.CreateRoute(route)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+
+	current, err := pg.CurrentRoute(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to get current Route: %s", err)
+	}
+	if current != nil {
+		t.Errorf("got Route %d before any was recorded", current.ID)
+	}
+
+	err = pg.SetCurrentRoute(vehicle.ID, &route.ID, 0.8)
+	if err != nil {
+		t.Fatalf("unable to set current Route: %s", err)
+	}
+	current, err = pg.CurrentRoute(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to get current Route: %s", err)
+	}
+	if current == nil || current.ID != route.ID {
+		t.Errorf("got %+v, expected Route %d", current, route.ID)
+	}
+
+	// recording that the vehicle left its route replaces the previous guess
+	err = pg.SetCurrentRoute(vehicle.ID, nil, 0)
+	if err != nil {
+		t.Fatalf("unable to set current Route: %s", err)
+	}
+	current, err = pg.CurrentRoute(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to get current Route: %s", err)
+	}
+	if current != nil {
+		t.Errorf("got Route %d after the vehicle left it", current.ID)
+	}
+}
+
+func TestScheduledRoutes(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{Name: "Vehicle", TrackerID: "1234"}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	west := &shuttletracker.Route{Name: "West", StopIDs: []int64{}, Schedule: shuttletracker.RouteSchedule{}}
+	east := &shuttletracker.Route{Name: "East", StopIDs: []int64{}, Schedule: shuttletracker.RouteSchedule{}}
+	for _, route := range []*shuttletracker.Route{west, east} {
+		err = pg.CreateRoute(route)
+		if err != nil {
+			t.Fatalf("unable to create Route: %s", err)
+		}
+	}
+
+	check := func(name string, expected map[int64]int64) {
+		scheduled, err := pg.ScheduledRoutes()
+		if err != nil {
+			t.Fatalf("%s: unable to get scheduled Routes: %s", name, err)
+		}
+		if len(scheduled) != len(expected) {
+			t.Fatalf("%s: got %v, expected %v", name, scheduled, expected)
+		}
+		for vehicleID, routeID := range expected {
+			if scheduled[vehicleID] != routeID {
+				t.Errorf("%s: got %v, expected %v", name, scheduled, expected)
+			}
+		}
+	}
+	check("none", map[int64]int64{})
+
+	err = pg.SetScheduledRoute(vehicle.ID, &west.ID)
+	if err != nil {
+		t.Fatalf("unable to set scheduled Route: %s", err)
+	}
+	check("west", map[int64]int64{vehicle.ID: west.ID})
+
+	// a new assignment replaces the previous one
+	err = pg.SetScheduledRoute(vehicle.ID, &east.ID)
+	if err != nil {
+		t.Fatalf("unable to set scheduled Route: %s", err)
+	}
+	check("east", map[int64]int64{vehicle.ID: east.ID})
+
+	err = pg.SetScheduledRoute(vehicle.ID, nil)
+	if err != nil {
+		t.Fatalf("unable to clear scheduled Route: %s", err)
+	}
+	check("cleared", map[int64]int64{})
+
+	// deleting a Route removes assignments to it
+	err = pg.SetScheduledRoute(vehicle.ID, &west.ID)
+	if err != nil {
+		t.Fatalf("unable to set scheduled Route: %s", err)
+	}
+	err = pg.DeleteRoute(west.ID)
+	if err != nil {
+		t.Fatalf("unable to delete Route: %s", err)
+	}
+	check("deleted", map[int64]int64{})
+}
+
+func TestTimetable(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	stop := &shuttletracker.Stop{Latitude: 42.73, Longitude: -73.68}
+	err := pg.CreateStop(stop)
+	if err != nil {
+		t.Fatalf("unable to create Stop: %s", err)
+	}
+	route := &shuttletracker.Route{Name: "West", StopIDs: []int64{stop.ID}, Schedule: shuttletracker.RouteSchedule{}}
+	err = pg.CreateRoute(route)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+
+	timetable := shuttletracker.Timetable{
+		{StopID: stop.ID, Day: time.Tuesday, Time: "08:00"},
+		{StopID: stop.ID, Day: time.Monday, Time: "09:30"},
+		{StopID: stop.ID, Day: time.Monday, Time: "08:15"},
+	}
+	err = pg.SetTimetable(route.ID, timetable)
+	if err != nil {
+		t.Fatalf("unable to set Timetable: %s", err)
+	}
+	got, err := pg.Timetable(route.ID)
+	if err != nil {
+		t.Fatalf("unable to get Timetable: %s", err)
+	}
+	expected := shuttletracker.Timetable{timetable[2], timetable[1], timetable[0]}
+	if len(got) != len(expected) {
+		t.Fatalf("got %d entries, expected %d", len(got), len(expected))
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("got entry %d %+v, expected %+v", i, got[i], expected[i])
+		}
+	}
+
+	err = pg.SetTimetable(route.ID, shuttletracker.Timetable{{StopID: stop.ID, Day: time.Monday, Time: "25:00"}})
+	if err == nil {
+		t.Error("expected an error for an invalid time")
+	}
+	got, err = pg.Timetable(route.ID)
+	if err != nil {
+		t.Fatalf("unable to get Timetable: %s", err)
+	}
+	if len(got) != len(expected) {
+		t.Errorf("got %d entries after an invalid update, expected %d", len(got), len(expected))
+	}
+}
+
+func TestRoutePointElevations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	flat := &shuttletracker.Route{
+		Name:   "Flat Route",
+		Points: []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.68}, {Latitude: 42.74, Longitude: -73.67}},
+	}
+	err := pg.CreateRoute(flat)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+	elevation := 120.5
+	hilly := &shuttletracker.Route{
+		Name: "Hilly Route",
+		Points: []shuttletracker.Point{
+			{Latitude: 42.73, Longitude: -73.68, Elevation: &elevation},
+			{Latitude: 42.74, Longitude: -73.67},
+		},
+	}
+	err = pg.CreateRoute(hilly)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+
+	route, err := pg.Route(flat.ID)
+	if err != nil {
+		t.Fatalf("unable to get Route: %s", err)
+	}
+	for i, point := range route.Points {
+		if point.Index == nil || *point.Index != i || point.Elevation != nil {
+			t.Errorf("got point %+v at index %d, expected no elevation", point, i)
+		}
+	}
+
+	routes, err := pg.Routes()
+	if err != nil {
+		t.Fatalf("unable to get Routes: %s", err)
+	}
+	for _, route := range routes {
+		if route.ID != hilly.ID {
+			continue
+		}
+		if len(route.Points) != 2 {
+			t.Fatalf("got %d points, expected 2", len(route.Points))
+		}
+		if route.Points[0].Elevation == nil || *route.Points[0].Elevation != elevation {
+			t.Errorf("got elevation %v, expected %v", route.Points[0].Elevation, elevation)
+		}
+		if route.Points[1].Elevation != nil || route.Points[1].Index == nil || *route.Points[1].Index != 1 {
+			t.Errorf("got point %+v, expected index 1 without an elevation", route.Points[1])
+		}
+	}
+}
+
+func TestRouteVersion(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	route := &shuttletracker.Route{
+		Name:   "Versioned Route",
+		Points: []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.68}, {Latitude: 42.74, Longitude: -73.67}},
+	}
+	err := pg.CreateRoute(route)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+	if route.Version != 1 {
+		t.Errorf("got version %d for a new Route, expected 1", route.Version)
+	}
+
+	// changes that don't touch the geometry keep the version
+	route.Name = "Renamed Route"
+	err = pg.ModifyRoute(route)
+	if err != nil {
+		t.Fatalf("unable to modify Route: %s", err)
+	}
+	if route.Version != 1 {
+		t.Errorf("got version %d after renaming, expected 1", route.Version)
+	}
+
+	route.Points = append(route.Points, shuttletracker.Point{Latitude: 42.75, Longitude: -73.66})
+	err = pg.ModifyRoute(route)
+	if err != nil {
+		t.Fatalf("unable to modify Route: %s", err)
+	}
+	actual, err := pg.Route(route.ID)
+	if err != nil {
+		t.Fatalf("unable to get Route: %s", err)
+	}
+	if route.Version != 2 || actual.Version != 2 {
+		t.Errorf("got versions %d and %d after changing points, expected 2", route.Version, actual.Version)
+	}
+
+	vehicle := &shuttletracker.Vehicle{Name: "test vehicle", TrackerID: "tracker1"}
+	err = pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	version := int64(1)
+	err = pg.CreateLocation(&shuttletracker.Location{TrackerID: "tracker1", Time: time.Now(), RouteID: &route.ID, RouteVersion: &version})
+	if err != nil {
+		t.Fatalf("unable to create Location: %s", err)
+	}
+	location, err := pg.LatestLocation(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to get Location: %s", err)
+	}
+	if location.RouteVersion == nil || *location.RouteVersion != 1 {
+		t.Errorf("got route version %v, expected 1", location.RouteVersion)
+	}
+}
+
+func TestRouteBranches(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	route := &shuttletracker.Route{
+		Name:   "Branching Route",
+		Points: []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.68}, {Latitude: 42.74, Longitude: -73.67}},
+		Branches: []shuttletracker.RouteBranch{{
+			Name:   "Detour",
+			Points: []shuttletracker.Point{{Latitude: 42.74, Longitude: -73.67}, {Latitude: 42.75, Longitude: -73.67}},
+		}},
+	}
+	err := pg.CreateRoute(route)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+	if route.Branches[0].ID == 0 {
+		t.Error("expected the Branch to have an ID")
+	}
+
+	actual, err := pg.Route(route.ID)
+	if err != nil {
+		t.Fatalf("unable to get Route: %s", err)
+	}
+	if len(actual.Branches) != 1 || actual.Branches[0].Name != "Detour" || len(actual.Branches[0].Points) != 2 {
+		t.Fatalf("got Branches %+v, expected the detour", actual.Branches)
+	}
+	if actual.Branches[0].Points[1].Latitude != 42.75 {
+		t.Errorf("got point %+v, expected latitude 42.75", actual.Branches[0].Points[1])
+	}
+
+	// changing a Branch changes the Route's geometry
+	actual.Branches = append(actual.Branches, shuttletracker.RouteBranch{
+		Name:   "Spur",
+		Points: []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.68}, {Latitude: 42.72, Longitude: -73.68}},
+	})
+	err = pg.ModifyRoute(actual)
+	if err != nil {
+		t.Fatalf("unable to modify Route: %s", err)
+	}
+	if actual.Version != 2 {
+		t.Errorf("got version %d, expected 2", actual.Version)
+	}
+	routes, err := pg.Routes()
+	if err != nil {
+		t.Fatalf("unable to get Routes: %s", err)
+	}
+	if len(routes) != 1 || len(routes[0].Branches) != 2 || routes[0].Branches[1].Name != "Spur" {
+		t.Errorf("got %+v, expected both Branches", routes)
+	}
+}
+
+func TestRoutesWithVehicleCounts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	west := &shuttletracker.Route{Name: "West", StopIDs: []int64{}, Schedule: shuttletracker.RouteSchedule{}}
+	east := &shuttletracker.Route{Name: "East", StopIDs: []int64{}, Schedule: shuttletracker.RouteSchedule{}}
+	for _, route := range []*shuttletracker.Route{west, east} {
+		err := pg.CreateRoute(route)
+		if err != nil {
+			t.Fatalf("unable to create Route: %s", err)
+		}
+	}
+
+	now := time.Now()
+	locations := []*shuttletracker.Location{
+		// vehicle 1 was on East but is now on West
+		{TrackerID: "1", RouteID: &east.ID, Time: now.Add(-time.Minute * 2)},
+		{TrackerID: "1", RouteID: &west.ID, Time: now.Add(-time.Minute)},
+		{TrackerID: "2", RouteID: &west.ID, Time: now.Add(-time.Minute)},
+		// vehicle 3 hasn't reported recently
+		{TrackerID: "3", RouteID: &east.ID, Time: now.Add(-time.Hour)},
+		// vehicle 4 is disabled
+		{TrackerID: "4", RouteID: &east.ID, Time: now.Add(-time.Minute)},
+	}
+	for i := 1; i <= 4; i++ {
+		id := strconv.Itoa(i)
+		vehicle := &shuttletracker.Vehicle{Name: "Vehicle " + id, Enabled: i != 4, TrackerID: id}
+		err := pg.CreateVehicle(vehicle)
+		if err != nil {
+			t.Fatalf("unable to create Vehicle: %s", err)
+		}
+	}
+	for _, location := range locations {
+		err := pg.CreateLocation(location)
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	routes, err := pg.RoutesWithVehicleCounts(time.Minute * 5)
+	if err != nil {
+		t.Fatalf("unable to get Routes: %s", err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("got %d Routes, expected 2", len(routes))
+	}
+	expected := map[int64]int{west.ID: 2, east.ID: 0}
+	names := map[int64]string{west.ID: "West", east.ID: "East"}
+	for _, route := range routes {
+		if route.Route.Name != names[route.Route.ID] {
+			t.Errorf("got name %q for Route %d, expected %q", route.Route.Name, route.Route.ID, names[route.Route.ID])
+		}
+		if route.VehicleCount != expected[route.Route.ID] {
+			t.Errorf("got %d vehicles on %s, expected %d", route.VehicleCount, route.Route.Name, expected[route.Route.ID])
+		}
+	}
+}
+
+func TestSameGeometry(t *testing.T) {
+	saved := []shuttletracker.Point{{Latitude: 42.7301234, Longitude: -73.6784567}}
+	// as read back from six decimal places
+	stored := []shuttletracker.Point{{Latitude: 42.730123, Longitude: -73.678457}}
+	if !sameGeometry(saved, stored) {
+		t.Error("expected rounding by storage to keep the same geometry")
+	}
+	moved := []shuttletracker.Point{{Latitude: 42.730133, Longitude: -73.678457}}
+	if sameGeometry(saved, moved) {
+		t.Error("expected a point moved by a hundred-thousandth of a degree to change the geometry")
+	}
+	elevation := 30.0
+	withElevation := []shuttletracker.Point{{Latitude: 42.730123, Longitude: -73.678457, Elevation: &elevation}}
+	if sameGeometry(stored, withElevation) {
+		t.Error("expected adding an elevation to change the geometry")
+	}
+}
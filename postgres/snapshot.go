@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// Snapshot is the current state of the database, e.g. to copy production to a staging environment.
+type Snapshot struct {
+	Vehicles []*shuttletracker.Vehicle `json:"vehicles"`
+	Stops    []*shuttletracker.Stop    `json:"stops"`
+	Routes   []*shuttletracker.Route   `json:"routes"`
+
+	// Locations contains the latest Location of each Vehicle that has one, ordered by Vehicle ID.
+	Locations []*shuttletracker.Location `json:"locations"`
+}
+
+// ExportSnapshot writes every Vehicle, Stop, and Route along with the latest Location of each Vehicle
+// to w as a single JSON document that ImportSnapshot can read.
+func (pg *Postgres) ExportSnapshot(w io.Writer) error {
+	vehicles, err := pg.Vehicles()
+	if err != nil {
+		return err
+	}
+	stops, err := pg.Stops()
+	if err != nil {
+		return err
+	}
+	routes, err := pg.Routes()
+	if err != nil {
+		return err
+	}
+	latest, err := pg.LatestLocations()
+	if err != nil {
+		return err
+	}
+
+	snapshot := Snapshot{
+		Vehicles:  vehicles,
+		Stops:     stops,
+		Routes:    routes,
+		Locations: []*shuttletracker.Location{},
+	}
+	if snapshot.Vehicles == nil {
+		snapshot.Vehicles = []*shuttletracker.Vehicle{}
+	}
+	ids := []int{}
+	for id := range latest {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		snapshot.Locations = append(snapshot.Locations, latest[int64(id)])
+	}
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// ImportSnapshot creates the Vehicles, Stops, Routes, and Locations of a snapshot written by
+// ExportSnapshot in a single transaction, so nothing is created if any of them can't be. Everything
+// gets a new ID, and Routes keep their Stops and Locations keep their Routes by way of them. Locations
+// aren't part of a trip once imported, and those that are already stored are skipped.
+func (pg *Postgres) ImportSnapshot(r io.Reader) error {
+	snapshot := Snapshot{}
+	err := json.NewDecoder(r).Decode(&snapshot)
+	if err != nil {
+		return err
+	}
+
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	// We can't really do anything if rolling back a transaction fails.
+	// nolint: errcheck
+	defer tx.Rollback()
+
+	stopIDs := map[int64]int64{}
+	for _, stop := range snapshot.Stops {
+		oldID := stop.ID
+		err = createStop(tx, stop)
+		if err != nil {
+			return err
+		}
+		stopIDs[oldID] = stop.ID
+	}
+
+	routes := map[int64]*shuttletracker.Route{}
+	for _, route := range snapshot.Routes {
+		oldID := route.ID
+		ids := []int64{}
+		for _, id := range route.StopIDs {
+			newID, ok := stopIDs[id]
+			if !ok {
+				return fmt.Errorf("route %d has stop %d, which isn't in the snapshot", oldID, id)
+			}
+			ids = append(ids, newID)
+		}
+		route.StopIDs = ids
+		err = createRoute(tx, route)
+		if err != nil {
+			return err
+		}
+		routes[oldID] = route
+	}
+
+	for _, vehicle := range snapshot.Vehicles {
+		err = createVehicle(tx, vehicle)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Locations belong to Vehicles by way of their tracker IDs, which don't change.
+	for _, location := range snapshot.Locations {
+		location.TripID = nil
+		location.RouteVersion = nil
+		if location.RouteID != nil {
+			route, ok := routes[*location.RouteID]
+			if ok {
+				location.RouteID = &route.ID
+				location.RouteVersion = &route.Version
+			} else {
+				location.RouteID = nil
+			}
+		}
+	}
+	err = createHistoricalLocations(tx, snapshot.Locations)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// nolint: gocyclo
+func TestSnapshot(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+
+	stop := &shuttletracker.Stop{Latitude: 42.73, Longitude: -73.68}
+	err := pg.CreateStop(stop)
+	if err != nil {
+		t.Fatalf("unable to create Stop: %s", err)
+	}
+	route := &shuttletracker.Route{
+		Name:     "West",
+		StopIDs:  []int64{stop.ID},
+		Schedule: shuttletracker.RouteSchedule{},
+		Points:   []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.68}, {Latitude: 42.74, Longitude: -73.68}},
+	}
+	err = pg.CreateRoute(route)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+	vehicle := &shuttletracker.Vehicle{Name: "Vehicle 1", Enabled: true, TrackerID: "1"}
+	err = pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	latest := time.Now().Truncate(time.Second)
+	for _, location := range []*shuttletracker.Location{
+		{TrackerID: "1", Time: latest.Add(-time.Minute)},
+		{TrackerID: "1", RouteID: &route.ID, Time: latest},
+	} {
+		err = pg.CreateLocation(location)
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err = pg.ExportSnapshot(buf)
+	if err != nil {
+		t.Fatalf("unable to export snapshot: %s", err)
+	}
+	snapshot := buf.Bytes()
+
+	// importing into the same database fails on the duplicate tracker ID and creates nothing
+	err = pg.ImportSnapshot(bytes.NewReader(snapshot))
+	if err == nil {
+		t.Error("expected an error for a duplicate tracker ID")
+	}
+	stops, err := pg.Stops()
+	if err != nil {
+		t.Fatalf("unable to get Stops: %s", err)
+	}
+	if len(stops) != 1 {
+		t.Errorf("got %d Stops after a failed import, expected 1", len(stops))
+	}
+	tearDownPostgres(t)
+
+	pg = setUpPostgres(t)
+	defer tearDownPostgres(t)
+	// shift the IDs that the imported Stops get
+	err = pg.CreateStop(&shuttletracker.Stop{Latitude: 1, Longitude: 1})
+	if err != nil {
+		t.Fatalf("unable to create Stop: %s", err)
+	}
+	err = pg.ImportSnapshot(bytes.NewReader(snapshot))
+	if err != nil {
+		t.Fatalf("unable to import snapshot: %s", err)
+	}
+
+	routes, err := pg.Routes()
+	if err != nil {
+		t.Fatalf("unable to get Routes: %s", err)
+	}
+	if len(routes) != 1 || len(routes[0].StopIDs) != 1 || len(routes[0].Points) != 2 {
+		t.Fatalf("got %+v, expected the exported Route", routes)
+	}
+	stops, err = pg.Stops()
+	if err != nil {
+		t.Fatalf("unable to get Stops: %s", err)
+	}
+	found := false
+	for _, s := range stops {
+		if s.ID == routes[0].StopIDs[0] {
+			found = s.ID != stop.ID && s.Latitude == stop.Latitude && s.Longitude == stop.Longitude
+		}
+	}
+	if !found {
+		t.Errorf("got Stops %+v, expected the Route's Stop to be a new Stop at %f, %f", stops, stop.Latitude, stop.Longitude)
+	}
+
+	vehicles, err := pg.Vehicles()
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	if len(vehicles) != 1 || vehicles[0].Name != vehicle.Name {
+		t.Fatalf("got %+v, expected the exported Vehicle", vehicles)
+	}
+	location, err := pg.LatestLocation(vehicles[0].ID)
+	if err != nil {
+		t.Fatalf("unable to get latest Location: %s", err)
+	}
+	if !location.Time.Equal(latest) || location.RouteID == nil || *location.RouteID != routes[0].ID {
+		t.Errorf("got %+v, expected the latest Location on the imported Route", location)
+	}
+}
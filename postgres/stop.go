@@ -2,8 +2,11 @@ package postgres
 
 import (
 	"database/sql"
+	"fmt"
+	"sort"
 
 	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
 )
 
 // StopService is an implementation of shuttletracker.StopService.
@@ -23,19 +26,37 @@ CREATE TABLE IF NOT EXISTS stops (
 	longitude double precision NOT NULL,
 	created timestamp with time zone NOT NULL DEFAULT now(),
 	updated timestamp with time zone NOT NULL DEFAULT now()
-);`
+);
+ALTER TABLE stops ADD COLUMN IF NOT EXISTS arrival_radius double precision;`
 	_, err := ss.db.Exec(schema)
 	return err
 }
 
 // CreateStop creates a Stop.
 func (ss *StopService) CreateStop(stop *shuttletracker.Stop) error {
+	return createStop(ss.db, stop)
+}
+
+// createStop creates a Stop using q, which may be a transaction.
+func createStop(q rowQuerier, stop *shuttletracker.Stop) error {
 	// Postgres command that cretes a stop in the database
-	statement := "INSERT INTO stops (name, description, latitude, longitude) VALUES" +
-		" ($1, $2, $3, $4) RETURNING id, created, updated;"
-	row := ss.db.QueryRow(statement, stop.Name, stop.Description, stop.Latitude, stop.Longitude)
+	statement := "INSERT INTO stops (name, description, latitude, longitude, arrival_radius) VALUES" +
+		" ($1, $2, $3, $4, $5) RETURNING id, created, updated;"
+	row := q.QueryRow(statement, stop.Name, stop.Description, stop.Latitude, stop.Longitude, stop.ArrivalRadius)
 	// If this function is successful, it should return "nil"
-	return row.Scan(&stop.ID, &stop.Created, &stop.Updated)
+	return row.Scan(&stop.ID, scanUTC{&stop.Created}, scanUTC{&stop.Updated})
+}
+
+// ModifyStop updates an existing Stop.
+func (ss *StopService) ModifyStop(stop *shuttletracker.Stop) error {
+	statement := "UPDATE stops SET name = $1, description = $2, latitude = $3, longitude = $4," +
+		" arrival_radius = $5, updated = now() WHERE id = $6 RETURNING updated;"
+	row := ss.db.QueryRow(statement, stop.Name, stop.Description, stop.Latitude, stop.Longitude, stop.ArrivalRadius, stop.ID)
+	err := row.Scan(scanUTC{&stop.Updated})
+	if err == sql.ErrNoRows {
+		return shuttletracker.ErrStopNotFound
+	}
+	return err
 }
 
 // Stops returns all Stops.
@@ -43,7 +64,7 @@ func (ss *StopService) Stops() ([]*shuttletracker.Stop, error) {
 	// Stops list to be returned
 	stops := []*shuttletracker.Stop{}
 	// Postgres command that gets all stops
-	query := "SELECT s.id, s.name, s.created, s.updated, s.description, s.latitude, s.longitude" +
+	query := "SELECT s.id, s.name, s.created, s.updated, s.description, s.latitude, s.longitude, s.arrival_radius" +
 		" FROM stops s;"
 	rows, err := ss.db.Query(query)
 	if err != nil {
@@ -54,7 +75,7 @@ func (ss *StopService) Stops() ([]*shuttletracker.Stop, error) {
 	// from the database
 	for rows.Next() {
 		s := &shuttletracker.Stop{}
-		err := rows.Scan(&s.ID, &s.Name, &s.Created, &s.Updated, &s.Description, &s.Latitude, &s.Longitude)
+		err := rows.Scan(&s.ID, &s.Name, scanUTC{&s.Created}, scanUTC{&s.Updated}, &s.Description, &s.Latitude, &s.Longitude, &s.ArrivalRadius)
 		if err != nil {
 			return nil, err
 		}
@@ -84,3 +105,166 @@ func (ss *StopService) DeleteStop(id int64) error {
 
 	return nil
 }
+
+// UpdateStopCoordinates moves Stops to corrected coordinates in a single transaction. If any Stop
+// does not exist, none of the updates are applied.
+func (ss *StopService) UpdateStopCoordinates(updates []shuttletracker.StopCoordinateUpdate) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	// We can't really do anything if rolling back a transaction fails.
+	// nolint: errcheck
+	defer tx.Rollback()
+
+	statement := "UPDATE stops SET latitude = $1, longitude = $2, updated = now() WHERE id = $3;"
+	for _, update := range updates {
+		result, err := tx.Exec(statement, update.Latitude, update.Longitude, update.ID)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("stop %d not found", update.ID)
+		}
+	}
+
+	return tx.Commit()
+}
+
+type stopsByID []*shuttletracker.Stop
+
+func (s stopsByID) Len() int           { return len(s) }
+func (s stopsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+func (s stopsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// FindDuplicateStops groups Stops that are within radius meters of each other, including Stops that
+// are only connected through other Stops in the group. Only groups with more than one Stop are
+// returned. Stops within a group and the groups themselves are ordered by Stop ID.
+func (ss *StopService) FindDuplicateStops(radius float64) ([][]*shuttletracker.Stop, error) {
+	stops, err := ss.Stops()
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(stopsByID(stops))
+
+	// each stop points toward the first stop of its group
+	parents := make([]int, len(stops))
+	for i := range parents {
+		parents[i] = i
+	}
+	root := func(i int) int {
+		for parents[i] != i {
+			parents[i] = parents[parents[i]]
+			i = parents[i]
+		}
+		return i
+	}
+	for i := range stops {
+		a := shuttletracker.Point{Latitude: stops[i].Latitude, Longitude: stops[i].Longitude}
+		for j := i + 1; j < len(stops); j++ {
+			b := shuttletracker.Point{Latitude: stops[j].Latitude, Longitude: stops[j].Longitude}
+			if spatial.DistanceBetween(a, b) > radius {
+				continue
+			}
+			ri, rj := root(i), root(j)
+			if ri < rj {
+				parents[rj] = ri
+			} else if rj < ri {
+				parents[ri] = rj
+			}
+		}
+	}
+
+	members := map[int][]*shuttletracker.Stop{}
+	for i, stop := range stops {
+		r := root(i)
+		members[r] = append(members[r], stop)
+	}
+	groups := [][]*shuttletracker.Stop{}
+	for i := range stops {
+		if group := members[i]; len(group) > 1 {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// MergeStops replaces the Stops with mergeIDs by the Stop with keepID on every Route and Timetable
+// and then deletes them, all in a single transaction. A Route that already serves the kept Stop
+// drops the merged Stop rather than serving the kept Stop twice.
+func (ss *StopService) MergeStops(keepID int64, mergeIDs []int64) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	// We can't really do anything if rolling back a transaction fails.
+	// nolint: errcheck
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT exists(SELECT 1 FROM stops WHERE id = $1);", keepID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return shuttletracker.ErrStopNotFound
+	}
+
+	for _, mergeID := range mergeIDs {
+		if mergeID == keepID {
+			return fmt.Errorf("stop %d cannot be merged into itself", keepID)
+		}
+		statement := "DELETE FROM routes_stops WHERE stop_id = $1" +
+			" AND route_id IN (SELECT route_id FROM routes_stops WHERE stop_id = $2);"
+		_, err = tx.Exec(statement, mergeID, keepID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE routes_stops SET stop_id = $1 WHERE stop_id = $2;", keepID, mergeID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE route_timetables SET stop_id = $1 WHERE stop_id = $2;", keepID, mergeID)
+		if err != nil {
+			return err
+		}
+		result, err := tx.Exec("DELETE FROM stops WHERE id = $1;", mergeID)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("stop %d not found", mergeID)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// OrphanStops returns the Stops that aren't served by any currently active Route.
+func (ss *StopService) OrphanStops() ([]*shuttletracker.Stop, error) {
+	stops := []*shuttletracker.Stop{}
+	query := "SELECT s.id, s.name, s.created, s.updated, s.description, s.latitude, s.longitude, s.arrival_radius" +
+		" FROM stops s WHERE NOT EXISTS (SELECT 1 FROM routes_stops rs" +
+		" WHERE rs.stop_id = s.id AND route_is_active(rs.route_id)) ORDER BY s.id;"
+	rows, err := ss.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		s := &shuttletracker.Stop{}
+		err := rows.Scan(&s.ID, &s.Name, scanUTC{&s.Created}, scanUTC{&s.Updated}, &s.Description, &s.Latitude, &s.Longitude, &s.ArrivalRadius)
+		if err != nil {
+			return nil, err
+		}
+		stops = append(stops, s)
+	}
+	return stops, nil
+}
@@ -0,0 +1,265 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// nolint: gocyclo
+func TestUpdateStopCoordinates(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	stop := &shuttletracker.Stop{
+		Latitude:  1.1,
+		Longitude: 1.2,
+	}
+	err := pg.CreateStop(stop)
+	if err != nil {
+		t.Fatalf("unable to create Stop: %s", err)
+	}
+
+	// a missing stop should roll back the whole correction
+	updates := []shuttletracker.StopCoordinateUpdate{
+		{ID: stop.ID, Latitude: 2.1, Longitude: 2.2},
+		{ID: stop.ID + 1, Latitude: 3.1, Longitude: 3.2},
+	}
+	err = pg.UpdateStopCoordinates(updates)
+	if err == nil {
+		t.Fatal("expected error for missing stop")
+	}
+	stops, err := pg.Stops()
+	if err != nil {
+		t.Fatalf("unable to get Stops: %s", err)
+	}
+	if len(stops) != 1 || stops[0].Latitude != 1.1 || stops[0].Longitude != 1.2 {
+		t.Errorf("got %+v, expected unchanged stop", stops)
+	}
+
+	err = pg.UpdateStopCoordinates(updates[:1])
+	if err != nil {
+		t.Fatalf("unable to update Stop coordinates: %s", err)
+	}
+	stops, err = pg.Stops()
+	if err != nil {
+		t.Fatalf("unable to get Stops: %s", err)
+	}
+	if len(stops) != 1 || stops[0].Latitude != 2.1 || stops[0].Longitude != 2.2 {
+		t.Errorf("got %+v, expected corrected stop", stops)
+	}
+	if !stops[0].Updated.After(stop.Updated) {
+		t.Error("stop updated time was not changed")
+	}
+}
+
+func TestModifyStop(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	stop := &shuttletracker.Stop{
+		Latitude:  1.1,
+		Longitude: 1.2,
+	}
+	err := pg.CreateStop(stop)
+	if err != nil {
+		t.Fatalf("unable to create Stop: %s", err)
+	}
+	stops, err := pg.Stops()
+	if err != nil {
+		t.Fatalf("unable to get Stops: %s", err)
+	}
+	if len(stops) != 1 || stops[0].ArrivalRadius != nil {
+		t.Fatalf("got %+v, expected one stop without an arrival radius", stops)
+	}
+
+	name := "Transit Center"
+	radius := 75.0
+	stop.Name = &name
+	stop.ArrivalRadius = &radius
+	err = pg.ModifyStop(stop)
+	if err != nil {
+		t.Fatalf("unable to modify Stop: %s", err)
+	}
+	stops, err = pg.Stops()
+	if err != nil {
+		t.Fatalf("unable to get Stops: %s", err)
+	}
+	if len(stops) != 1 || stops[0].Name == nil || *stops[0].Name != name ||
+		stops[0].ArrivalRadius == nil || *stops[0].ArrivalRadius != radius {
+		t.Errorf("got %+v, expected modified stop", stops)
+	}
+
+	stop.ID++
+	err = pg.ModifyStop(stop)
+	if err != shuttletracker.ErrStopNotFound {
+		t.Errorf("got error %v, expected %v", err, shuttletracker.ErrStopNotFound)
+	}
+}
+
+func TestFindDuplicateStops(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	// the first three are each about 5 m from the next, and the last is far from all of them
+	stops := []*shuttletracker.Stop{
+		{Latitude: 42.73000, Longitude: -73.68},
+		{Latitude: 42.73005, Longitude: -73.68},
+		{Latitude: 42.73010, Longitude: -73.68},
+		{Latitude: 42.74000, Longitude: -73.68},
+	}
+	for _, stop := range stops {
+		err := pg.CreateStop(stop)
+		if err != nil {
+			t.Fatalf("unable to create Stop: %s", err)
+		}
+	}
+
+	groups, err := pg.FindDuplicateStops(7)
+	if err != nil {
+		t.Fatalf("unable to find duplicate Stops: %s", err)
+	}
+	if len(groups) != 1 || len(groups[0]) != 3 {
+		t.Fatalf("got %v, expected one group of three Stops", groups)
+	}
+	for i, stop := range groups[0] {
+		if stop.ID != stops[i].ID {
+			t.Errorf("got Stop %d at position %d, expected %d", stop.ID, i, stops[i].ID)
+		}
+	}
+
+	groups, err = pg.FindDuplicateStops(1)
+	if err != nil {
+		t.Fatalf("unable to find duplicate Stops: %s", err)
+	}
+	if groups == nil || len(groups) != 0 {
+		t.Errorf("got %v, expected an empty slice", groups)
+	}
+}
+
+// nolint: gocyclo
+func TestMergeStops(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	keep := &shuttletracker.Stop{Latitude: 42.73, Longitude: -73.68}
+	merge := &shuttletracker.Stop{Latitude: 42.73001, Longitude: -73.68}
+	other := &shuttletracker.Stop{Latitude: 42.74, Longitude: -73.67}
+	for _, stop := range []*shuttletracker.Stop{keep, merge, other} {
+		err := pg.CreateStop(stop)
+		if err != nil {
+			t.Fatalf("unable to create Stop: %s", err)
+		}
+	}
+	west := &shuttletracker.Route{Name: "West", StopIDs: []int64{merge.ID, other.ID}, Schedule: shuttletracker.RouteSchedule{}}
+	east := &shuttletracker.Route{Name: "East", StopIDs: []int64{keep.ID, other.ID, merge.ID}, Schedule: shuttletracker.RouteSchedule{}}
+	for _, route := range []*shuttletracker.Route{west, east} {
+		err := pg.CreateRoute(route)
+		if err != nil {
+			t.Fatalf("unable to create Route: %s", err)
+		}
+	}
+
+	err := pg.MergeStops(keep.ID, []int64{keep.ID})
+	if err == nil {
+		t.Error("expected an error for merging a Stop into itself")
+	}
+	err = pg.MergeStops(keep.ID, []int64{merge.ID})
+	if err != nil {
+		t.Fatalf("unable to merge Stops: %s", err)
+	}
+
+	route, err := pg.Route(west.ID)
+	if err != nil {
+		t.Fatalf("unable to get Route: %s", err)
+	}
+	if len(route.StopIDs) != 2 || route.StopIDs[0] != keep.ID || route.StopIDs[1] != other.ID {
+		t.Errorf("got Stop IDs %v, expected %d, %d", route.StopIDs, keep.ID, other.ID)
+	}
+	route, err = pg.Route(east.ID)
+	if err != nil {
+		t.Fatalf("unable to get Route: %s", err)
+	}
+	if len(route.StopIDs) != 2 || route.StopIDs[0] != keep.ID || route.StopIDs[1] != other.ID {
+		t.Errorf("got Stop IDs %v, expected %d, %d", route.StopIDs, keep.ID, other.ID)
+	}
+	stops, err := pg.Stops()
+	if err != nil {
+		t.Fatalf("unable to get Stops: %s", err)
+	}
+	if len(stops) != 2 {
+		t.Errorf("got %d Stops, expected the merged Stop to be deleted", len(stops))
+	}
+}
+
+func TestOrphanStops(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	served := &shuttletracker.Stop{Latitude: 42.73, Longitude: -73.68}
+	inactive := &shuttletracker.Stop{Latitude: 42.74, Longitude: -73.67}
+	unserved := &shuttletracker.Stop{Latitude: 42.75, Longitude: -73.66}
+	for _, stop := range []*shuttletracker.Stop{served, inactive, unserved} {
+		err := pg.CreateStop(stop)
+		if err != nil {
+			t.Fatalf("unable to create Stop: %s", err)
+		}
+	}
+
+	// a Route without a schedule is always active, and one scheduled only tomorrow is not
+	now := time.Now()
+	tomorrow := (now.Weekday() + 1) % 7
+	active := &shuttletracker.Route{Name: "Active", StopIDs: []int64{served.ID}, Schedule: shuttletracker.RouteSchedule{}}
+	later := &shuttletracker.Route{
+		Name:    "Later",
+		StopIDs: []int64{inactive.ID},
+		Schedule: shuttletracker.RouteSchedule{
+			{StartDay: tomorrow, StartTime: now, EndDay: tomorrow, EndTime: now.Add(time.Minute)},
+		},
+	}
+	for _, route := range []*shuttletracker.Route{active, later} {
+		err := pg.CreateRoute(route)
+		if err != nil {
+			t.Fatalf("unable to create Route: %s", err)
+		}
+	}
+
+	orphans, err := pg.OrphanStops()
+	if err != nil {
+		t.Fatalf("unable to get orphan Stops: %s", err)
+	}
+	if len(orphans) != 2 || orphans[0].ID != inactive.ID || orphans[1].ID != unserved.ID {
+		t.Errorf("got %+v, expected Stops %d and %d", orphans, inactive.ID, unserved.ID)
+	}
+
+	all := &shuttletracker.Route{Name: "All", StopIDs: []int64{inactive.ID, unserved.ID}, Schedule: shuttletracker.RouteSchedule{}}
+	err = pg.CreateRoute(all)
+	if err != nil {
+		t.Fatalf("unable to create Route: %s", err)
+	}
+	orphans, err = pg.OrphanStops()
+	if err != nil {
+		t.Fatalf("unable to get orphan Stops: %s", err)
+	}
+	if orphans == nil || len(orphans) != 0 {
+		t.Errorf("got %v, expected an empty slice", orphans)
+	}
+}
@@ -0,0 +1,22 @@
+package postgres
+
+import (
+	"errors"
+	"time"
+)
+
+// scanUTC scans a timestamp into a time.Time in UTC. The driver returns timestamps in the session's
+// time zone, so scanning through this keeps every time returned by the services in one representation.
+type scanUTC struct {
+	t *time.Time
+}
+
+// Scan implements sql.Scanner.
+func (s scanUTC) Scan(src interface{}) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return errors.New("unable to scan time")
+	}
+	*s.t = t.UTC()
+	return nil
+}
@@ -2,22 +2,38 @@ package postgres
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
 
-	// Postgres driver for database/sql
-	_ "github.com/lib/pq"
-
+	"github.com/lib/pq"
 	"github.com/wtg/shuttletracker"
 )
 
+// trackerIDsColumn selects the tracker IDs belonging to the Vehicle aliased as v.
+const trackerIDsColumn = "array(SELECT t.tracker_id FROM vehicle_trackers t WHERE t.vehicle_id = v.id ORDER BY t.id)"
+
+// scheduleColumn selects the schedule of the Vehicle aliased as v as a JSON array.
+const scheduleColumn = "coalesce((SELECT json_agg(json_build_object(" +
+	"'start_day', s.start_day, 'start_time', to_char(s.start_time, 'HH24:MI'), " +
+	"'end_day', s.end_day, 'end_time', to_char(s.end_time, 'HH24:MI')) ORDER BY s.id) " +
+	"FROM vehicle_schedules s WHERE s.vehicle_id = v.id), '[]')"
+
 // VehicleService implements shuttletracker.VehicleService.
 type VehicleService struct {
 	db *sql.DB
+
+	// read is where listings of Vehicles are queried, which is the replica for the Postgres returned
+	// by ReadReplica and the primary otherwise. A single Vehicle is always read from the primary so
+	// that it can be modified safely.
+	read *sql.DB
 }
 
 // Initializes how the data is represented in the Postgres database
 func (v *VehicleService) initializeSchema(db *sql.DB) error {
 	// Postgres command that cretes a vehicle table in the database
 	v.db = db
+	v.read = db
 	schema := `
 -- DROP TABLE vehicles;
 CREATE TABLE IF NOT EXISTS vehicles (
@@ -28,20 +44,146 @@ CREATE TABLE IF NOT EXISTS vehicles (
 	enabled boolean NOT NULL,
 	tracker_id varchar(10) UNIQUE
 );
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS display_name text NOT NULL DEFAULT '';
+ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS tags text[] NOT NULL DEFAULT '{}';
+CREATE TABLE IF NOT EXISTS vehicle_trackers (
+	id serial PRIMARY KEY,
+	vehicle_id integer REFERENCES vehicles ON DELETE CASCADE NOT NULL,
+	tracker_id varchar(10) UNIQUE NOT NULL
+);
+CREATE TABLE IF NOT EXISTS vehicle_schedules (
+	id serial PRIMARY KEY,
+	vehicle_id integer REFERENCES vehicles ON DELETE CASCADE NOT NULL,
+	start_day smallint NOT NULL CHECK (start_day >= 0 AND start_day < 7),
+	start_time time NOT NULL,
+	end_day smallint NOT NULL CHECK (end_day >= 0 AND end_day < 7),
+	end_time time NOT NULL
+);
+-- Vehicles created before vehicle_trackers existed only have their primary tracker.
+INSERT INTO vehicle_trackers (vehicle_id, tracker_id)
+	SELECT id, tracker_id FROM vehicles WHERE tracker_id IS NOT NULL
+	ON CONFLICT DO NOTHING;
     `
 	_, err := v.db.Exec(schema)
 	return err
 }
 
+// trackerIDSet returns the Vehicle's tracker IDs with its primary tracker ID first and without duplicates.
+func trackerIDSet(vehicle *shuttletracker.Vehicle) []string {
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, id := range append([]string{vehicle.TrackerID}, vehicle.TrackerIDs...) {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// tagSet returns tags without empty tags or duplicates, in their original order.
+func tagSet(tags []string) []string {
+	set := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		set = append(set, tag)
+	}
+	return set
+}
+
+// setTrackers replaces the set of tracker IDs belonging to a Vehicle.
+func setTrackers(tx *sql.Tx, vehicle *shuttletracker.Vehicle) error {
+	_, err := tx.Exec("DELETE FROM vehicle_trackers WHERE vehicle_id = $1;", vehicle.ID)
+	if err != nil {
+		return err
+	}
+	ids := trackerIDSet(vehicle)
+	statement := "INSERT INTO vehicle_trackers (vehicle_id, tracker_id)" +
+		" SELECT $1, tracker_id FROM unnest($2::varchar[]) AS t(tracker_id);"
+	_, err = tx.Exec(statement, vehicle.ID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	vehicle.TrackerIDs = ids
+	return nil
+}
+
+// scanSchedule scans a JSON array selected by scheduleColumn into a VehicleSchedule.
+type scanSchedule struct {
+	schedule *shuttletracker.VehicleSchedule
+}
+
+// Scan implements sql.Scanner.
+func (s scanSchedule) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return errors.New("unable to scan schedule")
+	}
+	*s.schedule = shuttletracker.VehicleSchedule{}
+	return json.Unmarshal(b, s.schedule)
+}
+
+// setSchedule replaces the schedule of a Vehicle.
+func setSchedule(tx *sql.Tx, vehicle *shuttletracker.Vehicle) error {
+	err := vehicle.Schedule.Validate()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM vehicle_schedules WHERE vehicle_id = $1;", vehicle.ID)
+	if err != nil {
+		return err
+	}
+	for _, interval := range vehicle.Schedule {
+		statement := "INSERT INTO vehicle_schedules (vehicle_id, start_day, start_time, end_day, end_time)" +
+			" VALUES ($1, $2, $3::time, $4, $5::time);"
+		_, err = tx.Exec(statement, vehicle.ID, interval.StartDay, interval.StartTime, interval.EndDay, interval.EndTime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // CreateVehicle creates a Vehicle.
 func (v *VehicleService) CreateVehicle(vehicle *shuttletracker.Vehicle) error {
+	tx, err := v.db.Begin()
+	if err != nil {
+		return err
+	}
+	// We can't really do anything if rolling back a transaction fails.
+	// nolint: errcheck
+	defer tx.Rollback()
+
+	err = createVehicle(tx, vehicle)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createVehicle creates a Vehicle along with its trackers and schedule within tx.
+func createVehicle(tx *sql.Tx, vehicle *shuttletracker.Vehicle) error {
 	// Postgres command that cretes a vehicle in the database
-	statement := "INSERT INTO vehicles (name, enabled, tracker_id) " +
-		"VALUES ($1, $2, $3) RETURNING id, created, updated;"
-	row := v.db.QueryRow(statement, vehicle.Name, vehicle.Enabled, vehicle.TrackerID)
-	// If this function is successful, it should return "nil"
-	err := row.Scan(&vehicle.ID, &vehicle.Created, &vehicle.Updated)
-	return err
+	vehicle.Tags = tagSet(vehicle.Tags)
+	statement := "INSERT INTO vehicles (name, display_name, tags, enabled, tracker_id) " +
+		"VALUES ($1, $2, $3, $4, $5) RETURNING id, created, updated;"
+	row := tx.QueryRow(statement, vehicle.Name, vehicle.DisplayName, pq.Array(vehicle.Tags), vehicle.Enabled, vehicle.TrackerID)
+	err := row.Scan(&vehicle.ID, scanUTC{&vehicle.Created}, scanUTC{&vehicle.Updated})
+	if err != nil {
+		return err
+	}
+
+	err = setTrackers(tx, vehicle)
+	if err != nil {
+		return err
+	}
+
+	return setSchedule(tx, vehicle)
 }
 
 // DeleteVehicle deletes a Vehicle by its ID.
@@ -72,10 +214,11 @@ func (v *VehicleService) Vehicle(id int64) (*shuttletracker.Vehicle, error) {
 	}
 
 	// Finds the shuttle based on the input ID
-	statement := "SELECT name, created, updated, enabled, tracker_id " +
-		"FROM vehicles WHERE id = $1;"
+	statement := "SELECT v.name, v.display_name, v.tags, v.created, v.updated, v.enabled, v.tracker_id, " +
+		trackerIDsColumn + ", " + scheduleColumn + " " +
+		"FROM vehicles v WHERE v.id = $1;"
 	row := v.db.QueryRow(statement, id)
-	err := row.Scan(&vehicle.Name, &vehicle.Created, &vehicle.Updated, &vehicle.Enabled, &vehicle.TrackerID)
+	err := row.Scan(&vehicle.Name, &vehicle.DisplayName, pq.Array(&vehicle.Tags), scanUTC{&vehicle.Created}, scanUTC{&vehicle.Updated}, &vehicle.Enabled, &vehicle.TrackerID, pq.Array(&vehicle.TrackerIDs), scanSchedule{&vehicle.Schedule})
 	if err == sql.ErrNoRows {
 		return vehicle, shuttletracker.ErrVehicleNotFound
 	}
@@ -88,8 +231,10 @@ func (v *VehicleService) Vehicles() ([]*shuttletracker.Vehicle, error) {
 	// Vehicles list to be returned
 	var vehicles []*shuttletracker.Vehicle
 	// Postgres command that gets all vehicles
-	statement := "SELECT id, name, created, updated, enabled, tracker_id FROM vehicles;"
-	rows, err := v.db.Query(statement)
+	statement := "SELECT v.id, v.name, v.display_name, v.tags, v.created, v.updated, v.enabled, v.tracker_id, " +
+		trackerIDsColumn + ", " + scheduleColumn + " " +
+		"FROM vehicles v;"
+	rows, err := v.read.Query(statement)
 	if err != nil {
 		return vehicles, err
 	}
@@ -98,7 +243,7 @@ func (v *VehicleService) Vehicles() ([]*shuttletracker.Vehicle, error) {
 	// from the database
 	for rows.Next() {
 		vehicle := &shuttletracker.Vehicle{}
-		err := rows.Scan(&vehicle.ID, &vehicle.Name, &vehicle.Created, &vehicle.Updated, &vehicle.Enabled, &vehicle.TrackerID)
+		err := rows.Scan(&vehicle.ID, &vehicle.Name, &vehicle.DisplayName, pq.Array(&vehicle.Tags), scanUTC{&vehicle.Created}, scanUTC{&vehicle.Updated}, &vehicle.Enabled, &vehicle.TrackerID, pq.Array(&vehicle.TrackerIDs), scanSchedule{&vehicle.Schedule})
 		if err != nil {
 			return vehicles, err
 		}
@@ -114,9 +259,10 @@ func (v *VehicleService) EnabledVehicles() ([]*shuttletracker.Vehicle, error) {
 	var vehicles []*shuttletracker.Vehicle
 
 	// Postgres command that gets all vehicels with the var enabled set to true
-	statement := "SELECT id, name, created, updated, tracker_id " +
-		"FROM vehicles WHERE enabled = true;"
-	rows, err := v.db.Query(statement)
+	statement := "SELECT v.id, v.name, v.display_name, v.tags, v.created, v.updated, v.tracker_id, " +
+		trackerIDsColumn + ", " + scheduleColumn + " " +
+		"FROM vehicles v WHERE v.enabled = true;"
+	rows, err := v.read.Query(statement)
 	if err != nil {
 		return vehicles, err
 	}
@@ -127,7 +273,7 @@ func (v *VehicleService) EnabledVehicles() ([]*shuttletracker.Vehicle, error) {
 		vehicle := &shuttletracker.Vehicle{
 			Enabled: true,
 		}
-		err := rows.Scan(&vehicle.ID, &vehicle.Name, &vehicle.Created, &vehicle.Updated, &vehicle.TrackerID)
+		err := rows.Scan(&vehicle.ID, &vehicle.Name, &vehicle.DisplayName, pq.Array(&vehicle.Tags), scanUTC{&vehicle.Created}, scanUTC{&vehicle.Updated}, &vehicle.TrackerID, pq.Array(&vehicle.TrackerIDs), scanSchedule{&vehicle.Schedule})
 		if err != nil {
 			return vehicles, err
 		}
@@ -138,28 +284,250 @@ func (v *VehicleService) EnabledVehicles() ([]*shuttletracker.Vehicle, error) {
 	return vehicles, nil
 }
 
+// RecentlyActiveVehicles returns all enabled Vehicles that have a Location with a tracker time within
+// the provided duration of now.
+func (v *VehicleService) RecentlyActiveVehicles(within time.Duration) ([]*shuttletracker.Vehicle, error) {
+	vehicles := []*shuttletracker.Vehicle{}
+
+	statement := "SELECT v.id, v.name, v.display_name, v.tags, v.created, v.updated, v.tracker_id, " + trackerIDsColumn + ", " + scheduleColumn + " " +
+		"FROM vehicles v WHERE v.enabled = true AND EXISTS (" +
+		"SELECT 1 FROM locations l, vehicle_trackers t " +
+		"WHERE l.tracker_id = t.tracker_id AND t.vehicle_id = v.id AND l.time > $1);"
+	rows, err := v.read.Query(statement, time.Now().Add(-within))
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		vehicle := &shuttletracker.Vehicle{
+			Enabled: true,
+		}
+		err := rows.Scan(&vehicle.ID, &vehicle.Name, &vehicle.DisplayName, pq.Array(&vehicle.Tags), scanUTC{&vehicle.Created}, scanUTC{&vehicle.Updated}, &vehicle.TrackerID, pq.Array(&vehicle.TrackerIDs), scanSchedule{&vehicle.Schedule})
+		if err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+
+	return vehicles, nil
+}
+
+// VehiclesByRecentActivity returns all enabled Vehicles along with the Location with the latest tracker
+// time across their trackers, ordered from most to least recent. Vehicles that have never reported a
+// Location are last, ordered by ID, and have a nil Location.
+func (v *VehicleService) VehiclesByRecentActivity() ([]shuttletracker.VehicleActivity, error) {
+	activity := []shuttletracker.VehicleActivity{}
+
+	statement := "SELECT v.id, v.name, v.display_name, v.tags, v.created, v.updated, v.tracker_id, " + trackerIDsColumn + ", " + scheduleColumn + ", " +
+		"latest.id, latest.tracker_id, latest.latitude, latest.longitude, latest.heading, latest.speed, latest.time, " +
+		"latest.route_id, latest.trip_id, latest.route_version, latest.speed_derived, latest.reported_speed, latest.created " +
+		"FROM vehicles v LEFT JOIN LATERAL (" +
+		"SELECT l.* FROM locations l, vehicle_trackers t WHERE l.tracker_id = t.tracker_id AND t.vehicle_id = v.id " +
+		"ORDER BY l.time DESC LIMIT 1) latest ON true " +
+		"WHERE v.enabled = true ORDER BY latest.time DESC NULLS LAST, v.id;"
+	rows, err := v.read.Query(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		vehicle := &shuttletracker.Vehicle{
+			Enabled: true,
+		}
+		// the Location's columns are all null if the Vehicle has never reported
+		var (
+			locationID    *int64
+			trackerID     *string
+			latitude      *float64
+			longitude     *float64
+			heading       *float64
+			speed         *float64
+			locationTime  *time.Time
+			routeID       *int64
+			tripID        *int64
+			routeVersion  *int64
+			speedDerived  *bool
+			reportedSpeed *float64
+			created       *time.Time
+		)
+		err := rows.Scan(&vehicle.ID, &vehicle.Name, &vehicle.DisplayName, pq.Array(&vehicle.Tags), scanUTC{&vehicle.Created}, scanUTC{&vehicle.Updated}, &vehicle.TrackerID, pq.Array(&vehicle.TrackerIDs), scanSchedule{&vehicle.Schedule},
+			&locationID, &trackerID, &latitude, &longitude, &heading, &speed, &locationTime, &routeID, &tripID, &routeVersion, &speedDerived, &reportedSpeed, &created)
+		if err != nil {
+			return nil, err
+		}
+		va := shuttletracker.VehicleActivity{Vehicle: vehicle}
+		if locationID != nil {
+			id := vehicle.ID
+			va.Location = &shuttletracker.Location{
+				ID:            *locationID,
+				TrackerID:     *trackerID,
+				Latitude:      *latitude,
+				Longitude:     *longitude,
+				Heading:       *heading,
+				Speed:         *speed,
+				Time:          locationTime.UTC(),
+				Created:       created.UTC(),
+				VehicleID:     &id,
+				RouteID:       routeID,
+				TripID:        tripID,
+				RouteVersion:  routeVersion,
+				SpeedDerived:  *speedDerived,
+				ReportedSpeed: reportedSpeed,
+			}
+		}
+		activity = append(activity, va)
+	}
+
+	return activity, nil
+}
+
+// VehiclesModifiedSince returns all Vehicles that were created or modified after t, ordered from least
+// to most recently modified so that the last Vehicle's Updated time can be used for the next call.
+func (v *VehicleService) VehiclesModifiedSince(t time.Time) ([]*shuttletracker.Vehicle, error) {
+	vehicles := []*shuttletracker.Vehicle{}
+
+	statement := "SELECT v.id, v.name, v.display_name, v.tags, v.created, v.updated, v.enabled, v.tracker_id, " +
+		trackerIDsColumn + ", " + scheduleColumn + " " +
+		"FROM vehicles v WHERE v.updated > $1 ORDER BY v.updated ASC, v.id ASC;"
+	rows, err := v.db.Query(statement, t)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		vehicle := &shuttletracker.Vehicle{}
+		err := rows.Scan(&vehicle.ID, &vehicle.Name, &vehicle.DisplayName, pq.Array(&vehicle.Tags), scanUTC{&vehicle.Created}, scanUTC{&vehicle.Updated}, &vehicle.Enabled, &vehicle.TrackerID, pq.Array(&vehicle.TrackerIDs), scanSchedule{&vehicle.Schedule})
+		if err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+
+	return vehicles, nil
+}
+
+// VehiclesWithTag returns all Vehicles that have tag, ordered by ID.
+func (v *VehicleService) VehiclesWithTag(tag string) ([]*shuttletracker.Vehicle, error) {
+	vehicles := []*shuttletracker.Vehicle{}
+
+	statement := "SELECT v.id, v.name, v.display_name, v.tags, v.created, v.updated, v.enabled, v.tracker_id, " +
+		trackerIDsColumn + ", " + scheduleColumn + " " +
+		"FROM vehicles v WHERE $1 = ANY(v.tags) ORDER BY v.id;"
+	rows, err := v.read.Query(statement, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		vehicle := &shuttletracker.Vehicle{}
+		err := rows.Scan(&vehicle.ID, &vehicle.Name, &vehicle.DisplayName, pq.Array(&vehicle.Tags), scanUTC{&vehicle.Created}, scanUTC{&vehicle.Updated}, &vehicle.Enabled, &vehicle.TrackerID, pq.Array(&vehicle.TrackerIDs), scanSchedule{&vehicle.Schedule})
+		if err != nil {
+			return nil, err
+		}
+		vehicles = append(vehicles, vehicle)
+	}
+
+	return vehicles, nil
+}
+
 // ModifyVehicle updates a Vehicle by its ID.
 func (v *VehicleService) ModifyVehicle(vehicle *shuttletracker.Vehicle) error {
+	tx, err := v.db.Begin()
+	if err != nil {
+		return err
+	}
+	// We can't really do anything if rolling back a transaction fails.
+	// nolint: errcheck
+	defer tx.Rollback()
+
 	// Updates the vehicle from the parameter "vehicle", referenced from $_
-	statement := "UPDATE vehicles SET name = $1, enabled = $2, tracker_id = $3, updated = now() " +
-		"WHERE id = $4 RETURNING updated;"
-	row := v.db.QueryRow(statement, vehicle.Name, vehicle.Enabled, vehicle.TrackerID, vehicle.ID)
-	err := row.Scan(&vehicle.Updated)
-	return err
+	vehicle.Tags = tagSet(vehicle.Tags)
+	statement := "UPDATE vehicles SET name = $1, display_name = $2, tags = $3, enabled = $4, tracker_id = $5, updated = now() " +
+		"WHERE id = $6 RETURNING updated;"
+	row := tx.QueryRow(statement, vehicle.Name, vehicle.DisplayName, pq.Array(vehicle.Tags), vehicle.Enabled, vehicle.TrackerID, vehicle.ID)
+	err = row.Scan(scanUTC{&vehicle.Updated})
+	if err != nil {
+		return err
+	}
+
+	err = setTrackers(tx, vehicle)
+	if err != nil {
+		return err
+	}
+
+	err = setSchedule(tx, vehicle)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-// VehicleWithTrackerID returns the Vehicle with the specified tracker ID.
-func (v *VehicleService) VehicleWithTrackerID(id string) (*shuttletracker.Vehicle, error) {
-	vehicle := &shuttletracker.Vehicle{
-		TrackerID: id,
+// RepairVehicleTimestamps moves the updated time of every Vehicle that is missing one or that is
+// before its created time up to its created time, and returns the number of Vehicles repaired. Repaired
+// Vehicles no longer match, so running it again has no effect.
+func (v *VehicleService) RepairVehicleTimestamps() (int64, error) {
+	statement := "UPDATE vehicles SET updated = GREATEST(created, COALESCE(updated, created)) " +
+		"WHERE updated IS NULL OR updated < created;"
+	res, err := v.db.Exec(statement)
+	if err != nil {
+		return 0, err
 	}
-	statement := "SELECT id, name, created, updated, enabled " +
-		"FROM vehicles WHERE tracker_id = $1;"
+	return res.RowsAffected()
+}
+
+// VehicleWithTrackerID returns the Vehicle that owns the specified tracker ID.
+func (v *VehicleService) VehicleWithTrackerID(id string) (*shuttletracker.Vehicle, error) {
+	vehicle := &shuttletracker.Vehicle{}
+	statement := "SELECT v.id, v.name, v.display_name, v.tags, v.created, v.updated, v.enabled, v.tracker_id, " +
+		trackerIDsColumn + ", " + scheduleColumn + " " +
+		"FROM vehicles v JOIN vehicle_trackers vt ON vt.vehicle_id = v.id WHERE vt.tracker_id = $1;"
 	row := v.db.QueryRow(statement, id)
-	err := row.Scan(&vehicle.ID, &vehicle.Name, &vehicle.Created, &vehicle.Updated, &vehicle.Enabled)
+	err := row.Scan(&vehicle.ID, &vehicle.Name, &vehicle.DisplayName, pq.Array(&vehicle.Tags), scanUTC{&vehicle.Created}, scanUTC{&vehicle.Updated}, &vehicle.Enabled, &vehicle.TrackerID, pq.Array(&vehicle.TrackerIDs), scanSchedule{&vehicle.Schedule})
 	if err == sql.ErrNoRows {
+		vehicle.TrackerID = id
 		return vehicle, shuttletracker.ErrVehicleNotFound
 	}
 
 	return vehicle, err
 }
+
+// MergeVehicles moves every tracker ID of the Vehicle with mergeID onto the Vehicle with keepID
+// and then deletes the merged Vehicle. Since locations are attributed to Vehicles through their
+// trackers, the merged Vehicle's location history now belongs to the kept Vehicle.
+func (v *VehicleService) MergeVehicles(keepID, mergeID int64) error {
+	if keepID == mergeID {
+		return errors.New("cannot merge a vehicle into itself")
+	}
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return err
+	}
+	// We can't really do anything if rolling back a transaction fails.
+	// nolint: errcheck
+	defer tx.Rollback()
+
+	for _, id := range []int64{keepID, mergeID} {
+		var exists bool
+		row := tx.QueryRow("SELECT true FROM vehicles WHERE id = $1 FOR UPDATE;", id)
+		err = row.Scan(&exists)
+		if err == sql.ErrNoRows {
+			return shuttletracker.ErrVehicleNotFound
+		} else if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE vehicle_trackers SET vehicle_id = $1 WHERE vehicle_id = $2;", keepID, mergeID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM vehicles WHERE id = $1;", mergeID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,470 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// nolint: gocyclo
+func TestMergeVehicles(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	// insert test data
+	keep := &shuttletracker.Vehicle{
+		Name:      "keep vehicle",
+		Enabled:   true,
+		TrackerID: "tracker1",
+	}
+	err := pg.CreateVehicle(keep)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	merge := &shuttletracker.Vehicle{
+		Name:      "merge vehicle",
+		Enabled:   true,
+		TrackerID: "tracker2",
+	}
+	err = pg.CreateVehicle(merge)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+
+	sharedTime := time.Now().Add(-time.Minute)
+	locations := []*shuttletracker.Location{
+		{TrackerID: "tracker1", Time: sharedTime},
+		{TrackerID: "tracker2", Time: sharedTime},
+		{TrackerID: "tracker2", Time: time.Now()},
+	}
+	for _, location := range locations {
+		err = pg.CreateLocation(location)
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	err = pg.MergeVehicles(keep.ID, merge.ID)
+	if err != nil {
+		t.Fatalf("unable to merge Vehicles: %s", err)
+	}
+
+	_, err = pg.Vehicle(merge.ID)
+	if err != shuttletracker.ErrVehicleNotFound {
+		t.Errorf("got error %v, expected %v", err, shuttletracker.ErrVehicleNotFound)
+	}
+
+	actuals, err := pg.LocationsSince(keep.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("unable to get Locations: %s", err)
+	}
+	if len(actuals) != 3 {
+		t.Errorf("got %d Locations, expected 3", len(actuals))
+	}
+
+	vehicle, err := pg.VehicleWithTrackerID("tracker2")
+	if err != nil {
+		t.Fatalf("unable to get Vehicle: %s", err)
+	}
+	if vehicle.ID != keep.ID {
+		t.Errorf("got vehicle ID %d, expected %d", vehicle.ID, keep.ID)
+	}
+
+	err = pg.MergeVehicles(keep.ID, merge.ID)
+	if err != shuttletracker.ErrVehicleNotFound {
+		t.Errorf("got error %v, expected %v", err, shuttletracker.ErrVehicleNotFound)
+	}
+}
+
+func TestVehicleWithSecondaryTrackerID(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:       "test vehicle",
+		Enabled:    true,
+		TrackerID:  "primary",
+		TrackerIDs: []string{"backup"},
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	if len(vehicle.TrackerIDs) != 2 {
+		t.Errorf("got %d tracker IDs, expected 2", len(vehicle.TrackerIDs))
+	}
+
+	for _, trackerID := range []string{"primary", "backup"} {
+		actual, err := pg.VehicleWithTrackerID(trackerID)
+		if err != nil {
+			t.Fatalf("unable to get Vehicle with tracker ID %s: %s", trackerID, err)
+		}
+		if actual.ID != vehicle.ID {
+			t.Errorf("got vehicle ID %d for tracker ID %s, expected %d", actual.ID, trackerID, vehicle.ID)
+		}
+	}
+
+	// removing the backup tracker should leave only the primary
+	vehicle.TrackerIDs = nil
+	err = pg.ModifyVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to modify Vehicle: %s", err)
+	}
+	_, err = pg.VehicleWithTrackerID("backup")
+	if err != shuttletracker.ErrVehicleNotFound {
+		t.Errorf("got error %v, expected %v", err, shuttletracker.ErrVehicleNotFound)
+	}
+}
+
+func TestVehicleSchedule(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	schedule := shuttletracker.VehicleSchedule{
+		{StartDay: time.Monday, StartTime: "07:00", EndDay: time.Monday, EndTime: "23:30"},
+		{StartDay: time.Saturday, StartTime: "22:00", EndDay: time.Sunday, EndTime: "02:00"},
+	}
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "test vehicle",
+		Enabled:   true,
+		TrackerID: "tracker1",
+		Schedule:  schedule,
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+
+	actual, err := pg.Vehicle(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to get Vehicle: %s", err)
+	}
+	if !reflect.DeepEqual(actual.Schedule, schedule) {
+		t.Errorf("got schedule %+v, expected %+v", actual.Schedule, schedule)
+	}
+
+	vehicle.Schedule = shuttletracker.VehicleSchedule{}
+	err = pg.ModifyVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to modify Vehicle: %s", err)
+	}
+	actual, err = pg.Vehicle(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to get Vehicle: %s", err)
+	}
+	if len(actual.Schedule) != 0 {
+		t.Errorf("got schedule %+v, expected none", actual.Schedule)
+	}
+
+	vehicle.Schedule = shuttletracker.VehicleSchedule{{StartDay: time.Monday, StartTime: "7am"}}
+	err = pg.ModifyVehicle(vehicle)
+	if err == nil {
+		t.Error("expected an error for an invalid schedule")
+	}
+}
+
+func TestVehicleDisplayName(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	vehicle := &shuttletracker.Vehicle{
+		Name:      "BUS-0417-TRK",
+		Enabled:   true,
+		TrackerID: "0417",
+	}
+	err := pg.CreateVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to create Vehicle: %s", err)
+	}
+	actual, err := pg.Vehicle(vehicle.ID)
+	if err != nil {
+		t.Fatalf("unable to get Vehicle: %s", err)
+	}
+	if actual.DisplayName != "" || actual.PublicName() != vehicle.Name {
+		t.Errorf("got display name %q and public name %q, expected the internal name", actual.DisplayName, actual.PublicName())
+	}
+
+	vehicle.DisplayName = "Shuttle 4"
+	err = pg.ModifyVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unable to modify Vehicle: %s", err)
+	}
+	vehicles, err := pg.Vehicles()
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	if len(vehicles) != 1 || vehicles[0].DisplayName != "Shuttle 4" || vehicles[0].Name != "BUS-0417-TRK" {
+		t.Errorf("got %+v, expected the display name to be stored", vehicles)
+	}
+}
+
+func TestVehiclesModifiedSince(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	first := &shuttletracker.Vehicle{Name: "Vehicle 1", Enabled: true, TrackerID: "1"}
+	second := &shuttletracker.Vehicle{Name: "Vehicle 2", Enabled: true, TrackerID: "2"}
+	for _, vehicle := range []*shuttletracker.Vehicle{first, second} {
+		err := pg.CreateVehicle(vehicle)
+		if err != nil {
+			t.Fatalf("unable to create Vehicle: %s", err)
+		}
+	}
+
+	vehicles, err := pg.VehiclesModifiedSince(time.Time{})
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	if len(vehicles) != 2 {
+		t.Fatalf("got %d Vehicles, expected 2", len(vehicles))
+	}
+	cursor := vehicles[1].Updated
+
+	vehicles, err = pg.VehiclesModifiedSince(cursor)
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	if len(vehicles) != 0 {
+		t.Errorf("got %+v, expected no Vehicles to have changed", vehicles)
+	}
+
+	first.Name = "Vehicle 1 renamed"
+	err = pg.ModifyVehicle(first)
+	if err != nil {
+		t.Fatalf("unable to modify Vehicle: %s", err)
+	}
+	vehicles, err = pg.VehiclesModifiedSince(cursor)
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	if len(vehicles) != 1 || vehicles[0].ID != first.ID || vehicles[0].Name != first.Name {
+		t.Errorf("got %+v, expected only the renamed Vehicle", vehicles)
+	}
+}
+
+func TestRepairVehicleTimestamps(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	broken := &shuttletracker.Vehicle{Name: "Vehicle 1", Enabled: true, TrackerID: "1"}
+	fine := &shuttletracker.Vehicle{Name: "Vehicle 2", Enabled: true, TrackerID: "2"}
+	for _, vehicle := range []*shuttletracker.Vehicle{broken, fine} {
+		err := pg.CreateVehicle(vehicle)
+		if err != nil {
+			t.Fatalf("unable to create Vehicle: %s", err)
+		}
+	}
+	_, err := pg.VehicleService.db.Exec("UPDATE vehicles SET updated = created - interval '1 day' WHERE id = $1;", broken.ID)
+	if err != nil {
+		t.Fatalf("unable to break Vehicle: %s", err)
+	}
+
+	n, err := pg.RepairVehicleTimestamps()
+	if err != nil {
+		t.Fatalf("unable to repair timestamps: %s", err)
+	}
+	if n != 1 {
+		t.Errorf("repaired %d Vehicles, expected 1", n)
+	}
+	actual, err := pg.Vehicle(broken.ID)
+	if err != nil {
+		t.Fatalf("unable to get Vehicle: %s", err)
+	}
+	if !actual.Updated.Equal(actual.Created) {
+		t.Errorf("got updated %s, expected created %s", actual.Updated, actual.Created)
+	}
+
+	n, err = pg.RepairVehicleTimestamps()
+	if err != nil {
+		t.Fatalf("unable to repair timestamps: %s", err)
+	}
+	if n != 0 {
+		t.Errorf("repaired %d Vehicles the second time, expected 0", n)
+	}
+}
+
+func TestVehiclesWithTag(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	accessible := &shuttletracker.Vehicle{Name: "Vehicle 1", Enabled: true, TrackerID: "1", Tags: []string{"accessible", "charter", "accessible", ""}}
+	other := &shuttletracker.Vehicle{Name: "Vehicle 2", Enabled: true, TrackerID: "2"}
+	for _, vehicle := range []*shuttletracker.Vehicle{accessible, other} {
+		err := pg.CreateVehicle(vehicle)
+		if err != nil {
+			t.Fatalf("unable to create Vehicle: %s", err)
+		}
+	}
+
+	vehicles, err := pg.VehiclesWithTag("accessible")
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	if len(vehicles) != 1 || vehicles[0].ID != accessible.ID {
+		t.Fatalf("got %+v, expected only the accessible Vehicle", vehicles)
+	}
+	if !reflect.DeepEqual(vehicles[0].Tags, []string{"accessible", "charter"}) {
+		t.Errorf("got tags %v, expected duplicate and empty tags to be dropped", vehicles[0].Tags)
+	}
+
+	actual, err := pg.Vehicle(other.ID)
+	if err != nil {
+		t.Fatalf("unable to get Vehicle: %s", err)
+	}
+	if actual.Tags == nil || len(actual.Tags) != 0 {
+		t.Errorf("got tags %#v, expected none", actual.Tags)
+	}
+
+	accessible.Tags = []string{"maintenance"}
+	err = pg.ModifyVehicle(accessible)
+	if err != nil {
+		t.Fatalf("unable to modify Vehicle: %s", err)
+	}
+	vehicles, err = pg.VehiclesWithTag("accessible")
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	if len(vehicles) != 0 {
+		t.Errorf("got %+v, expected the tag to be removed", vehicles)
+	}
+	vehicles, err = pg.VehiclesWithTag("maintenance")
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	if len(vehicles) != 1 || vehicles[0].ID != accessible.ID {
+		t.Errorf("got %+v, expected the modified Vehicle", vehicles)
+	}
+}
+
+func TestRecentlyActiveVehicles(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	fresh := &shuttletracker.Vehicle{Name: "fresh", Enabled: true, TrackerID: "fresh"}
+	stale := &shuttletracker.Vehicle{Name: "stale", Enabled: true, TrackerID: "stale"}
+	disabled := &shuttletracker.Vehicle{Name: "disabled", Enabled: false, TrackerID: "disabled"}
+	unseen := &shuttletracker.Vehicle{Name: "unseen", Enabled: true, TrackerID: "unseen"}
+	for _, vehicle := range []*shuttletracker.Vehicle{fresh, stale, disabled, unseen} {
+		err := pg.CreateVehicle(vehicle)
+		if err != nil {
+			t.Fatalf("unable to create Vehicle: %s", err)
+		}
+	}
+
+	now := time.Now()
+	for _, l := range []*shuttletracker.Location{
+		{TrackerID: "fresh", Time: now.Add(-time.Minute)},
+		{TrackerID: "fresh", Time: now.Add(-time.Hour)},
+		{TrackerID: "stale", Time: now.Add(-time.Hour)},
+		{TrackerID: "disabled", Time: now.Add(-time.Minute)},
+	} {
+		err := pg.CreateLocation(l)
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	vehicles, err := pg.RecentlyActiveVehicles(time.Minute * 5)
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	if len(vehicles) != 1 || vehicles[0].ID != fresh.ID {
+		t.Fatalf("got %+v, expected only the Vehicle with a fresh Location", vehicles)
+	}
+	if !vehicles[0].Enabled || vehicles[0].TrackerID != "fresh" {
+		t.Errorf("got %+v, expected the fresh Vehicle's fields", vehicles[0])
+	}
+}
+
+func TestVehiclesByRecentActivity(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	pg := setUpPostgres(t)
+	defer tearDownPostgres(t)
+
+	older := &shuttletracker.Vehicle{Name: "older", Enabled: true, TrackerID: "older"}
+	newer := &shuttletracker.Vehicle{Name: "newer", Enabled: true, TrackerID: "newer", TrackerIDs: []string{"backup"}}
+	unseen := &shuttletracker.Vehicle{Name: "unseen", Enabled: true, TrackerID: "unseen"}
+	disabled := &shuttletracker.Vehicle{Name: "disabled", Enabled: false, TrackerID: "disabled"}
+	for _, vehicle := range []*shuttletracker.Vehicle{unseen, older, newer, disabled} {
+		err := pg.CreateVehicle(vehicle)
+		if err != nil {
+			t.Fatalf("unable to create Vehicle: %s", err)
+		}
+	}
+
+	now := time.Now()
+	for _, l := range []*shuttletracker.Location{
+		{TrackerID: "newer", Time: now.Add(-time.Minute * 2)},
+		{TrackerID: "backup", Time: now.Add(-time.Minute), Latitude: 42.73},
+		{TrackerID: "older", Time: now.Add(-time.Hour)},
+		{TrackerID: "disabled", Time: now},
+		// a late-arriving record doesn't make its vehicle more recently active
+		{TrackerID: "older", Time: now.Add(-time.Hour * 2)},
+	} {
+		err := pg.CreateLocation(l)
+		if err != nil {
+			t.Fatalf("unable to create Location: %s", err)
+		}
+	}
+
+	activity, err := pg.VehiclesByRecentActivity()
+	if err != nil {
+		t.Fatalf("unable to get Vehicles: %s", err)
+	}
+	expected := []int64{newer.ID, older.ID, unseen.ID}
+	if len(activity) != len(expected) {
+		t.Fatalf("got %d Vehicles, expected %d", len(activity), len(expected))
+	}
+	for i, id := range expected {
+		if activity[i].Vehicle.ID != id {
+			t.Errorf("got Vehicle %d at position %d, expected %d", activity[i].Vehicle.ID, i, id)
+		}
+	}
+	location := activity[0].Location
+	if location == nil || location.TrackerID != "backup" || location.Latitude != 42.73 || *location.VehicleID != newer.ID {
+		t.Errorf("got %+v, expected the backup tracker's Location", location)
+	}
+	if d := activity[1].Location.Time.Sub(now.Add(-time.Hour)); d > time.Microsecond || d < -time.Microsecond {
+		t.Errorf("got time %v, expected the older Vehicle's latest tracker time", activity[1].Location.Time)
+	}
+	if activity[2].Location != nil {
+		t.Errorf("got %+v, expected no Location for a Vehicle that has never reported", activity[2].Location)
+	}
+}
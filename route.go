@@ -19,6 +19,41 @@ type Route struct {
 	Points      []Point       `json:"points"`
 	Active      bool          `json:"active"`
 	Schedule    RouteSchedule `json:"schedule"`
+
+	// SpeedLimit is the maximum speed in miles per hour allowed on this Route. Zero means no limit.
+	SpeedLimit float64 `json:"speed_limit"`
+
+	// Version starts at 1 and increases whenever the Route's Points or Branches change.
+	Version int64 `json:"version"`
+
+	// Branches are parts of the Route that Points can't represent as a single ordered list, such as
+	// a short detour that only some trips serve.
+	Branches []RouteBranch `json:"branches"`
+}
+
+// RouteBranch is a named polyline that belongs to a Route in addition to the Route's Points.
+type RouteBranch struct {
+	ID     int64   `json:"id"`
+	Name   string  `json:"name"`
+	Points []Point `json:"points"`
+}
+
+// Polylines returns the Route's Points followed by the Points of each of its Branches.
+func (r *Route) Polylines() [][]Point {
+	polylines := [][]Point{r.Points}
+	for _, branch := range r.Branches {
+		polylines = append(polylines, branch.Points)
+	}
+	return polylines
+}
+
+// RouteWithCount is a Route along with how many vehicles are currently on it.
+type RouteWithCount struct {
+	Route *Route `json:"route"`
+
+	// VehicleCount is the number of enabled vehicles whose latest Location is on the Route and was
+	// recorded within the duration passed to RoutesWithVehicleCounts.
+	VehicleCount int `json:"vehicle_count"`
 }
 
 // RouteActiveInterval represents a time interval during which a Route is active.
@@ -34,16 +69,55 @@ type RouteActiveInterval struct {
 // RouteSchedule represents multiple time intervals during which a Route is active.
 type RouteSchedule []RouteActiveInterval
 
+// TimetableEntry is a scheduled arrival of a Route at one of its Stops. Time is a wall clock time
+// formatted as "15:04" in the campus time zone.
+type TimetableEntry struct {
+	StopID int64        `json:"stop_id"`
+	Day    time.Weekday `json:"day"`
+	Time   string       `json:"time"`
+}
+
+// Timetable contains the scheduled arrivals of a Route.
+type Timetable []TimetableEntry
+
+// Validate returns an error if any entry in the Timetable has an invalid day or time.
+func (t Timetable) Validate() error {
+	for _, entry := range t {
+		if _, err := minuteOfWeek(entry.Day, entry.Time); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Point represents a latitude/longitude pair.
 type Point struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
+
+	// Elevation is the height in meters above sea level, or nil if it isn't known.
+	Elevation *float64 `json:"elevation,omitempty"`
+
+	// Index is the Point's position in its Route's polyline when the Route is loaded, or nil for Points
+	// that weren't loaded from a Route. It is a pointer so that the first Point's index of zero is told
+	// apart from no index. Points are stored in the order of their slice, so it is ignored when saving a
+	// Route. It isn't part of a Point's position, so compare Latitude and Longitude rather than whole
+	// Points.
+	Index *int `json:"index,omitempty"`
 }
 
 // RouteService is an interface for interacting with Routes.
 type RouteService interface {
 	Route(id int64) (*Route, error)
 	Routes() ([]*Route, error)
+	RoutesForStop(stopID int64) ([]*Route, error)
+	RoutesWithVehicleCounts(within time.Duration) ([]RouteWithCount, error)
+	CurrentRoute(vehicleID int64) (*Route, error)
+	SetCurrentRoute(vehicleID int64, routeID *int64, confidence float64) error
+	ScheduledRoutes() (map[int64]int64, error)
+	SetScheduledRoute(vehicleID int64, routeID *int64) error
+	Timetable(routeID int64) (Timetable, error)
+	SetTimetable(routeID int64, timetable Timetable) error
 	CreateRoute(route *Route) error
 	DeleteRoute(id int64) error
 	ModifyRoute(route *Route) error
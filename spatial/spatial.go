@@ -0,0 +1,303 @@
+// Package spatial provides geometry helpers for coordinates on the Earth's surface.
+package spatial
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// EarthRadius is the mean radius of the Earth in meters.
+const EarthRadius = 6371000.0
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// DistanceBetween returns the great-circle distance in meters between two Points.
+func DistanceBetween(p1, p2 shuttletracker.Point) float64 {
+	lat1 := toRadians(p1.Latitude)
+	lat2 := toRadians(p2.Latitude)
+	dLat := lat2 - lat1
+	dLng := toRadians(p2.Longitude - p1.Longitude)
+
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Length returns the length of a polyline in meters.
+func Length(polyline []shuttletracker.Point) float64 {
+	length := 0.0
+	for i := 1; i < len(polyline); i++ {
+		length += DistanceBetween(polyline[i-1], polyline[i])
+	}
+	return length
+}
+
+// Projection describes the point on a polyline that is closest to some other point.
+type Projection struct {
+	// Segment is the index of the first point of the polyline segment containing the projection.
+	Segment int
+
+	// Fraction is how far along the segment the projection lies, from 0 to 1.
+	Fraction float64
+
+	// Distance is the distance in meters from the projected point to the polyline.
+	Distance float64
+
+	// Along is the distance in meters along the polyline from its first point to the projection.
+	Along float64
+}
+
+// planar returns p's position in meters on a plane tangent to the Earth at origin.
+// This is accurate over the few kilometers spanned by a route.
+func planar(origin, p shuttletracker.Point) (x, y float64) {
+	x = toRadians(p.Longitude-origin.Longitude) * math.Cos(toRadians(origin.Latitude)) * EarthRadius
+	y = toRadians(p.Latitude-origin.Latitude) * EarthRadius
+	return x, y
+}
+
+// Project finds the point on a polyline closest to p. A polyline with a single point is treated
+// as a segment of zero length. An empty polyline results in an infinite Distance.
+func Project(p shuttletracker.Point, polyline []shuttletracker.Point) Projection {
+	best := Projection{Distance: math.Inf(0)}
+	if len(polyline) == 1 {
+		best.Distance = DistanceBetween(p, polyline[0])
+		return best
+	}
+
+	along := 0.0
+	for i := 0; i+1 < len(polyline); i++ {
+		ax, ay := planar(p, polyline[i])
+		bx, by := planar(p, polyline[i+1])
+		dx, dy := bx-ax, by-ay
+
+		// find the fraction along AB closest to p, which is the origin of the plane
+		t := 0.0
+		if lengthSquared := dx*dx + dy*dy; lengthSquared > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSquared))
+		}
+		distance := math.Hypot(ax+t*dx, ay+t*dy)
+		segmentLength := DistanceBetween(polyline[i], polyline[i+1])
+		if distance < best.Distance {
+			best = Projection{
+				Segment:  i,
+				Fraction: t,
+				Distance: distance,
+				Along:    along + t*segmentLength,
+			}
+		}
+		along += segmentLength
+	}
+	return best
+}
+
+// IsLoop returns whether a polyline ends within tolerance meters of where it starts.
+func IsLoop(polyline []shuttletracker.Point, tolerance float64) bool {
+	if len(polyline) < 3 {
+		return false
+	}
+	return DistanceBetween(polyline[0], polyline[len(polyline)-1]) <= tolerance
+}
+
+// Simplify reduces the number of points in a polyline using the Douglas-Peucker algorithm. Points are
+// removed as long as the simplified polyline stays within tolerance meters of every removed point.
+// The first and last points are always kept, and a tolerance of zero returns the full polyline.
+func Simplify(polyline []shuttletracker.Point, tolerance float64) []shuttletracker.Point {
+	if tolerance <= 0 || len(polyline) < 3 {
+		return append([]shuttletracker.Point{}, polyline...)
+	}
+
+	keep := make([]bool, len(polyline))
+	keep[0] = true
+	keep[len(polyline)-1] = true
+	simplify(polyline, 0, len(polyline)-1, tolerance, keep)
+
+	simplified := []shuttletracker.Point{}
+	for i, p := range polyline {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+// simplify marks the points between first and last that must be kept.
+func simplify(polyline []shuttletracker.Point, first, last int, tolerance float64, keep []bool) {
+	if last-first < 2 {
+		return
+	}
+	segment := []shuttletracker.Point{polyline[first], polyline[last]}
+	furthest := first
+	furthestDistance := 0.0
+	for i := first + 1; i < last; i++ {
+		// a closed polyline's endpoints coincide, so this is then the distance to that point
+		d := Project(polyline[i], segment).Distance
+		if d > furthestDistance {
+			furthest = i
+			furthestDistance = d
+		}
+	}
+	if furthestDistance <= tolerance {
+		return
+	}
+	keep[furthest] = true
+	simplify(polyline, first, furthest, tolerance, keep)
+	simplify(polyline, furthest, last, tolerance, keep)
+}
+
+// Crossing is a place where two non-adjacent segments of a polyline cross. Segments are identified by
+// the index of their first point, and First is less than Second.
+type Crossing struct {
+	First  int
+	Second int
+}
+
+// Crossings finds the segments of a polyline that cross each other. Segments that only touch, like
+// those sharing a point or the ends of a closed polyline, don't count.
+func Crossings(polyline []shuttletracker.Point) []Crossing {
+	crossings := []Crossing{}
+	if len(polyline) < 4 {
+		return crossings
+	}
+	origin := polyline[0]
+	xs := make([]float64, len(polyline))
+	ys := make([]float64, len(polyline))
+	for i, p := range polyline {
+		xs[i], ys[i] = planar(origin, p)
+	}
+
+	// orientation is positive if p, q, r turn counterclockwise and negative if clockwise
+	orientation := func(p, q, r int) float64 {
+		return (xs[q]-xs[p])*(ys[r]-ys[p]) - (ys[q]-ys[p])*(xs[r]-xs[p])
+	}
+	for i := 0; i+1 < len(polyline); i++ {
+		for j := i + 2; j+1 < len(polyline); j++ {
+			d1 := orientation(i, i+1, j)
+			d2 := orientation(i, i+1, j+1)
+			d3 := orientation(j, j+1, i)
+			d4 := orientation(j, j+1, i+1)
+			if d1*d2 < 0 && d3*d4 < 0 {
+				crossings = append(crossings, Crossing{First: i, Second: j})
+			}
+		}
+	}
+	return crossings
+}
+
+// Centroid returns the spherical mean of points, which is the point on the Earth's surface nearest to
+// the average of their positions in three dimensions. Unlike averaging latitudes and longitudes, this
+// is correct for points on either side of the antimeridian. The centroid of no points is the zero Point.
+func Centroid(points []shuttletracker.Point) shuttletracker.Point {
+	var x, y, z float64
+	for _, p := range points {
+		lat := toRadians(p.Latitude)
+		lng := toRadians(p.Longitude)
+		x += math.Cos(lat) * math.Cos(lng)
+		y += math.Cos(lat) * math.Sin(lng)
+		z += math.Sin(lat)
+	}
+	if len(points) == 0 {
+		return shuttletracker.Point{}
+	}
+	return shuttletracker.Point{
+		Latitude:  math.Atan2(z, math.Hypot(x, y)) * 180 / math.Pi,
+		Longitude: math.Atan2(y, x) * 180 / math.Pi,
+	}
+}
+
+// Bearing returns the initial direction of travel from (lat1, lng1) to (lat2, lng2) in degrees
+// clockwise from north, from 0 up to 360.
+func Bearing(lat1, lng1, lat2, lng2 float64) float64 {
+	dLng := toRadians(lng2 - lng1)
+	lat1 = toRadians(lat1)
+	lat2 = toRadians(lat2)
+
+	y := math.Sin(dLng) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// CollapseLocations merges each run of consecutive Locations that stay within distance meters and
+// within of the run's first Location into that first Location, which thins out the near-identical
+// Locations of an idling vehicle. Locations may be ordered either way in time, and each run keeps
+// the Location that comes first in that order, so oldest to newest keeps the oldest. A distance of zero
+// returns every Location, and a within of zero doesn't limit how long a run may last. The Locations
+// themselves are not modified.
+func CollapseLocations(locations []*shuttletracker.Location, distance float64, within time.Duration) []*shuttletracker.Location {
+	collapsed := []*shuttletracker.Location{}
+	var first *shuttletracker.Location
+	for _, l := range locations {
+		if first != nil && distance > 0 {
+			elapsed := l.Time.Sub(first.Time)
+			if elapsed < 0 {
+				elapsed = -elapsed
+			}
+			p1 := shuttletracker.Point{Latitude: first.Latitude, Longitude: first.Longitude}
+			p2 := shuttletracker.Point{Latitude: l.Latitude, Longitude: l.Longitude}
+			if DistanceBetween(p1, p2) <= distance && (within == 0 || elapsed <= within) {
+				continue
+			}
+		}
+		first = l
+		collapsed = append(collapsed, l)
+	}
+	return collapsed
+}
+
+type byPosition []shuttletracker.Point
+
+func (p byPosition) Len() int { return len(p) }
+func (p byPosition) Less(i, j int) bool {
+	if p[i].Longitude != p[j].Longitude {
+		return p[i].Longitude < p[j].Longitude
+	}
+	return p[i].Latitude < p[j].Latitude
+}
+func (p byPosition) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// ConvexHull returns the vertices of the smallest convex polygon containing points, counterclockwise
+// from the westernmost point. Latitudes and longitudes are treated as planar coordinates, which is
+// accurate over the few kilometers spanned by a route but not across the antimeridian. Points with the
+// same coordinates are duplicates regardless of their other fields, and only the first is kept. If
+// fewer than three distinct points remain they are returned as they are. Points that all lie on a line
+// result in its two ends.
+func ConvexHull(points []shuttletracker.Point) []shuttletracker.Point {
+	sorted := append([]shuttletracker.Point{}, points...)
+	sort.Stable(byPosition(sorted))
+	distinct := []shuttletracker.Point{}
+	for i, p := range sorted {
+		if i == 0 || p.Latitude != sorted[i-1].Latitude || p.Longitude != sorted[i-1].Longitude {
+			distinct = append(distinct, p)
+		}
+	}
+	if len(distinct) < 3 {
+		return distinct
+	}
+
+	// cross is positive if o, a, b turn counterclockwise
+	cross := func(o, a, b shuttletracker.Point) float64 {
+		return (a.Longitude-o.Longitude)*(b.Latitude-o.Latitude) - (a.Latitude-o.Latitude)*(b.Longitude-o.Longitude)
+	}
+
+	// Andrew's monotone chain builds the lower hull west to east, then the upper hull back again
+	hull := []shuttletracker.Point{}
+	for _, p := range distinct {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(distinct) - 2; i >= 0; i-- {
+		p := distinct[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	// the upper hull ends where the lower one started
+	return hull[:len(hull)-1]
+}
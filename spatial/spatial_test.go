@@ -0,0 +1,259 @@
+package spatial
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+func TestDistanceBetween(t *testing.T) {
+	// one degree of latitude is about 111.2 km
+	d := DistanceBetween(shuttletracker.Point{Latitude: 42, Longitude: -73}, shuttletracker.Point{Latitude: 43, Longitude: -73})
+	if math.Abs(d-111195) > 10 {
+		t.Errorf("got distance %f, expected about 111195", d)
+	}
+
+	d = DistanceBetween(shuttletracker.Point{Latitude: 42.73, Longitude: -73.68}, shuttletracker.Point{Latitude: 42.73, Longitude: -73.68})
+	if d != 0 {
+		t.Errorf("got distance %f, expected 0", d)
+	}
+}
+
+func TestProject(t *testing.T) {
+	polyline := []shuttletracker.Point{
+		{Latitude: 42.73, Longitude: -73.68},
+		{Latitude: 42.73, Longitude: -73.67},
+		{Latitude: 42.74, Longitude: -73.67},
+	}
+	first := DistanceBetween(polyline[0], polyline[1])
+
+	// halfway along the first segment, slightly north of it
+	proj := Project(shuttletracker.Point{Latitude: 42.7301, Longitude: -73.675}, polyline)
+	if proj.Segment != 0 {
+		t.Errorf("got segment %d, expected 0", proj.Segment)
+	}
+	if math.Abs(proj.Fraction-0.5) > 0.01 {
+		t.Errorf("got fraction %f, expected 0.5", proj.Fraction)
+	}
+	if math.Abs(proj.Distance-11.1) > 0.5 {
+		t.Errorf("got distance %f, expected about 11.1", proj.Distance)
+	}
+	if math.Abs(proj.Along-first/2) > 1 {
+		t.Errorf("got along %f, expected %f", proj.Along, first/2)
+	}
+
+	// beyond the end of the polyline projects onto its last point
+	proj = Project(shuttletracker.Point{Latitude: 42.75, Longitude: -73.67}, polyline)
+	if proj.Segment != 1 || proj.Fraction != 1 {
+		t.Errorf("got segment %d fraction %f, expected segment 1 fraction 1", proj.Segment, proj.Fraction)
+	}
+	if math.Abs(proj.Along-Length(polyline)) > 1 {
+		t.Errorf("got along %f, expected %f", proj.Along, Length(polyline))
+	}
+
+	proj = Project(shuttletracker.Point{}, nil)
+	if !math.IsInf(proj.Distance, 1) {
+		t.Errorf("got distance %f for empty polyline, expected +Inf", proj.Distance)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	polyline := []shuttletracker.Point{
+		{Latitude: 42.73, Longitude: -73.68},
+		{Latitude: 42.73001, Longitude: -73.675}, // about 1 m off the straight line
+		{Latitude: 42.73, Longitude: -73.67},
+		{Latitude: 42.74, Longitude: -73.67},
+	}
+
+	simplified := Simplify(polyline, 5)
+	expected := []shuttletracker.Point{polyline[0], polyline[2], polyline[3]}
+	if len(simplified) != len(expected) {
+		t.Fatalf("got %d points, expected %d", len(simplified), len(expected))
+	}
+	for i := range expected {
+		if simplified[i] != expected[i] {
+			t.Errorf("got point %d %+v, expected %+v", i, simplified[i], expected[i])
+		}
+	}
+
+	// a tolerance smaller than the deviation keeps every point
+	if len(Simplify(polyline, 0.5)) != len(polyline) {
+		t.Error("expected every point to be kept with a small tolerance")
+	}
+	if len(Simplify(polyline, 0)) != len(polyline) {
+		t.Error("expected every point to be kept with zero tolerance")
+	}
+
+	// a huge tolerance keeps only the endpoints
+	simplified = Simplify(polyline, 100000)
+	if len(simplified) != 2 || simplified[0] != polyline[0] || simplified[1] != polyline[3] {
+		t.Errorf("got %+v, expected only the endpoints", simplified)
+	}
+
+	// a closed loop keeps its corners
+	loop := []shuttletracker.Point{
+		{Latitude: 42.73, Longitude: -73.68},
+		{Latitude: 42.73, Longitude: -73.67},
+		{Latitude: 42.74, Longitude: -73.67},
+		{Latitude: 42.73, Longitude: -73.68},
+	}
+	if len(Simplify(loop, 5)) != len(loop) {
+		t.Errorf("got %+v, expected every corner of the loop", Simplify(loop, 5))
+	}
+}
+
+func TestCrossings(t *testing.T) {
+	// a closed square doesn't cross itself, even where its ends meet
+	square := []shuttletracker.Point{
+		{Latitude: 42.73, Longitude: -73.68},
+		{Latitude: 42.73, Longitude: -73.67},
+		{Latitude: 42.74, Longitude: -73.67},
+		{Latitude: 42.74, Longitude: -73.68},
+		{Latitude: 42.73, Longitude: -73.68},
+	}
+	if crossings := Crossings(square); len(crossings) != 0 {
+		t.Errorf("got crossings %v for a square", crossings)
+	}
+
+	// swapping two corners makes a bowtie whose sides cross
+	bowtie := []shuttletracker.Point{square[0], square[1], square[3], square[2], square[0]}
+	crossings := Crossings(bowtie)
+	if len(crossings) != 1 || crossings[0] != (Crossing{First: 1, Second: 3}) {
+		t.Errorf("got crossings %v, expected segments 1 and 3", crossings)
+	}
+}
+
+func TestCentroid(t *testing.T) {
+	centroid := Centroid([]shuttletracker.Point{
+		{Latitude: 42.72, Longitude: -73.68},
+		{Latitude: 42.74, Longitude: -73.66},
+	})
+	if math.Abs(centroid.Latitude-42.73) > 0.0001 || math.Abs(centroid.Longitude+73.67) > 0.0001 {
+		t.Errorf("got %+v, expected about 42.73, -73.67", centroid)
+	}
+
+	// the naive mean of these longitudes would be on the other side of the world
+	centroid = Centroid([]shuttletracker.Point{
+		{Latitude: 0, Longitude: 179},
+		{Latitude: 0, Longitude: -179},
+	})
+	if math.Abs(math.Abs(centroid.Longitude)-180) > 0.0001 {
+		t.Errorf("got longitude %f, expected 180", centroid.Longitude)
+	}
+}
+
+func TestBearing(t *testing.T) {
+	origin := shuttletracker.Point{Latitude: 42.73, Longitude: -73.68}
+	cases := []struct {
+		to      shuttletracker.Point
+		bearing float64
+	}{
+		{shuttletracker.Point{Latitude: 42.74, Longitude: -73.68}, 0},
+		{shuttletracker.Point{Latitude: 42.73, Longitude: -73.67}, 90},
+		{shuttletracker.Point{Latitude: 42.72, Longitude: -73.68}, 180},
+		{shuttletracker.Point{Latitude: 42.73, Longitude: -73.69}, 270},
+	}
+	for _, c := range cases {
+		if bearing := Bearing(origin.Latitude, origin.Longitude, c.to.Latitude, c.to.Longitude); math.Abs(bearing-c.bearing) > 0.01 {
+			t.Errorf("got bearing %f to %+v, expected %f", bearing, c.to, c.bearing)
+		}
+	}
+}
+
+func TestCollapseLocations(t *testing.T) {
+	start := time.Date(2018, time.April, 16, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int, latitude float64) *shuttletracker.Location {
+		return &shuttletracker.Location{ID: int64(seconds), Latitude: latitude, Longitude: -73.68, Time: start.Add(time.Second * time.Duration(seconds))}
+	}
+	// 0.00001 degrees of latitude is about 1.1 meters
+	locations := []*shuttletracker.Location{
+		at(0, 42.73),
+		at(10, 42.73001),
+		at(20, 42.73002),
+		// moved about 110 meters
+		at(30, 42.731),
+		at(40, 42.731),
+		// still idling, but too long after the run began
+		at(120, 42.731),
+	}
+	ids := func(locations []*shuttletracker.Location) []int64 {
+		ids := []int64{}
+		for _, l := range locations {
+			ids = append(ids, l.ID)
+		}
+		return ids
+	}
+
+	cases := []struct {
+		distance float64
+		within   time.Duration
+		expected []int64
+	}{
+		{5, time.Minute, []int64{0, 30, 120}},
+		{5, 0, []int64{0, 30}},
+		{0, time.Minute, []int64{0, 10, 20, 30, 40, 120}},
+	}
+	for _, c := range cases {
+		collapsed := ids(CollapseLocations(locations, c.distance, c.within))
+		if len(collapsed) != len(c.expected) {
+			t.Errorf("got %v with %.0f meters and %s, expected %v", collapsed, c.distance, c.within, c.expected)
+			continue
+		}
+		for i := range collapsed {
+			if collapsed[i] != c.expected[i] {
+				t.Errorf("got %v with %.0f meters and %s, expected %v", collapsed, c.distance, c.within, c.expected)
+				break
+			}
+		}
+	}
+
+	// newest to oldest, runs begin at their newest Location
+	reversed := []*shuttletracker.Location{}
+	for i := len(locations) - 1; i >= 0; i-- {
+		reversed = append(reversed, locations[i])
+	}
+	collapsed := ids(CollapseLocations(reversed, 5, time.Minute))
+	if len(collapsed) != 3 || collapsed[0] != 120 || collapsed[1] != 40 || collapsed[2] != 20 {
+		t.Errorf("got %v, expected [120 40 20]", collapsed)
+	}
+}
+
+func TestConvexHull(t *testing.T) {
+	points := []shuttletracker.Point{
+		{Latitude: 42.74, Longitude: -73.67},
+		{Latitude: 42.73, Longitude: -73.68},
+		{Latitude: 42.735, Longitude: -73.675}, // inside
+		{Latitude: 42.73, Longitude: -73.67},
+		{Latitude: 42.73, Longitude: -73.675}, // on an edge
+		{Latitude: 42.74, Longitude: -73.68},
+		{Latitude: 42.73, Longitude: -73.68}, // duplicate
+	}
+	check := func(name string, points, expected []shuttletracker.Point) {
+		hull := ConvexHull(points)
+		if len(hull) != len(expected) {
+			t.Fatalf("%s: got %d points, expected %d: %+v", name, len(hull), len(expected), hull)
+		}
+		for i := range expected {
+			if hull[i] != expected[i] {
+				t.Errorf("%s: got point %d %+v, expected %+v", name, i, hull[i], expected[i])
+			}
+		}
+	}
+	check("square", points, []shuttletracker.Point{points[1], points[3], points[0], points[5]})
+	check("collinear", []shuttletracker.Point{points[1], points[4], points[3]}, []shuttletracker.Point{points[1], points[3]})
+	check("degenerate", []shuttletracker.Point{points[0], points[0], points[1]}, []shuttletracker.Point{points[1], points[0]})
+	check("empty", nil, []shuttletracker.Point{})
+
+	// duplicates differ only in fields other than their coordinates
+	elevation := 30.0
+	indexes := []int{0, 1, 2, 3}
+	loaded := []shuttletracker.Point{
+		{Latitude: 42.73, Longitude: -73.68, Index: &indexes[0]},
+		{Latitude: 42.73, Longitude: -73.67, Index: &indexes[1]},
+		{Latitude: 42.74, Longitude: -73.67, Index: &indexes[2]},
+		{Latitude: 42.73, Longitude: -73.68, Index: &indexes[3], Elevation: &elevation},
+	}
+	check("loaded", loaded, []shuttletracker.Point{loaded[0], loaded[1], loaded[2]})
+}
@@ -16,13 +16,29 @@ type Stop struct {
 	// Name and Description are pointers because they may be nil.
 	Name        *string `json:"name"`
 	Description *string `json:"description"`
+
+	// ArrivalRadius is how close in meters a vehicle must come to the Stop to have arrived at it.
+	// If it is nil, a default radius is used.
+	ArrivalRadius *float64 `json:"arrival_radius"`
+}
+
+// StopCoordinateUpdate is a corrected position for an existing Stop.
+type StopCoordinateUpdate struct {
+	ID        int64   `json:"id"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
 }
 
 // StopService is an interface for interacting with Stops.
 type StopService interface {
 	Stops() ([]*Stop, error)
 	CreateStop(stop *Stop) error
+	ModifyStop(stop *Stop) error
 	DeleteStop(id int64) error
+	UpdateStopCoordinates(updates []StopCoordinateUpdate) error
+	FindDuplicateStops(radius float64) ([][]*Stop, error)
+	MergeStops(keepID int64, mergeIDs []int64) error
+	OrphanStops() ([]*Stop, error)
 }
 
 // ErrStopNotFound indicates that a Stop is not in the service.
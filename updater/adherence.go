@@ -0,0 +1,189 @@
+package updater
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+const (
+	// defaultArrivalRadius is how close in meters a vehicle must come to a stop to have arrived at it
+	// when the stop doesn't set its own ArrivalRadius.
+	defaultArrivalRadius = 30.0
+
+	// adherenceWindow is how far from a scheduled time an arrival may be and still count for it.
+	adherenceWindow = time.Minute * 30
+
+	// earlyTolerance and lateTolerance bound the arrivals that are considered on time.
+	earlyTolerance = time.Minute
+	lateTolerance  = time.Minute * 5
+)
+
+// AdherenceStatus describes how an observed arrival compares to its scheduled time.
+type AdherenceStatus string
+
+// AdherenceStatus values.
+const (
+	AdherenceOnTime AdherenceStatus = "on_time"
+	AdherenceEarly  AdherenceStatus = "early"
+	AdherenceLate   AdherenceStatus = "late"
+	AdherenceMissed AdherenceStatus = "missed"
+)
+
+// AdherenceRecord compares a scheduled arrival at a stop to the observed arrival matched with it.
+type AdherenceRecord struct {
+	StopID    int64     `json:"stop_id"`
+	Scheduled time.Time `json:"scheduled"`
+
+	// Arrival is when a vehicle on the route arrived at the stop, or nil if none did.
+	Arrival *time.Time `json:"arrival"`
+
+	// Delta is Arrival minus Scheduled, so it is positive for late arrivals. It is zero if missed.
+	Delta  time.Duration   `json:"delta"`
+	Status AdherenceStatus `json:"status"`
+}
+
+// stopArrivals returns when vehicles on a route arrived at each of its stops between start and end,
+// keyed by stop ID. A vehicle arrives when its first Location within the stop's arrival radius is
+// recorded, and must leave the radius before it can arrive again.
+func (u *Updater) stopArrivals(route *shuttletracker.Route, start, end time.Time) (map[int64][]time.Time, error) {
+	stops, err := u.stopsForRoute(route)
+	if err != nil {
+		return nil, err
+	}
+	vehicles, err := u.ms.Vehicles()
+	if err != nil {
+		return nil, err
+	}
+
+	arrivals := map[int64][]time.Time{}
+	for _, vehicle := range vehicles {
+		locations, err := u.ms.LocationsBetween(vehicle.ID, start, end, shuttletracker.Collapse{})
+		if err != nil {
+			return nil, err
+		}
+		atStop := map[int64]bool{}
+		for _, location := range locations {
+			if location.RouteID == nil || *location.RouteID != route.ID {
+				atStop = map[int64]bool{}
+				continue
+			}
+			point := shuttletracker.Point{Latitude: location.Latitude, Longitude: location.Longitude}
+			for _, stop := range stops {
+				near := spatial.DistanceBetween(point, shuttletracker.Point{Latitude: stop.Latitude, Longitude: stop.Longitude}) <= stopArrivalRadius(stop)
+				if near && !atStop[stop.ID] {
+					arrivals[stop.ID] = append(arrivals[stop.ID], location.Time)
+				}
+				atStop[stop.ID] = near
+			}
+		}
+	}
+	return arrivals, nil
+}
+
+// stopArrivalRadius returns the radius in meters within which a vehicle has arrived at stop.
+func stopArrivalRadius(stop *shuttletracker.Stop) float64 {
+	if stop.ArrivalRadius == nil {
+		return defaultArrivalRadius
+	}
+	return *stop.ArrivalRadius
+}
+
+// adherenceStatus classifies the difference between an arrival and its scheduled time.
+func adherenceStatus(delta time.Duration) AdherenceStatus {
+	switch {
+	case delta < -earlyTolerance:
+		return AdherenceEarly
+	case delta > lateTolerance:
+		return AdherenceLate
+	default:
+		return AdherenceOnTime
+	}
+}
+
+// ScheduleAdherence compares a route's timetable for the campus day containing day to the arrivals
+// observed at its stops. Scheduled times are matched in order to the closest unmatched arrival at the
+// same stop within thirty minutes, and those without one are missed. Records are ordered by
+// scheduled time.
+func (u *Updater) ScheduleAdherence(routeID int64, day time.Time) ([]AdherenceRecord, error) {
+	u.mutex.Lock()
+	loc := u.campusLocation
+	u.mutex.Unlock()
+
+	local := day.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	route, err := u.ms.Route(routeID)
+	if err != nil {
+		return nil, err
+	}
+	timetable, err := u.ms.Timetable(routeID)
+	if err != nil {
+		return nil, err
+	}
+	arrivals, err := u.stopArrivals(route, start.Add(-adherenceWindow), start.AddDate(0, 0, 1).Add(adherenceWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	records := []AdherenceRecord{}
+	for _, entry := range timetable {
+		if entry.Day != start.Weekday() {
+			continue
+		}
+		clock, err := time.Parse("15:04", entry.Time)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, AdherenceRecord{
+			StopID:    entry.StopID,
+			Scheduled: time.Date(start.Year(), start.Month(), start.Day(), clock.Hour(), clock.Minute(), 0, 0, loc),
+			Status:    AdherenceMissed,
+		})
+	}
+	sort.Sort(byScheduled(records))
+
+	claimed := map[int64]map[int]bool{}
+	for i := range records {
+		record := &records[i]
+		if claimed[record.StopID] == nil {
+			claimed[record.StopID] = map[int]bool{}
+		}
+		best := -1
+		var bestDelta time.Duration
+		for j, arrival := range arrivals[record.StopID] {
+			delta := arrival.Sub(record.Scheduled)
+			if claimed[record.StopID][j] || absDuration(delta) > adherenceWindow {
+				continue
+			}
+			if best == -1 || absDuration(delta) < absDuration(bestDelta) {
+				best = j
+				bestDelta = delta
+			}
+		}
+		if best == -1 {
+			continue
+		}
+		claimed[record.StopID][best] = true
+		arrival := arrivals[record.StopID][best]
+		record.Arrival = &arrival
+		record.Delta = bestDelta
+		record.Status = adherenceStatus(bestDelta)
+	}
+	return records, nil
+}
+
+type byScheduled []AdherenceRecord
+
+func (r byScheduled) Len() int           { return len(r) }
+func (r byScheduled) Less(i, j int) bool { return r[i].Scheduled.Before(r[j].Scheduled) }
+func (r byScheduled) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
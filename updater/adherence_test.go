@@ -0,0 +1,129 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestScheduleAdherence(t *testing.T) {
+	routeID := int64(1)
+	otherRouteID := int64(2)
+	route := &shuttletracker.Route{ID: routeID, StopIDs: []int64{10, 20}}
+	stops := []*shuttletracker.Stop{
+		{ID: 10, Latitude: 42.730, Longitude: -73.680},
+		{ID: 20, Latitude: 42.740, Longitude: -73.670},
+	}
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("unable to load time zone: %s", err)
+	}
+	// a Monday
+	day := time.Date(2018, time.April, 16, 12, 0, 0, 0, loc)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2018, time.April, 16, hour, minute, 0, 0, loc)
+	}
+	timetable := shuttletracker.Timetable{
+		{StopID: 10, Day: time.Monday, Time: "08:00"},
+		{StopID: 20, Day: time.Monday, Time: "08:10"},
+		{StopID: 10, Day: time.Monday, Time: "09:00"},
+		{StopID: 20, Day: time.Monday, Time: "09:10"},
+		{StopID: 10, Day: time.Tuesday, Time: "08:00"},
+	}
+	locations := []*shuttletracker.Location{
+		// on time at the first stop, staying there for two samples
+		{Latitude: 42.730, Longitude: -73.680, Time: at(8, 1), RouteID: &routeID},
+		{Latitude: 42.730, Longitude: -73.680, Time: at(8, 2), RouteID: &routeID},
+		// late at the second stop
+		{Latitude: 42.740, Longitude: -73.670, Time: at(8, 20), RouteID: &routeID},
+		// early at the first stop
+		{Latitude: 42.730, Longitude: -73.680, Time: at(8, 55), RouteID: &routeID},
+		// at the second stop, but on another route
+		{Latitude: 42.740, Longitude: -73.670, Time: at(9, 10), RouteID: &otherRouteID},
+	}
+
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Route", routeID).Return(route, nil)
+	ms.RouteService.On("Timetable", routeID).Return(timetable, nil)
+	ms.StopService.On("Stops").Return(stops, nil)
+	ms.VehicleService.On("Vehicles").Return([]*shuttletracker.Vehicle{{ID: 1}}, nil)
+	ms.LocationService.On("LocationsBetween", int64(1), at(0, 0).Add(-adherenceWindow), at(0, 0).AddDate(0, 0, 1).Add(adherenceWindow), shuttletracker.Collapse{}).Return(locations, nil)
+	u, err := New(Config{UpdateInterval: "10s", CampusTimezone: "America/New_York"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	records, err := u.ScheduleAdherence(routeID, day)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []struct {
+		stopID int64
+		status AdherenceStatus
+		delta  time.Duration
+	}{
+		{10, AdherenceOnTime, time.Minute},
+		{20, AdherenceLate, time.Minute * 10},
+		{10, AdherenceEarly, -time.Minute * 5},
+		{20, AdherenceMissed, 0},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("got %d records, expected %d", len(records), len(expected))
+	}
+	for i, e := range expected {
+		r := records[i]
+		if r.StopID != e.stopID || r.Status != e.status || r.Delta != e.delta {
+			t.Errorf("got record %d for stop %d %s %s, expected stop %d %s %s", i, r.StopID, r.Status, r.Delta, e.stopID, e.status, e.delta)
+		}
+		if (r.Arrival == nil) != (e.status == AdherenceMissed) {
+			t.Errorf("got arrival %v for record %d with status %s", r.Arrival, i, r.Status)
+		}
+	}
+}
+
+func TestStopArrivalsRadius(t *testing.T) {
+	routeID := int64(1)
+	route := &shuttletracker.Route{ID: routeID, StopIDs: []int64{10, 20, 30}}
+	large := 100.0
+	small := 10.0
+	stops := []*shuttletracker.Stop{
+		{ID: 10, Latitude: 42.730, Longitude: -73.680, ArrivalRadius: &large},
+		{ID: 20, Latitude: 42.740, Longitude: -73.680, ArrivalRadius: &small},
+		{ID: 30, Latitude: 42.750, Longitude: -73.680},
+	}
+	start := time.Date(2018, time.April, 16, 8, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	// each location is about 55 m from a stop
+	locations := []*shuttletracker.Location{
+		{Latitude: 42.7305, Longitude: -73.680, Time: start.Add(time.Minute), RouteID: &routeID},
+		{Latitude: 42.7405, Longitude: -73.680, Time: start.Add(time.Minute * 2), RouteID: &routeID},
+		{Latitude: 42.7505, Longitude: -73.680, Time: start.Add(time.Minute * 3), RouteID: &routeID},
+		// within the default radius of the last stop
+		{Latitude: 42.7501, Longitude: -73.680, Time: start.Add(time.Minute * 4), RouteID: &routeID},
+	}
+
+	ms := &mock.ModelService{}
+	ms.StopService.On("Stops").Return(stops, nil)
+	ms.VehicleService.On("Vehicles").Return([]*shuttletracker.Vehicle{{ID: 1}}, nil)
+	ms.LocationService.On("LocationsBetween", int64(1), start, end, shuttletracker.Collapse{}).Return(locations, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	arrivals, err := u.stopArrivals(route, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(arrivals[10]) != 1 || !arrivals[10][0].Equal(start.Add(time.Minute)) {
+		t.Errorf("got arrivals %v at the stop with a large radius", arrivals[10])
+	}
+	if len(arrivals[20]) != 0 {
+		t.Errorf("got arrivals %v at the stop with a small radius", arrivals[20])
+	}
+	if len(arrivals[30]) != 1 || !arrivals[30][0].Equal(start.Add(time.Minute*4)) {
+		t.Errorf("got arrivals %v at the stop with the default radius", arrivals[30])
+	}
+}
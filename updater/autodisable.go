@@ -0,0 +1,60 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/log"
+)
+
+// autoDisableInterval is how often Run checks for silent vehicles to disable.
+const autoDisableInterval = time.Hour
+
+// DisableSilentVehicles disables every enabled Vehicle that has neither reported nor been modified
+// within the configured AutoDisableAfter, and returns the Vehicles it disabled. Vehicles are disabled
+// rather than deleted so that they can be enabled again, which also modifies them and so keeps them
+// from being disabled again right away. Nothing is disabled if AutoDisableAfter isn't set.
+func (u *Updater) DisableSilentVehicles() ([]*shuttletracker.Vehicle, error) {
+	u.mutex.Lock()
+	after := u.autoDisableAfter
+	u.mutex.Unlock()
+
+	disabled := []*shuttletracker.Vehicle{}
+	if after == 0 {
+		return disabled, nil
+	}
+	vehicles, err := u.ms.EnabledVehicles()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := u.ms.LatestLocations()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-after)
+	for _, vehicle := range vehicles {
+		// old Locations are pruned, so a vehicle that hasn't reported in a long time may have none
+		lastSeen := vehicle.Updated
+		if location, ok := latest[vehicle.ID]; ok && location.Time.After(lastSeen) {
+			lastSeen = location.Time
+		}
+		if !lastSeen.Before(cutoff) {
+			continue
+		}
+		vehicle.Enabled = false
+		if err := u.ms.ModifyVehicle(vehicle); err != nil {
+			return disabled, err
+		}
+		log.WithFields(log.Fields{"vehicle": vehicle.Name, "last_seen": lastSeen}).Info("Disabled silent vehicle.")
+		disabled = append(disabled, vehicle)
+	}
+	return disabled, nil
+}
+
+// autoDisable runs DisableSilentVehicles and logs any error.
+func (u *Updater) autoDisable() {
+	if _, err := u.DisableSilentVehicles(); err != nil {
+		log.WithError(err).Error("Unable to disable silent vehicles.")
+	}
+}
@@ -0,0 +1,64 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestDisableSilentVehicles(t *testing.T) {
+	now := time.Now()
+	longAgo := now.AddDate(0, 0, -40)
+	silent := &shuttletracker.Vehicle{ID: 1, Name: "Silent", Enabled: true, Updated: longAgo}
+	reporting := &shuttletracker.Vehicle{ID: 2, Name: "Reporting", Enabled: true, Updated: longAgo}
+	pruned := &shuttletracker.Vehicle{ID: 3, Name: "Pruned", Enabled: true, Updated: longAgo}
+	reenabled := &shuttletracker.Vehicle{ID: 4, Name: "Re-enabled", Enabled: true, Updated: now.Add(-time.Hour)}
+
+	ms := &mock.ModelService{}
+	ms.VehicleService.On("EnabledVehicles").Return([]*shuttletracker.Vehicle{silent, reporting, pruned, reenabled}, nil)
+	ms.LocationService.On("LatestLocations").Return(map[int64]*shuttletracker.Location{
+		silent.ID:    {Time: now.AddDate(0, 0, -35)},
+		reporting.ID: {Time: now.Add(-time.Minute)},
+	}, nil)
+	ms.VehicleService.On("ModifyVehicle", silent).Return(nil)
+	ms.VehicleService.On("ModifyVehicle", pruned).Return(nil)
+	u, err := New(Config{UpdateInterval: "10s", AutoDisableAfter: "720h"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	disabled, err := u.DisableSilentVehicles()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(disabled) != 2 || disabled[0] != silent || disabled[1] != pruned {
+		t.Errorf("got %+v, expected the silent and pruned vehicles", disabled)
+	}
+	if silent.Enabled || pruned.Enabled || !reporting.Enabled || !reenabled.Enabled {
+		t.Error("expected only the silent and pruned vehicles to be disabled")
+	}
+	ms.VehicleService.AssertNumberOfCalls(t, "ModifyVehicle", 2)
+}
+
+func TestDisableSilentVehiclesOptIn(t *testing.T) {
+	ms := &mock.ModelService{}
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	disabled, err := u.DisableSilentVehicles()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(disabled) != 0 {
+		t.Errorf("got %+v, expected no vehicles to be disabled", disabled)
+	}
+	ms.VehicleService.AssertNotCalled(t, "EnabledVehicles")
+
+	_, err = New(Config{UpdateInterval: "10s", AutoDisableAfter: "-1h"}, ms)
+	if err == nil {
+		t.Error("expected an error for a negative auto-disable threshold")
+	}
+}
@@ -0,0 +1,98 @@
+package updater
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+// fleetBoundsActivity is how recently a vehicle must have reported to be included in the fleet bounds.
+const fleetBoundsActivity = time.Minute * 15
+
+// ErrNoFleetLocations indicates that no enabled vehicle has reported a Location recently, so there is
+// nothing to frame.
+var ErrNoFleetLocations = errors.New("no recent vehicle locations")
+
+// FleetBounds returns the bounding box of the latest Location of every enabled vehicle that has
+// reported in the last fifteen minutes. ErrNoFleetLocations is returned if there are none.
+func (u *Updater) FleetBounds() (minLat, minLng, maxLat, maxLng float64, err error) {
+	vehicles, err := u.ms.EnabledVehicles()
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	minLat, minLng = math.Inf(1), math.Inf(1)
+	maxLat, maxLng = math.Inf(-1), math.Inf(-1)
+	found := false
+	since := time.Now().Add(-fleetBoundsActivity)
+	for _, vehicle := range vehicles {
+		location, err := u.ms.LatestLocation(vehicle.ID)
+		if err == shuttletracker.ErrLocationNotFound {
+			continue
+		} else if err != nil {
+			return 0, 0, 0, 0, err
+		}
+		if location.Time.Before(since) {
+			continue
+		}
+		found = true
+		minLat = math.Min(minLat, location.Latitude)
+		minLng = math.Min(minLng, location.Longitude)
+		maxLat = math.Max(maxLat, location.Latitude)
+		maxLng = math.Max(maxLng, location.Longitude)
+	}
+
+	if !found {
+		return 0, 0, 0, 0, ErrNoFleetLocations
+	}
+	return minLat, minLng, maxLat, maxLng, nil
+}
+
+// fleetSpeedWindow is how far back Locations are included in the current fleet speed.
+const fleetSpeedWindow = time.Minute
+
+// CurrentFleetSpeedStats returns the mean and max speed in miles per hour of the Locations stored in
+// the last minute. A stationary fleet has a mean and max of zero, and ErrNoFleetLocations is returned
+// if nothing was stored.
+func (u *Updater) CurrentFleetSpeedStats() (mean, max float64, err error) {
+	stats, err := u.ms.SpeedStats(time.Now().Add(-fleetSpeedWindow))
+	if err != nil {
+		return 0, 0, err
+	}
+	if stats.Count == 0 {
+		return 0, 0, ErrNoFleetLocations
+	}
+	return stats.Mean, stats.Max, nil
+}
+
+// FleetCentroid returns the spherical mean of the latest Locations of enabled vehicles that are within
+// the freshness window. ErrNoFleetLocations is returned if there are none.
+func (u *Updater) FleetCentroid() (lat, lng float64, err error) {
+	u.mutex.Lock()
+	freshness := u.locationFreshness
+	u.mutex.Unlock()
+
+	// enabled vehicles and their latest Locations come from one query since this is polled often
+	activity, err := u.ms.VehiclesByRecentActivity()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	since := time.Now().Add(-freshness)
+	points := []shuttletracker.Point{}
+	for _, va := range activity {
+		location := va.Location
+		if location == nil || location.Time.Before(since) {
+			continue
+		}
+		points = append(points, shuttletracker.Point{Latitude: location.Latitude, Longitude: location.Longitude})
+	}
+	if len(points) == 0 {
+		return 0, 0, ErrNoFleetLocations
+	}
+	centroid := spatial.Centroid(points)
+	return centroid.Latitude, centroid.Longitude, nil
+}
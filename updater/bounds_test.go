@@ -0,0 +1,113 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/wtg/shuttletracker"
+	stmock "github.com/wtg/shuttletracker/mock"
+)
+
+func TestFleetBounds(t *testing.T) {
+	now := time.Now()
+	vehicles := []*shuttletracker.Vehicle{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	ms := &stmock.ModelService{}
+	ms.VehicleService.On("EnabledVehicles").Return(vehicles, nil)
+	ms.LocationService.On("LatestLocation", int64(1)).Return(&shuttletracker.Location{Latitude: 42.73, Longitude: -73.66, Time: now}, nil)
+	ms.LocationService.On("LatestLocation", int64(2)).Return(&shuttletracker.Location{Latitude: 42.72, Longitude: -73.68, Time: now}, nil)
+	// a stale location and a vehicle that has never reported are ignored
+	ms.LocationService.On("LatestLocation", int64(3)).Return(&shuttletracker.Location{Latitude: 40, Longitude: -70, Time: now.Add(-time.Hour)}, nil)
+	ms.LocationService.On("LatestLocation", int64(4)).Return((*shuttletracker.Location)(nil), shuttletracker.ErrLocationNotFound)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	minLat, minLng, maxLat, maxLng, err := u.FleetBounds()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if minLat != 42.72 || minLng != -73.68 || maxLat != 42.73 || maxLng != -73.66 {
+		t.Errorf("got bounds %f, %f, %f, %f", minLat, minLng, maxLat, maxLng)
+	}
+}
+
+func TestFleetBoundsNoLocations(t *testing.T) {
+	ms := &stmock.ModelService{}
+	ms.VehicleService.On("EnabledVehicles").Return([]*shuttletracker.Vehicle{{ID: 1}}, nil)
+	ms.LocationService.On("LatestLocation", int64(1)).Return((*shuttletracker.Location)(nil), shuttletracker.ErrLocationNotFound)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, _, _, _, err = u.FleetBounds()
+	if err != ErrNoFleetLocations {
+		t.Errorf("got error %v, expected ErrNoFleetLocations", err)
+	}
+}
+
+func TestCurrentFleetSpeedStats(t *testing.T) {
+	cases := []struct {
+		stats     shuttletracker.SpeedStats
+		mean, max float64
+		err       error
+	}{
+		{shuttletracker.SpeedStats{Count: 4, Mean: 12.5, Max: 25}, 12.5, 25, nil},
+		// a stationary fleet isn't an error
+		{shuttletracker.SpeedStats{Count: 4}, 0, 0, nil},
+		{shuttletracker.SpeedStats{}, 0, 0, ErrNoFleetLocations},
+	}
+	for _, c := range cases {
+		ms := &stmock.ModelService{}
+		ms.LocationService.On("SpeedStats", mock.AnythingOfType("time.Time")).Return(c.stats, nil)
+		u, err := New(Config{UpdateInterval: "10s"}, ms)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		mean, max, err := u.CurrentFleetSpeedStats()
+		if mean != c.mean || max != c.max || err != c.err {
+			t.Errorf("got mean %f, max %f, and error %v for %+v, expected %f, %f, and %v", mean, max, err, c.stats, c.mean, c.max, c.err)
+		}
+	}
+}
+
+func TestFleetCentroid(t *testing.T) {
+	now := time.Now()
+	activity := []shuttletracker.VehicleActivity{
+		{Vehicle: &shuttletracker.Vehicle{ID: 1}, Location: &shuttletracker.Location{Latitude: 42.72, Longitude: -73.68, Time: now}},
+		{Vehicle: &shuttletracker.Vehicle{ID: 2}, Location: &shuttletracker.Location{Latitude: 42.74, Longitude: -73.66, Time: now}},
+		// a stale location and a vehicle that has never reported are ignored
+		{Vehicle: &shuttletracker.Vehicle{ID: 3}, Location: &shuttletracker.Location{Latitude: 10, Longitude: 10, Time: now.Add(-time.Hour)}},
+		{Vehicle: &shuttletracker.Vehicle{ID: 4}},
+	}
+	ms := &stmock.ModelService{}
+	ms.VehicleService.On("VehiclesByRecentActivity").Return(activity, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	lat, lng, err := u.FleetCentroid()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if lat < 42.7299 || lat > 42.7301 || lng < -73.6701 || lng > -73.6699 {
+		t.Errorf("got %f, %f, expected about 42.73, -73.67", lat, lng)
+	}
+	ms.VehicleService.AssertNumberOfCalls(t, "VehiclesByRecentActivity", 1)
+
+	ms = &stmock.ModelService{}
+	ms.VehicleService.On("VehiclesByRecentActivity").Return(activity[3:], nil)
+	u, err = New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, _, err = u.FleetCentroid()
+	if err != ErrNoFleetLocations {
+		t.Errorf("got error %v, expected %v", err, ErrNoFleetLocations)
+	}
+}
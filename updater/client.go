@@ -0,0 +1,120 @@
+package updater
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// defaultSlowFeedFraction is used when Config.SlowFeedFraction is zero.
+const defaultSlowFeedFraction = 0.5
+
+// slowFeedThreshold returns how long a data feed request may take with the provided update interval
+// before it is considered slow.
+func slowFeedThreshold(cfg Config, interval time.Duration) time.Duration {
+	fraction := cfg.SlowFeedFraction
+	if fraction == 0 {
+		fraction = defaultSlowFeedFraction
+	}
+	return time.Duration(float64(interval) * fraction)
+}
+
+// Option configures an Updater when it is created.
+type Option func(*Updater)
+
+// WithHTTPClient makes the Updater fetch the data feed with client instead of building its own from
+// its Config, e.g. to share a tuned client across the app or to stub the transport in tests. The
+// client keeps being used when the Updater is reloaded, so Config.FeedProxyURL has no effect.
+func WithHTTPClient(client *http.Client) Option {
+	return func(u *Updater) {
+		u.providedClient = client
+	}
+}
+
+// FeedURLProvider supplies the URL of the data feed, e.g. to refresh a signed URL before its token
+// expires. The Updater asks for the URL before every fetch.
+type FeedURLProvider interface {
+	FeedURL() (string, error)
+}
+
+// StaticFeedURL is a FeedURLProvider that always provides the same URL.
+type StaticFeedURL string
+
+// FeedURL returns the URL.
+func (s StaticFeedURL) FeedURL() (string, error) {
+	return string(s), nil
+}
+
+// WithFeedURLProvider makes the Updater fetch the data feed from the URLs that provider supplies
+// instead of Config.DataFeed. The provider keeps being used when the Updater is reloaded.
+func WithFeedURLProvider(provider FeedURLProvider) Option {
+	return func(u *Updater) {
+		u.feedURLProvider = provider
+	}
+}
+
+// feedURL returns the URL to fetch the data feed from, which is Config.DataFeed unless a
+// FeedURLProvider was provided.
+func (u *Updater) feedURL(cfg Config) (string, error) {
+	u.mutex.Lock()
+	provider := u.feedURLProvider
+	u.mutex.Unlock()
+	if provider == nil {
+		provider = StaticFeedURL(cfg.DataFeed)
+	}
+	return provider.FeedURL()
+}
+
+// redactedURL returns raw without its query, which is where signed URLs usually carry their token, so
+// that it can be logged.
+func redactedURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	parsed.RawQuery = ""
+	return parsed.String()
+}
+
+// newFeedClient returns the HTTP client used to fetch the data feed. Its Transport is a copy of
+// http.DefaultTransport, so it keeps the default dialer, connection pooling, and HTTP/2 settings.
+// Requests go through cfg.FeedProxyURL if it is set, or the proxy from the environment otherwise.
+// Since the client's Transport chooses the proxy for every request it sends, redirects are proxied too.
+func newFeedClient(cfg Config) (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if cfg.FeedProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.FeedProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("feed proxy URL \"%s\" must include a scheme and host", cfg.FeedProxyURL)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSHandshakeTimeout = time.Second * 5
+	return &http.Client{Timeout: time.Second * 5, Transport: transport}, nil
+}
+
+// conditionalRequest returns a GET request for the data feed. If the last response had an ETag or
+// Last-Modified header, the request asks the feed to respond with 304 Not Modified if nothing changed.
+func conditionalRequest(dataFeed string, last *DataFeedResponse) (*http.Request, error) {
+	req, err := http.NewRequest("GET", dataFeed, nil)
+	if err != nil {
+		return nil, err
+	}
+	if last == nil {
+		return req, nil
+	}
+	if etag := last.Headers.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified := last.Headers.Get("Last-Modified"); lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	return req, nil
+}
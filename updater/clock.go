@@ -0,0 +1,58 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/wtg/shuttletracker/log"
+)
+
+// parseClockConfig returns the location of data feed timestamps and the maximum tolerated clock skew from cfg.
+func parseClockConfig(cfg Config) (*time.Location, time.Duration, error) {
+	loc, err := loadLocation(cfg.FeedTimezone)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var maxSkew time.Duration
+	if cfg.MaxClockSkew != "" {
+		maxSkew, err = time.ParseDuration(cfg.MaxClockSkew)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return loc, maxSkew, nil
+}
+
+// loadLocation returns the time zone with the provided IANA name, or UTC if name is empty.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// recordClockSkew stores how far behind the server's clock a tracker's latest timestamp was.
+// A negative skew means that the tracker's clock is ahead.
+func (u *Updater) recordClockSkew(trackerID string, skew time.Duration) {
+	u.mutex.Lock()
+	u.clockSkew[trackerID] = skew
+	maxSkew := u.maxClockSkew
+	u.mutex.Unlock()
+
+	if maxSkew > 0 && (skew > maxSkew || skew < -maxSkew) {
+		log.Warnf("Tracker %s clock is off by %s.", trackerID, skew)
+	}
+}
+
+// TrackerClockSkew returns how far behind the server's clock each tracker's most recent new timestamp
+// was when the data feed was fetched. Since trackers report at most once per update, a skew of up to
+// the update interval is expected.
+func (u *Updater) TrackerClockSkew() map[string]time.Duration {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	skew := make(map[string]time.Duration, len(u.clockSkew))
+	for trackerID, d := range u.clockSkew {
+		skew[trackerID] = d
+	}
+	return skew
+}
@@ -0,0 +1,53 @@
+package updater
+
+import (
+	"testing"
+	"time"
+)
+
+func TestITrakTimeDateIn(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %s", err)
+	}
+	parsed, err := itrakTimeDateIn("time:200546", "date:04162018", loc)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := time.Date(2018, time.April, 17, 0, 5, 46, 0, time.UTC)
+	if !parsed.Equal(expected) {
+		t.Errorf("got %+v, expected %+v", parsed, expected)
+	}
+}
+
+func TestTrackerClockSkew(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s", MaxClockSkew: "1m"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	u.recordClockSkew("1", time.Second*5)
+	u.recordClockSkew("2", -time.Minute*3)
+	u.recordClockSkew("1", time.Second*7)
+
+	skew := u.TrackerClockSkew()
+	if len(skew) != 2 || skew["1"] != time.Second*7 || skew["2"] != -time.Minute*3 {
+		t.Errorf("got %v", skew)
+	}
+
+	// the returned map is a copy
+	skew["1"] = 0
+	if u.TrackerClockSkew()["1"] != time.Second*7 {
+		t.Error("modifying the returned map changed the Updater's state")
+	}
+}
+
+func TestParseClockConfig(t *testing.T) {
+	_, _, err := parseClockConfig(Config{FeedTimezone: "Not/AZone"})
+	if err == nil {
+		t.Error("expected an error for an unknown time zone")
+	}
+	_, _, err = parseClockConfig(Config{MaxClockSkew: "soon"})
+	if err == nil {
+		t.Error("expected an error for an invalid duration")
+	}
+}
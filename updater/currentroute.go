@@ -0,0 +1,32 @@
+package updater
+
+import (
+	"math"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/log"
+)
+
+// routeConfidence converts a vehicle's average distance from its guessed route into a confidence
+// from 0 to 1, where 1 means its recent Locations lie on the route and 0 means they are at or beyond
+// the off-route threshold.
+func routeConfidence(distance, offThreshold float64) float64 {
+	if offThreshold <= 0 {
+		return 0
+	}
+	return math.Max(0, math.Min(1, 1-distance/offThreshold))
+}
+
+// recordCurrentRoute stores a route guess for a vehicle. A routeID of zero records that the vehicle
+// is not on a route. Failures are logged since the guess is still stored with the vehicle's Location.
+func (u *Updater) recordCurrentRoute(vehicle *shuttletracker.Vehicle, routeID int64, distance, offThreshold float64) {
+	var id *int64
+	confidence := 0.0
+	if routeID != 0 {
+		id = &routeID
+		confidence = routeConfidence(distance, offThreshold)
+	}
+	if err := u.ms.SetCurrentRoute(vehicle.ID, id, confidence); err != nil {
+		log.WithError(err).Errorf("Unable to record current route for %s.", vehicle.Name)
+	}
+}
@@ -0,0 +1,66 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestRouteConfidence(t *testing.T) {
+	for _, c := range []struct {
+		distance, off, expected float64
+	}{
+		{0, 10, 1},
+		{5, 10, 0.5},
+		{10, 10, 0},
+		{60, 10, 0},
+		{1, 0, 0},
+	} {
+		if got := routeConfidence(c.distance, c.off); got != c.expected {
+			t.Errorf("got confidence %f for distance %f, expected %f", got, c.distance, c.expected)
+		}
+	}
+}
+
+func TestGuessRoutePersistCurrentRoute(t *testing.T) {
+	route := &shuttletracker.Route{
+		ID:      1,
+		Enabled: true,
+		Active:  true,
+		Points:  []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.67}},
+	}
+	vehicle := &shuttletracker.Vehicle{ID: 1, Name: "Vehicle 1", Enabled: true}
+	updates := []*shuttletracker.Location{}
+	for i := 0; i < 5; i++ {
+		updates = append(updates, &shuttletracker.Location{Latitude: 42.73, Longitude: -73.67})
+	}
+
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Routes").Return([]*shuttletracker.Route{route}, nil)
+	ms.RouteService.On("Route", route.ID).Return(route, nil)
+	ms.RouteService.On("SetCurrentRoute", vehicle.ID, &route.ID, 1.0).Return(nil)
+	ms.LocationService.On("LocationsSince", vehicle.ID).Return(updates, nil)
+	u, err := New(Config{UpdateInterval: "10s", PersistCurrentRoute: true}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// queries don't record anything
+	guess, err := u.GuessRouteForVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if guess == nil || guess.ID != route.ID {
+		t.Errorf("got %+v, expected route %d", guess, route.ID)
+	}
+	ms.RouteService.AssertNotCalled(t, "SetCurrentRoute", vehicle.ID, &route.ID, 1.0)
+
+	// the update cycle does
+	cycleGuess, err := u.guessRoute(vehicle, []*shuttletracker.Route{route}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	u.commitRouteGuess(vehicle, cycleGuess)
+	ms.RouteService.AssertExpectations(t)
+}
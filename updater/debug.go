@@ -0,0 +1,56 @@
+package updater
+
+import (
+	"math"
+	"time"
+)
+
+// RouteGuessDebug records the inputs and outcome of a single route guess for a vehicle.
+type RouteGuessDebug struct {
+	VehicleID int64     `json:"vehicle_id"`
+	Time      time.Time `json:"time"`
+
+	// Updates is the number of recent locations the guess was made from.
+	Updates int `json:"updates"`
+
+	// Distances maps route IDs to the vehicle's average distance from that route. Routes that
+	// could not be matched at all, such as disabled or inactive ones, are omitted.
+	Distances map[int64]float64 `json:"distances"`
+
+	// RouteID is the guessed route, or 0 if the vehicle was not considered to be on a route.
+	RouteID int64 `json:"route_id"`
+}
+
+// Only the most recent guess is kept for each vehicle, so memory is bounded by the size of the fleet.
+func (u *Updater) recordGuessDebug(debug *RouteGuessDebug) {
+	distances := make(map[int64]float64, len(debug.Distances))
+	for id, distance := range debug.Distances {
+		if !math.IsInf(distance, 0) && !math.IsNaN(distance) {
+			distances[id] = distance
+		}
+	}
+	debug.Distances = distances
+
+	u.mutex.Lock()
+	u.guessDebug[debug.VehicleID] = debug
+	u.mutex.Unlock()
+}
+
+// LastGuessDebug returns the most recent route guess recorded for a vehicle, or nil if there is none.
+// Guesses are only recorded when RouteGuessDebug is enabled in the Config.
+func (u *Updater) LastGuessDebug(vehicleID int64) *RouteGuessDebug {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	debug, ok := u.guessDebug[vehicleID]
+	if !ok {
+		return nil
+	}
+
+	// copy so that callers can't race with future guesses
+	c := *debug
+	c.Distances = make(map[int64]float64, len(debug.Distances))
+	for id, distance := range debug.Distances {
+		c.Distances[id] = distance
+	}
+	return &c
+}
@@ -0,0 +1,46 @@
+package updater
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestLastGuessDebug(t *testing.T) {
+	u := &Updater{
+		mutex:      &sync.Mutex{},
+		guessDebug: map[int64]*RouteGuessDebug{},
+	}
+
+	if debug := u.LastGuessDebug(1); debug != nil {
+		t.Errorf("got %+v, expected nil", debug)
+	}
+
+	u.recordGuessDebug(&RouteGuessDebug{
+		VehicleID: 1,
+		Distances: map[int64]float64{1: 4.2, 2: 4.3, 3: math.Inf(0)},
+		RouteID:   1,
+	})
+	u.recordGuessDebug(&RouteGuessDebug{
+		VehicleID: 1,
+		Distances: map[int64]float64{1: 4.3, 2: 4.2},
+		RouteID:   2,
+	})
+
+	debug := u.LastGuessDebug(1)
+	if debug == nil {
+		t.Fatal("got nil, expected a guess")
+	}
+	if debug.RouteID != 2 {
+		t.Errorf("got route ID %d, expected 2", debug.RouteID)
+	}
+	if len(debug.Distances) != 2 {
+		t.Errorf("got %d distances, expected 2", len(debug.Distances))
+	}
+
+	// modifying the returned guess shouldn't affect the stored one
+	debug.Distances[1] = 0
+	if u.LastGuessDebug(1).Distances[1] != 4.3 {
+		t.Error("stored guess was modified")
+	}
+}
@@ -0,0 +1,58 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/wtg/shuttletracker"
+	stmock "github.com/wtg/shuttletracker/mock"
+)
+
+func TestHandleVehicleDataSkipsStoredTimestamps(t *testing.T) {
+	vehicle := &shuttletracker.Vehicle{ID: 1, Name: "Vehicle 1", Enabled: true, TrackerID: "1234"}
+	stored := time.Date(2018, time.April, 16, 5, 29, 57, 0, time.UTC)
+	older := time.Date(2018, time.April, 16, 5, 28, 0, 0, time.UTC)
+
+	ms := &stmock.ModelService{}
+	ms.VehicleService.On("VehicleWithTrackerID", "1234").Return(vehicle, nil)
+	ms.LocationService.On("ExistsLocation", "1234", stored).Return(true, nil)
+	ms.LocationService.On("ExistsLocation", "1234", older).Return(false, nil)
+	ms.LocationService.On("LocationsSince", int64(1)).Return([]*shuttletracker.Location{}, nil)
+	ms.LocationService.On("CreateLocation", mock.AnythingOfType("*shuttletracker.Location")).Return(nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, isStored := u.handleVehicleData("Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:52957 date:04162018", time.Now(), []*shuttletracker.Route{})
+	if isStored {
+		t.Error("duplicate record was reported as stored")
+	}
+	ms.LocationService.AssertNotCalled(t, "CreateLocation", mock.Anything)
+
+	// an older record that arrives late is still stored
+	_, isStored = u.handleVehicleData("Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:52800 date:04162018", time.Now(), []*shuttletracker.Route{})
+	if !isStored {
+		t.Error("late record was not reported as stored")
+	}
+	ms.LocationService.AssertNumberOfCalls(t, "CreateLocation", 1)
+}
+
+func TestHandleVehicleDataAllowlist(t *testing.T) {
+	ms := &stmock.ModelService{}
+	u, err := New(Config{UpdateInterval: "10s", TrackerAllowlist: []string{"5678"}}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	trackerID, stored := u.handleVehicleData("Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:52957 date:04162018", time.Now(), []*shuttletracker.Route{})
+	if trackerID != "1234" {
+		t.Errorf("got tracker ID %s, expected 1234", trackerID)
+	}
+	if stored {
+		t.Error("record from a tracker outside the allowlist was reported as stored")
+	}
+	ms.VehicleService.AssertNotCalled(t, "VehicleWithTrackerID", mock.Anything)
+}
@@ -0,0 +1,64 @@
+package updater
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wtg/shuttletracker/log"
+)
+
+// dropDuplicateTrackers keeps a single record for every tracker ID that appears more than once in the
+// records from one data feed cycle, so that misconfigured trackers sharing an ID don't race to store
+// conflicting positions for one vehicle. The record with the latest time is kept, and the first of
+// those is kept if several share it. Records that can't be parsed are kept so that they fail as
+// usual. The remaining records stay in feed order, and the duplicated IDs are remembered for
+// DuplicateTrackerIDs.
+func (u *Updater) dropDuplicateTrackers(records []string) []string {
+	kept := map[string]int{}
+	keptTimes := map[string]time.Time{}
+	counts := map[string]int{}
+	for i, record := range records {
+		trackerID, location, err := u.parseRecord(record)
+		if err != nil {
+			continue
+		}
+		counts[trackerID]++
+		if t, ok := keptTimes[trackerID]; ok && !location.Time.After(t) {
+			continue
+		}
+		kept[trackerID] = i
+		keptTimes[trackerID] = location.Time
+	}
+
+	duplicates := []string{}
+	for trackerID, count := range counts {
+		if count > 1 {
+			duplicates = append(duplicates, trackerID)
+			log.WithFields(log.Fields{"tracker_id": trackerID, "records": count}).Warn("Data feed contains several records for one tracker ID.")
+		}
+	}
+	sort.Strings(duplicates)
+	u.mutex.Lock()
+	u.duplicateTrackers = duplicates
+	u.mutex.Unlock()
+	if len(duplicates) == 0 {
+		return records
+	}
+
+	deduplicated := []string{}
+	for i, record := range records {
+		trackerID, _, err := u.parseRecord(record)
+		if err != nil || kept[trackerID] == i {
+			deduplicated = append(deduplicated, record)
+		}
+	}
+	return deduplicated
+}
+
+// DuplicateTrackerIDs returns the tracker IDs that appeared more than once in the most recent data
+// feed, in order. Each is likely shared by several misconfigured trackers.
+func (u *Updater) DuplicateTrackerIDs() []string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return append([]string{}, u.duplicateTrackers...)
+}
@@ -0,0 +1,52 @@
+package updater
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestDropDuplicateTrackers(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, &mock.ModelService{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	records := []string{
+		"Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:52800 date:04162018",
+		"Vehicle ID:5678 lat:42.73000 lon:-73.68000 time:52800 date:04162018",
+		// the same tracker ID reporting a later position from another tracker
+		"Vehicle ID:1234 lat:42.73100 lon:-73.68100 time:52957 date:04162018",
+		// and an earlier one
+		"Vehicle ID:1234 lat:42.73200 lon:-73.68200 time:52700 date:04162018",
+		"Vehicle ID:9999 lat:nope",
+	}
+	kept := u.dropDuplicateTrackers(records)
+	expected := []string{records[1], records[2], records[4]}
+	if !reflect.DeepEqual(kept, expected) {
+		t.Errorf("got records %q, expected %q", kept, expected)
+	}
+	if ids := u.DuplicateTrackerIDs(); !reflect.DeepEqual(ids, []string{"1234"}) {
+		t.Errorf("got duplicate tracker IDs %v, expected [1234]", ids)
+	}
+
+	// records with the same time keep the first one in the feed
+	records = []string{
+		"Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:52800 date:04162018",
+		"Vehicle ID:1234 lat:42.73100 lon:-73.68100 time:52800 date:04162018",
+	}
+	kept = u.dropDuplicateTrackers(records)
+	if !reflect.DeepEqual(kept, records[:1]) {
+		t.Errorf("got records %q, expected only the first", kept)
+	}
+
+	// duplicates are only reported for the most recent feed
+	kept = u.dropDuplicateTrackers(records[:1])
+	if len(kept) != 1 {
+		t.Errorf("got %d records, expected 1", len(kept))
+	}
+	if ids := u.DuplicateTrackerIDs(); len(ids) != 0 {
+		t.Errorf("got duplicate tracker IDs %v, expected none", ids)
+	}
+}
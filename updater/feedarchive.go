@@ -0,0 +1,170 @@
+package updater
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+const (
+	// defaultFeedArchiveCount is used when Config.FeedArchiveCount is zero.
+	defaultFeedArchiveCount = 100
+
+	// feedArchiveExtension and feedArchiveTimeFormat name archived bodies so that sorting their names
+	// sorts them by when they were fetched.
+	feedArchiveExtension  = ".feed"
+	feedArchiveTimeFormat = "20060102T150405.000000000"
+)
+
+// ErrFeedArchiveDisabled indicates that no feed archive directory is configured.
+var ErrFeedArchiveDisabled = errors.New("feed archive is disabled")
+
+// ArchivedFeed is a data feed response body kept by a FeedArchive.
+type ArchivedFeed struct {
+	Fetched time.Time
+	Body    []byte
+}
+
+// FeedArchive keeps the most recent raw data feed response bodies on disk, one file per body, so that
+// parser problems can be reproduced after the fact. Once it holds more than its maximum number of
+// bodies, the oldest are deleted.
+type FeedArchive struct {
+	dir      string
+	maxFiles int
+	mutex    *sync.Mutex
+}
+
+// NewFeedArchive creates a FeedArchive in dir that keeps up to maxFiles bodies. The directory is
+// created if it doesn't exist.
+func NewFeedArchive(dir string, maxFiles int) (*FeedArchive, error) {
+	if maxFiles <= 0 {
+		return nil, errors.New("feed archive must keep at least one body")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FeedArchive{dir: dir, maxFiles: maxFiles, mutex: &sync.Mutex{}}, nil
+}
+
+// newFeedArchive returns the FeedArchive configured by cfg, or nil if archiving is disabled.
+func newFeedArchive(cfg Config) (*FeedArchive, error) {
+	if cfg.FeedArchiveDir == "" {
+		return nil, nil
+	}
+	count := cfg.FeedArchiveCount
+	if count == 0 {
+		count = defaultFeedArchiveCount
+	}
+	return NewFeedArchive(cfg.FeedArchiveDir, count)
+}
+
+// names returns the names of the archived bodies, oldest first.
+func (a *FeedArchive) names() ([]string, error) {
+	files, err := ioutil.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), feedArchiveExtension) {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Write archives a body fetched at the provided time and deletes the oldest bodies beyond the limit.
+func (a *FeedArchive) Write(body []byte, fetched time.Time) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	name := fetched.UTC().Format(feedArchiveTimeFormat) + feedArchiveExtension
+	if err := ioutil.WriteFile(filepath.Join(a.dir, name), body, 0644); err != nil {
+		return err
+	}
+
+	names, err := a.names()
+	if err != nil {
+		return err
+	}
+	for len(names) > a.maxFiles {
+		if err := os.Remove(filepath.Join(a.dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// Latest returns up to n of the most recently archived bodies, oldest first.
+func (a *FeedArchive) Latest(n int) ([]ArchivedFeed, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	names, err := a.names()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		n = 0
+	}
+	if len(names) > n {
+		names = names[len(names)-n:]
+	}
+
+	feeds := []ArchivedFeed{}
+	for _, name := range names {
+		fetched, err := time.ParseInLocation(feedArchiveTimeFormat, strings.TrimSuffix(name, feedArchiveExtension), time.UTC)
+		if err != nil {
+			// not a file we wrote
+			continue
+		}
+		body, err := ioutil.ReadFile(filepath.Join(a.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, ArchivedFeed{Fetched: fetched, Body: body})
+	}
+	return feeds, nil
+}
+
+// FeedReplay is the result of parsing an archived data feed response body again.
+type FeedReplay struct {
+	Fetched   time.Time                  `json:"fetched"`
+	Locations []*shuttletracker.Location `json:"locations"`
+	Issues    []ParseIssue               `json:"issues"`
+}
+
+// ReplayLatest parses the last n archived data feed bodies with the current Config, as PreviewFeed
+// does, oldest first. Nothing is stored. ErrFeedArchiveDisabled is returned if no archive is configured.
+func (u *Updater) ReplayLatest(n int) ([]FeedReplay, error) {
+	cfg := u.config()
+	u.mutex.Lock()
+	archive := u.feedArchive
+	u.mutex.Unlock()
+	if archive == nil {
+		return nil, ErrFeedArchiveDisabled
+	}
+
+	feeds, err := archive.Latest(n)
+	if err != nil {
+		return nil, err
+	}
+	replays := []FeedReplay{}
+	for _, feed := range feeds {
+		locations, issues, err := u.previewBody(cfg, feed.Body)
+		if err != nil {
+			return nil, err
+		}
+		replays = append(replays, FeedReplay{Fetched: feed.Fetched, Locations: locations, Issues: issues})
+	}
+	return replays, nil
+}
@@ -0,0 +1,97 @@
+package updater
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestFeedArchiveRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feedarchive")
+	if err != nil {
+		t.Fatalf("unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive, err := NewFeedArchive(dir, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	start := time.Date(2018, time.April, 16, 8, 0, 0, 0, time.UTC)
+	for i, body := range []string{"a", "b", "c", "d", "e"} {
+		err = archive.Write([]byte(body), start.Add(time.Second*time.Duration(i)))
+		if err != nil {
+			t.Fatalf("unable to write body: %s", err)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unable to read directory: %s", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("got %d files, expected 3", len(files))
+	}
+	feeds, err := archive.Latest(2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(feeds) != 2 || string(feeds[0].Body) != "d" || string(feeds[1].Body) != "e" {
+		t.Fatalf("got %+v, expected the last two bodies", feeds)
+	}
+	if !feeds[1].Fetched.Equal(start.Add(time.Second * 4)) {
+		t.Errorf("got fetched time %v, expected %v", feeds[1].Fetched, start.Add(time.Second*4))
+	}
+	feeds, err = archive.Latest(10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(feeds) != 3 || string(feeds[0].Body) != "c" {
+		t.Errorf("got %+v, expected the three kept bodies", feeds)
+	}
+}
+
+func TestReplayLatest(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := u.ReplayLatest(1); err != ErrFeedArchiveDisabled {
+		t.Errorf("got error %v, expected %v", err, ErrFeedArchiveDisabled)
+	}
+
+	dir, err := ioutil.TempDir("", "feedarchive")
+	if err != nil {
+		t.Fatalf("unable to create directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	vehicle := &shuttletracker.Vehicle{ID: 1, TrackerID: "1234"}
+	ms := &mock.ModelService{}
+	ms.VehicleService.On("VehicleWithTrackerID", "1234").Return(vehicle, nil)
+	u, err = New(Config{UpdateInterval: "10s", FeedArchiveDir: dir}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fetched := time.Date(2018, time.April, 16, 8, 0, 0, 0, time.UTC)
+	err = u.feedArchive.Write([]byte("Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:52957 date:04162018eof"+
+		"Vehicle ID:1234 lat:north lon:-73.67543 time:52957 date:04162018eof"), fetched)
+	if err != nil {
+		t.Fatalf("unable to write body: %s", err)
+	}
+
+	replays, err := u.ReplayLatest(5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(replays) != 1 || !replays[0].Fetched.Equal(fetched) {
+		t.Fatalf("got %+v, expected one replay", replays)
+	}
+	if len(replays[0].Locations) != 1 || len(replays[0].Issues) != 1 {
+		t.Errorf("got %d locations and %d issues, expected one of each", len(replays[0].Locations), len(replays[0].Issues))
+	}
+}
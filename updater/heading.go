@@ -0,0 +1,74 @@
+package updater
+
+import (
+	"math"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/log"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+// headingPenalty returns how badly a heading conflicts with the direction of travel along a polyline
+// at the point with index nearest, from 0 when they match to 1 when they are opposite. Polylines
+// without a direction, like a single point, never conflict.
+func headingPenalty(heading float64, polyline []shuttletracker.Point, nearest int) float64 {
+	if len(polyline) < 2 || nearest < 0 {
+		return 0
+	}
+	from, to := nearest, nearest+1
+	if to == len(polyline) {
+		from, to = nearest-1, nearest
+	}
+	direction := spatial.Bearing(polyline[from].Latitude, polyline[from].Longitude, polyline[to].Latitude, polyline[to].Longitude)
+	return (1 - math.Cos((heading-direction)*math.Pi/180)) / 2
+}
+
+// minHeadingMovement is how far in meters a vehicle must have moved since its previous Location for the
+// bearing between them to be used as its heading.
+const minHeadingMovement = 5.0
+
+// validHeading returns whether a reported heading can be trusted. Trackers that don't know their
+// heading report zero, so a vehicle heading due north is indistinguishable and treated the same.
+func validHeading(heading float64) bool {
+	return heading > 0 && heading < 360
+}
+
+// previousLocation returns the latest stored Location of an update's vehicle when deriving its speed
+// or filling in its heading needs it, so that both share a single query. It returns nil when neither
+// does, when the vehicle has no Locations, or when the Location can't be read.
+func (u *Updater) previousLocation(vehicle *shuttletracker.Vehicle, update *shuttletracker.Location, cfg Config) *shuttletracker.Location {
+	deriving := cfg.DeriveSpeedDistance > 0 && update.Speed == 0
+	filling := cfg.ComputeHeading && !validHeading(update.Heading)
+	if !deriving && !filling {
+		return nil
+	}
+	previous, err := u.ms.LatestLocation(vehicle.ID)
+	if err == shuttletracker.ErrLocationNotFound {
+		return nil
+	} else if err != nil {
+		log.WithError(err).Error("Unable to get previous location.")
+		return nil
+	}
+	return previous
+}
+
+// fillHeading replaces an update's heading when it isn't valid. A moving vehicle's heading becomes the
+// bearing from its previous Location, and a stationary vehicle keeps its previous heading. A nil
+// previous leaves the heading as it is.
+func fillHeading(previous, update *shuttletracker.Location) {
+	if validHeading(update.Heading) || previous == nil {
+		return
+	}
+	if !previous.Time.Before(update.Time) {
+		// an out-of-order record would point the bearing backwards
+		return
+	}
+
+	from := shuttletracker.Point{Latitude: previous.Latitude, Longitude: previous.Longitude}
+	to := shuttletracker.Point{Latitude: update.Latitude, Longitude: update.Longitude}
+	if update.Speed > movingSpeed && spatial.DistanceBetween(from, to) >= minHeadingMovement {
+		update.Heading = spatial.Bearing(previous.Latitude, previous.Longitude, update.Latitude, update.Longitude)
+		return
+	}
+	update.Heading = previous.Heading
+}
@@ -0,0 +1,141 @@
+package updater
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestHeadingPenalty(t *testing.T) {
+	eastbound := []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.68}, {Latitude: 42.73, Longitude: -73.67}}
+	cases := []struct {
+		heading float64
+		nearest int
+		penalty float64
+	}{
+		{90, 0, 0},
+		{270, 0, 1},
+		{0, 1, 0.5},
+		{-270, 1, 0},
+	}
+	for _, c := range cases {
+		if penalty := headingPenalty(c.heading, eastbound, c.nearest); math.Abs(penalty-c.penalty) > 0.001 {
+			t.Errorf("got penalty %f for heading %f, expected %f", penalty, c.heading, c.penalty)
+		}
+	}
+	if penalty := headingPenalty(270, eastbound[:1], 0); penalty != 0 {
+		t.Errorf("got penalty %f for a single point, expected 0", penalty)
+	}
+}
+
+func TestGuessRouteHeading(t *testing.T) {
+	// two routes along the same street in opposite directions
+	eastbound := &shuttletracker.Route{
+		ID:      1,
+		Enabled: true,
+		Active:  true,
+		Points:  []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.68}, {Latitude: 42.73, Longitude: -73.67}},
+	}
+	westbound := &shuttletracker.Route{
+		ID:      2,
+		Enabled: true,
+		Active:  true,
+		Points:  []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.67}, {Latitude: 42.73, Longitude: -73.68}},
+	}
+	vehicle := &shuttletracker.Vehicle{ID: 1, Name: "Vehicle 1", Enabled: true}
+	updates := []*shuttletracker.Location{}
+	for i := 0; i < 5; i++ {
+		updates = append(updates, &shuttletracker.Location{Latitude: 42.73, Longitude: -73.672, Heading: 265, Speed: 15})
+	}
+
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Routes").Return([]*shuttletracker.Route{eastbound, westbound}, nil)
+	ms.RouteService.On("Route", eastbound.ID).Return(eastbound, nil)
+	ms.RouteService.On("Route", westbound.ID).Return(westbound, nil)
+	ms.LocationService.On("LocationsSince", int64(1)).Return(updates, nil)
+	u, err := New(Config{UpdateInterval: "10s", HeadingWeight: 1}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	guess, err := u.GuessRouteForVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if guess == nil || guess.ID != westbound.ID {
+		t.Errorf("got %+v, expected the westbound route", guess)
+	}
+
+	_, err = New(Config{UpdateInterval: "10s", HeadingWeight: -1}, ms)
+	if err == nil {
+		t.Error("expected an error for a negative heading weight")
+	}
+}
+
+func TestFillHeading(t *testing.T) {
+	now := time.Now()
+	previous := &shuttletracker.Location{Latitude: 42.73, Longitude: -73.68, Heading: 45, Time: now.Add(-time.Second * 10)}
+
+	cases := []struct {
+		name    string
+		update  *shuttletracker.Location
+		heading float64
+	}{
+		{"moving", &shuttletracker.Location{Latitude: 42.73, Longitude: -73.679, Speed: 20, Time: now}, 90},
+		{"stationary", &shuttletracker.Location{Latitude: 42.73, Longitude: -73.67999, Speed: 0, Time: now}, 45},
+		{"reported", &shuttletracker.Location{Latitude: 42.73, Longitude: -73.679, Heading: 180, Speed: 20, Time: now}, 180},
+		{"out of order", &shuttletracker.Location{Latitude: 42.73, Longitude: -73.679, Speed: 20, Time: now.Add(-time.Minute)}, 0},
+	}
+	for _, c := range cases {
+		fillHeading(previous, c.update)
+		if math.Abs(c.update.Heading-c.heading) > 0.01 {
+			t.Errorf("%s: got heading %f, expected %f", c.name, c.update.Heading, c.heading)
+		}
+	}
+
+	update := &shuttletracker.Location{Latitude: 42.73, Longitude: -73.679, Speed: 20, Time: now}
+	fillHeading(nil, update)
+	if update.Heading != 0 {
+		t.Errorf("got heading %f without a previous location, expected 0", update.Heading)
+	}
+}
+
+func TestPreviousLocation(t *testing.T) {
+	now := time.Now()
+	previous := &shuttletracker.Location{Latitude: 42.73, Longitude: -73.68, Heading: 45, Time: now.Add(-time.Second * 10)}
+	vehicle := &shuttletracker.Vehicle{ID: 1}
+	ms := &mock.ModelService{}
+	ms.LocationService.On("LatestLocation", vehicle.ID).Return(previous, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cfg := Config{DeriveSpeedDistance: 20, ComputeHeading: true}
+
+	// a record with a speed and a valid heading doesn't need its previous Location
+	reported := &shuttletracker.Location{Heading: 90, Speed: 20, Time: now}
+	if u.previousLocation(vehicle, reported, cfg) != nil {
+		t.Error("expected no previous location for a record that needs none")
+	}
+	ms.LocationService.AssertNumberOfCalls(t, "LatestLocation", 0)
+
+	// one query serves both deriving the speed and filling in the heading
+	stuck := &shuttletracker.Location{Time: now}
+	if u.previousLocation(vehicle, stuck, cfg) != previous {
+		t.Error("expected the previous location for a record without a speed or heading")
+	}
+	ms.LocationService.AssertNumberOfCalls(t, "LatestLocation", 1)
+
+	ms = &mock.ModelService{}
+	ms.LocationService.On("LatestLocation", vehicle.ID).Return((*shuttletracker.Location)(nil), shuttletracker.ErrLocationNotFound)
+	u, err = New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if u.previousLocation(vehicle, stuck, cfg) != nil {
+		t.Error("expected no previous location for a vehicle without Locations")
+	}
+}
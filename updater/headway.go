@@ -0,0 +1,122 @@
+package updater
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+const (
+	// headwayActivity is how recently a vehicle must have reported to be counted in headways.
+	headwayActivity = time.Minute * 5
+
+	// headwaySpeedSamples is the number of recent Locations per vehicle used to estimate speed.
+	headwaySpeedSamples = 10
+
+	// fallbackSpeed is the typical shuttle speed in meters per second, used if no vehicle on a route is moving.
+	fallbackSpeed = 4.5
+
+	metersPerSecondPerMPH = 0.44704
+)
+
+// ErrInsufficientArrivals indicates that too few vehicles arrived at a stop to measure the time
+// between them.
+var ErrInsufficientArrivals = errors.New("fewer than two arrivals at stop")
+
+// Headways estimates the time gaps between consecutive vehicles on a route. Vehicles are ordered by
+// how far along the route they are, and each gap is the time the vehicle behind needs to reach the
+// position of the vehicle in front at the recent average speed of all vehicles on the route.
+// Loop routes include the gap from the furthest vehicle around to the first. A route with fewer than
+// two vehicles has no headways.
+func (u *Updater) Headways(routeID int64) ([]time.Duration, error) {
+	route, err := u.ms.Route(routeID)
+	if err != nil {
+		return nil, err
+	}
+	vehicles, err := u.ms.RecentlyActiveVehicles(headwayActivity)
+	if err != nil {
+		return nil, err
+	}
+
+	positions := []float64{}
+	var speedTotal float64
+	var speedSamples int
+	for _, vehicle := range vehicles {
+		location, err := u.ms.LatestLocation(vehicle.ID)
+		if err == shuttletracker.ErrLocationNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if location.RouteID == nil || *location.RouteID != routeID {
+			continue
+		}
+		point := shuttletracker.Point{Latitude: location.Latitude, Longitude: location.Longitude}
+		positions = append(positions, spatial.Project(point, route.Points).Along)
+
+		recent, err := u.ms.RecentLocations(vehicle.ID, headwaySpeedSamples)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range recent {
+			speedTotal += l.Speed
+			speedSamples++
+		}
+	}
+
+	headways := []time.Duration{}
+	if len(positions) < 2 {
+		return headways, nil
+	}
+	speed := fallbackSpeed
+	if speedTotal > 0 {
+		speed = speedTotal / float64(speedSamples) * metersPerSecondPerMPH
+	}
+
+	sort.Float64s(positions)
+	gaps := []float64{}
+	for i := 1; i < len(positions); i++ {
+		gaps = append(gaps, positions[i]-positions[i-1])
+	}
+	if spatial.IsLoop(route.Points, loopTolerance) {
+		gaps = append(gaps, spatial.Length(route.Points)-positions[len(positions)-1]+positions[0])
+	}
+	for _, gap := range gaps {
+		headways = append(headways, time.Duration(gap/speed*float64(time.Second)))
+	}
+	return headways, nil
+}
+
+// AverageStopHeadway returns the mean time between consecutive arrivals at a stop between start and
+// end, counting vehicles on every route that serves it. ErrInsufficientArrivals is returned if fewer
+// than two vehicles arrived.
+func (u *Updater) AverageStopHeadway(stopID int64, start, end time.Time) (time.Duration, error) {
+	routes, err := u.ms.RoutesForStop(stopID)
+	if err != nil {
+		return 0, err
+	}
+	arrivals := []time.Time{}
+	for _, route := range routes {
+		routeArrivals, err := u.stopArrivals(route, start, end)
+		if err != nil {
+			return 0, err
+		}
+		arrivals = append(arrivals, routeArrivals[stopID]...)
+	}
+	if len(arrivals) < 2 {
+		return 0, ErrInsufficientArrivals
+	}
+
+	// the mean of the gaps between sorted arrivals is the span divided by the number of gaps
+	sort.Sort(byTime(arrivals))
+	return arrivals[len(arrivals)-1].Sub(arrivals[0]) / time.Duration(len(arrivals)-1), nil
+}
+
+type byTime []time.Time
+
+func (t byTime) Len() int           { return len(t) }
+func (t byTime) Less(i, j int) bool { return t[i].Before(t[j]) }
+func (t byTime) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
@@ -0,0 +1,140 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+func TestHeadways(t *testing.T) {
+	routeID := int64(1)
+	otherRouteID := int64(2)
+	route := &shuttletracker.Route{
+		ID: routeID,
+		Points: []shuttletracker.Point{
+			{Latitude: 42.730, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.670},
+			{Latitude: 42.740, Longitude: -73.670},
+			{Latitude: 42.740, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.680},
+		},
+	}
+	length := spatial.Length(route.Points)
+	side := spatial.Length(route.Points[:2])
+
+	vehicles := []*shuttletracker.Vehicle{{ID: 1}, {ID: 2}, {ID: 3}}
+	// vehicle 1 is at the start, vehicle 2 at the end of the first side, and vehicle 3 is on another route
+	locations := []*shuttletracker.Location{
+		{Latitude: 42.730, Longitude: -73.680, Speed: 10, RouteID: &routeID},
+		{Latitude: 42.730, Longitude: -73.670, Speed: 10, RouteID: &routeID},
+		{Latitude: 42.735, Longitude: -73.670, Speed: 30, RouteID: &otherRouteID},
+	}
+
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Route", routeID).Return(route, nil)
+	ms.VehicleService.On("RecentlyActiveVehicles", headwayActivity).Return(vehicles, nil)
+	for i, vehicle := range vehicles {
+		ms.LocationService.On("LatestLocation", vehicle.ID).Return(locations[i], nil)
+		ms.LocationService.On("RecentLocations", vehicle.ID, headwaySpeedSamples).Return([]*shuttletracker.Location{locations[i]}, nil)
+	}
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	headways, err := u.Headways(routeID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	speed := 10 * metersPerSecondPerMPH
+	expected := []time.Duration{
+		time.Duration(side / speed * float64(time.Second)),
+		time.Duration((length - side) / speed * float64(time.Second)),
+	}
+	if len(headways) != len(expected) {
+		t.Fatalf("got headways %v, expected %v", headways, expected)
+	}
+	for i := range expected {
+		if d := headways[i] - expected[i]; d > time.Second || d < -time.Second {
+			t.Errorf("got headway %v, expected %v", headways[i], expected[i])
+		}
+	}
+}
+
+func TestHeadwaysSingleVehicle(t *testing.T) {
+	routeID := int64(1)
+	route := &shuttletracker.Route{
+		ID:     routeID,
+		Points: []shuttletracker.Point{{Latitude: 42.730, Longitude: -73.680}, {Latitude: 42.730, Longitude: -73.670}},
+	}
+	location := &shuttletracker.Location{Latitude: 42.730, Longitude: -73.675, RouteID: &routeID}
+
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Route", routeID).Return(route, nil)
+	ms.VehicleService.On("RecentlyActiveVehicles", headwayActivity).Return([]*shuttletracker.Vehicle{{ID: 1}}, nil)
+	ms.LocationService.On("LatestLocation", int64(1)).Return(location, nil)
+	ms.LocationService.On("RecentLocations", int64(1), headwaySpeedSamples).Return([]*shuttletracker.Location{location}, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	headways, err := u.Headways(routeID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(headways) != 0 {
+		t.Errorf("got headways %v, expected none", headways)
+	}
+}
+
+func TestAverageStopHeadway(t *testing.T) {
+	first := int64(1)
+	second := int64(2)
+	routes := []*shuttletracker.Route{{ID: first, StopIDs: []int64{10}}, {ID: second, StopIDs: []int64{10}}}
+	stops := []*shuttletracker.Stop{{ID: 10, Latitude: 42.730, Longitude: -73.680}}
+	start := time.Date(2018, time.April, 16, 8, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	at := func(minute int) time.Time {
+		return start.Add(time.Minute * time.Duration(minute))
+	}
+	atStop := func(minute int, routeID *int64) *shuttletracker.Location {
+		return &shuttletracker.Location{Latitude: 42.730, Longitude: -73.680, Time: at(minute), RouteID: routeID}
+	}
+	away := func(minute int, routeID *int64) *shuttletracker.Location {
+		return &shuttletracker.Location{Latitude: 42.740, Longitude: -73.670, Time: at(minute), RouteID: routeID}
+	}
+
+	ms := &mock.ModelService{}
+	ms.RouteService.On("RoutesForStop", int64(10)).Return(routes, nil)
+	ms.StopService.On("Stops").Return(stops, nil)
+	ms.VehicleService.On("Vehicles").Return([]*shuttletracker.Vehicle{{ID: 1}, {ID: 2}}, nil)
+	// vehicle 1 arrives twice on the first route, and vehicle 2 once on the second in between
+	ms.LocationService.On("LocationsBetween", int64(1), start, end, shuttletracker.Collapse{}).Return([]*shuttletracker.Location{
+		atStop(1, &first), atStop(2, &first), away(10, &first), atStop(21, &first),
+	}, nil)
+	ms.LocationService.On("LocationsBetween", int64(2), start, end, shuttletracker.Collapse{}).Return([]*shuttletracker.Location{
+		away(5, &second), atStop(9, &second),
+	}, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	headway, err := u.AverageStopHeadway(10, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if headway != time.Minute*10 {
+		t.Errorf("got headway %s, expected 10m", headway)
+	}
+
+	ms.RouteService.On("RoutesForStop", int64(20)).Return(routes[:1], nil)
+	_, err = u.AverageStopHeadway(20, start, end)
+	if err != ErrInsufficientArrivals {
+		t.Errorf("got error %v, expected ErrInsufficientArrivals", err)
+	}
+}
@@ -0,0 +1,110 @@
+package updater
+
+import (
+	"errors"
+	"time"
+)
+
+// maxFetchHistory is how many data feed fetches are remembered for FeedHealthSummary. At the default
+// update interval this covers several hours.
+const maxFetchHistory = 2000
+
+// fetchOutcome is the result of a single data feed request.
+type fetchOutcome struct {
+	time    time.Time
+	success bool
+	latency time.Duration
+}
+
+// FeedHealth summarizes the data feed requests made within a window of time.
+type FeedHealth struct {
+	Window    time.Duration `json:"window"`
+	Fetches   int           `json:"fetches"`
+	Successes int           `json:"successes"`
+
+	// SuccessRate is the fraction of Fetches that succeeded, from 0 to 1. It is zero without fetches.
+	SuccessRate float64 `json:"success_rate"`
+
+	// AverageLatency is the mean time taken for the data feed to respond, including failed requests.
+	AverageLatency time.Duration `json:"average_latency"`
+}
+
+// recordFetch remembers the outcome of a data feed request made at requested. A request succeeds if
+// the data feed responds with a usable status code. Only the most recent maxFetchHistory are kept.
+func (u *Updater) recordFetch(requested time.Time, success bool, latency time.Duration) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if len(u.fetchHistory) == maxFetchHistory {
+		copy(u.fetchHistory, u.fetchHistory[1:])
+		u.fetchHistory = u.fetchHistory[:maxFetchHistory-1]
+	}
+	u.fetchHistory = append(u.fetchHistory, fetchOutcome{time: requested, success: success, latency: latency})
+}
+
+// FeedHealthSummary summarizes the data feed requests made within window of now. Windows longer than
+// the remembered history only cover what is remembered.
+func (u *Updater) FeedHealthSummary(window time.Duration) FeedHealth {
+	since := time.Now().Add(-window)
+	health := FeedHealth{Window: window}
+	var latency time.Duration
+
+	u.mutex.Lock()
+	for _, fetch := range u.fetchHistory {
+		if fetch.time.Before(since) {
+			continue
+		}
+		health.Fetches++
+		if fetch.success {
+			health.Successes++
+		}
+		latency += fetch.latency
+	}
+	u.mutex.Unlock()
+
+	if health.Fetches > 0 {
+		health.SuccessRate = float64(health.Successes) / float64(health.Fetches)
+		health.AverageLatency = latency / time.Duration(health.Fetches)
+	}
+	return health
+}
+
+// ErrFeedDown indicates that the most recent data feed request failed.
+var ErrFeedDown = errors.New("data feed is down")
+
+// FeedStatus returns ErrFeedDown if the most recent data feed request failed, unless that has been
+// acknowledged with AcknowledgeFeedDown.
+func (u *Updater) FeedStatus() error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if len(u.fetchHistory) == 0 || u.fetchHistory[len(u.fetchHistory)-1].success {
+		return nil
+	}
+	if time.Now().Before(u.feedDownUntil) {
+		return nil
+	}
+	return ErrFeedDown
+}
+
+// AcknowledgeFeedDown silences a data feed outage that is expected, e.g. during planned maintenance.
+// Until until, FeedStatus doesn't report ErrFeedDown and failed data feed requests are only logged
+// at the debug level. A time in the past ends an acknowledgment early.
+func (u *Updater) AcknowledgeFeedDown(until time.Time) {
+	u.mutex.Lock()
+	u.feedDownUntil = until
+	u.mutex.Unlock()
+}
+
+// FeedDownAcknowledgedUntil returns when the current acknowledgment of a data feed outage ends, and
+// whether there is one.
+func (u *Updater) FeedDownAcknowledgedUntil() (time.Time, bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	until := u.feedDownUntil
+	return until, time.Now().Before(until)
+}
+
+// feedDownAcknowledged returns whether data feed failures are currently acknowledged.
+func (u *Updater) feedDownAcknowledged() bool {
+	_, acknowledged := u.FeedDownAcknowledgedUntil()
+	return acknowledged
+}
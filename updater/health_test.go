@@ -0,0 +1,111 @@
+package updater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestFeedHealthSummary(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, &mock.ModelService{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	health := u.FeedHealthSummary(time.Hour)
+	if health.Fetches != 0 || health.SuccessRate != 0 || health.AverageLatency != 0 {
+		t.Errorf("got %+v, expected an empty summary", health)
+	}
+
+	now := time.Now()
+	u.recordFetch(now.Add(-time.Hour*2), false, time.Second)
+	u.recordFetch(now.Add(-time.Minute*3), true, time.Millisecond*100)
+	u.recordFetch(now.Add(-time.Minute*2), true, time.Millisecond*200)
+	u.recordFetch(now.Add(-time.Minute), false, time.Millisecond*300)
+	u.recordFetch(now, true, time.Millisecond*200)
+
+	health = u.FeedHealthSummary(time.Hour)
+	if health.Fetches != 4 || health.Successes != 3 {
+		t.Errorf("got %d fetches with %d successes, expected 4 with 3", health.Fetches, health.Successes)
+	}
+	if health.SuccessRate != 0.75 {
+		t.Errorf("got success rate %f, expected 0.75", health.SuccessRate)
+	}
+	if health.AverageLatency != time.Millisecond*200 {
+		t.Errorf("got average latency %s, expected 200ms", health.AverageLatency)
+	}
+}
+
+func TestFeedHealthBounded(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, &mock.ModelService{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for i := 0; i < maxFetchHistory+10; i++ {
+		u.recordFetch(time.Now(), i >= 10, 0)
+	}
+	health := u.FeedHealthSummary(time.Hour)
+	if health.Fetches != maxFetchHistory || health.Successes != maxFetchHistory {
+		t.Errorf("got %+v, expected only the latest %d fetches", health, maxFetchHistory)
+	}
+}
+
+func TestUpdateRecordsFetchHealth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	u, err := New(Config{UpdateInterval: "10s", DataFeed: server.URL}, &mock.ModelService{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	u.update()
+	health := u.FeedHealthSummary(time.Minute)
+	if health.Fetches != 1 || health.Successes != 0 {
+		t.Errorf("got %+v, expected one failed fetch", health)
+	}
+}
+
+func TestAcknowledgeFeedDown(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, &mock.ModelService{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := u.FeedStatus(); err != nil {
+		t.Errorf("got %v before any fetch, expected no error", err)
+	}
+
+	now := time.Now()
+	u.recordFetch(now, false, time.Second)
+	if err := u.FeedStatus(); err != ErrFeedDown {
+		t.Errorf("got %v after a failed fetch, expected ErrFeedDown", err)
+	}
+
+	until := now.Add(time.Hour)
+	u.AcknowledgeFeedDown(until)
+	if err := u.FeedStatus(); err != nil {
+		t.Errorf("got %v while acknowledged, expected no error", err)
+	}
+	acknowledged, ok := u.FeedDownAcknowledgedUntil()
+	if !ok || !acknowledged.Equal(until) {
+		t.Errorf("got acknowledgment until %v (%t), expected %v", acknowledged, ok, until)
+	}
+
+	// an acknowledgment that has ended no longer silences the outage
+	u.AcknowledgeFeedDown(now.Add(-time.Second))
+	if _, ok := u.FeedDownAcknowledgedUntil(); ok {
+		t.Error("expected the acknowledgment to have ended")
+	}
+	if err := u.FeedStatus(); err != ErrFeedDown {
+		t.Errorf("got %v after the acknowledgment ended, expected ErrFeedDown", err)
+	}
+
+	u.recordFetch(now, true, time.Second)
+	if err := u.FeedStatus(); err != nil {
+		t.Errorf("got %v after a successful fetch, expected no error", err)
+	}
+}
@@ -0,0 +1,26 @@
+package updater
+
+// routeThresholds returns the on-route and off-route thresholds from cfg, substituting defaults for unset values.
+func routeThresholds(cfg Config) (on, off float64) {
+	on, off = cfg.OnRouteThreshold, cfg.OffRouteThreshold
+	if on == 0 {
+		on = defaultOnRouteThreshold
+	}
+	if off == 0 {
+		off = defaultOffRouteThreshold
+	}
+	return on, off
+}
+
+// previousRoute returns the ID of the route that a vehicle was last guessed to be on, or zero if none.
+func (u *Updater) previousRoute(vehicleID int64) int64 {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.previousRoutes[vehicleID]
+}
+
+func (u *Updater) setPreviousRoute(vehicleID, routeID int64) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.previousRoutes[vehicleID] = routeID
+}
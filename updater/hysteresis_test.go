@@ -0,0 +1,144 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestGuessRouteHysteresis(t *testing.T) {
+	route := &shuttletracker.Route{
+		ID:      1,
+		Enabled: true,
+		Active:  true,
+		Points:  []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.67}},
+	}
+	vehicle := &shuttletracker.Vehicle{ID: 1, Name: "Vehicle 1", Enabled: true}
+
+	// one far sample out of ten puts the average distance just above the on-route threshold
+	updates := []*shuttletracker.Location{{Latitude: 42.74, Longitude: -73.67}}
+	for i := 0; i < 9; i++ {
+		updates = append(updates, &shuttletracker.Location{Latitude: 42.73, Longitude: -73.67})
+	}
+
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Routes").Return([]*shuttletracker.Route{route}, nil)
+	ms.RouteService.On("Route", int64(1)).Return(route, nil)
+	ms.LocationService.On("LocationsSince", int64(1)).Return(updates, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	guess, err := u.GuessRouteForVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if guess != nil {
+		t.Errorf("vehicle joined route %d above the on-route threshold", guess.ID)
+	}
+
+	u.setPreviousRoute(vehicle.ID, route.ID)
+	guess, err = u.GuessRouteForVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if guess == nil || guess.ID != route.ID {
+		t.Errorf("got %+v, expected vehicle to stay on route below the off-route threshold", guess)
+	}
+}
+
+func TestGuessRouteCommit(t *testing.T) {
+	route := &shuttletracker.Route{
+		ID:      1,
+		Enabled: true,
+		Active:  true,
+		Points:  []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.67}},
+	}
+	vehicle := &shuttletracker.Vehicle{ID: 1, Name: "Vehicle 1", Enabled: true}
+	updates := []*shuttletracker.Location{}
+	for i := 0; i < 5; i++ {
+		updates = append(updates, &shuttletracker.Location{Latitude: 42.73, Longitude: -73.67})
+	}
+
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Routes").Return([]*shuttletracker.Route{route}, nil)
+	ms.RouteService.On("Route", route.ID).Return(route, nil)
+	ms.LocationService.On("LocationsSince", vehicle.ID).Return(updates, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = u.GuessRouteForVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if previous := u.previousRoute(vehicle.ID); previous != 0 {
+		t.Errorf("got previous route %d after a query, expected it to be unchanged", previous)
+	}
+
+	guess, err := u.guessRoute(vehicle, []*shuttletracker.Route{route}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	u.commitRouteGuess(vehicle, guess)
+	if previous := u.previousRoute(vehicle.ID); previous != route.ID {
+		t.Errorf("got previous route %d after committing, expected %d", previous, route.ID)
+	}
+}
+
+func TestGuessRouteSwitchesToCloserRoute(t *testing.T) {
+	// the vehicle left the previous route for the next one, which shares part of its path
+	previous := &shuttletracker.Route{
+		ID:      1,
+		Enabled: true,
+		Active:  true,
+		Points:  []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.67}},
+	}
+	next := &shuttletracker.Route{
+		ID:      2,
+		Enabled: true,
+		Active:  true,
+		Points:  []shuttletracker.Point{{Latitude: 42.73, Longitude: -73.67}, {Latitude: 42.74, Longitude: -73.67}},
+	}
+	vehicle := &shuttletracker.Vehicle{ID: 1, Name: "Vehicle 1", Enabled: true}
+
+	// one sample in ten is off the previous route, within its off-route threshold
+	updates := []*shuttletracker.Location{{Latitude: 42.74, Longitude: -73.67}}
+	for i := 0; i < 9; i++ {
+		updates = append(updates, &shuttletracker.Location{Latitude: 42.73, Longitude: -73.67})
+	}
+
+	ms := &mock.ModelService{}
+	ms.LocationService.On("LocationsSince", vehicle.ID).Return(updates, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	guess, err := u.guessRoute(vehicle, []*shuttletracker.Route{previous, next}, previous.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if guess.routeID != next.ID {
+		t.Errorf("got route %d, expected the vehicle to switch to route %d", guess.routeID, next.ID)
+	}
+
+	// without a clearly closer route, the vehicle stays where it was
+	guess, err = u.guessRoute(vehicle, []*shuttletracker.Route{previous}, previous.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if guess.routeID != previous.ID {
+		t.Errorf("got route %d, expected the vehicle to stay on route %d", guess.routeID, previous.ID)
+	}
+}
+
+func TestParseConfigRouteThresholds(t *testing.T) {
+	_, err := parseConfig(Config{UpdateInterval: "10s", OnRouteThreshold: 8, OffRouteThreshold: 4})
+	if err == nil {
+		t.Error("expected an error for an off-route threshold below the on-route threshold")
+	}
+}
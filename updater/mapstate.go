@@ -0,0 +1,63 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// MapState is everything the tracking map needs to draw a single frame.
+type MapState struct {
+	Time      time.Time                  `json:"time"`
+	Vehicles  []*shuttletracker.Vehicle  `json:"vehicles"`
+	Locations []*shuttletracker.Location `json:"locations"`
+	Routes    []*shuttletracker.Route    `json:"routes"`
+	Stops     []*shuttletracker.Stop     `json:"stops"`
+}
+
+// MapState returns the enabled Vehicles, their latest Locations, the enabled and active Routes, and
+// all Stops. It waits for any in-progress update to finish so that every Location belongs to the same
+// data feed cycle as the Vehicles and Routes returned alongside it.
+func (u *Updater) MapState() (MapState, error) {
+	u.cycleMutex.RLock()
+	defer u.cycleMutex.RUnlock()
+
+	state := MapState{
+		Time:      time.Now(),
+		Locations: []*shuttletracker.Location{},
+		Routes:    []*shuttletracker.Route{},
+	}
+
+	vehicles, err := u.ms.EnabledVehicles()
+	if err != nil {
+		return MapState{}, err
+	}
+	state.Vehicles = vehicles
+	for _, vehicle := range vehicles {
+		location, err := u.ms.LatestLocation(vehicle.ID)
+		if err == shuttletracker.ErrLocationNotFound {
+			continue
+		} else if err != nil {
+			return MapState{}, err
+		}
+		state.Locations = append(state.Locations, location)
+	}
+
+	routes, err := u.ms.Routes()
+	if err != nil {
+		return MapState{}, err
+	}
+	for _, route := range routes {
+		if route.Enabled && route.Active {
+			state.Routes = append(state.Routes, route)
+		}
+	}
+
+	stops, err := u.ms.Stops()
+	if err != nil {
+		return MapState{}, err
+	}
+	state.Stops = stops
+
+	return state, nil
+}
@@ -0,0 +1,47 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestMapState(t *testing.T) {
+	ms := &mock.ModelService{}
+	vehicles := []*shuttletracker.Vehicle{{ID: 1, Enabled: true}, {ID: 2, Enabled: true}}
+	location := &shuttletracker.Location{ID: 10}
+	routes := []*shuttletracker.Route{
+		{ID: 1, Enabled: true, Active: true},
+		{ID: 2, Enabled: true, Active: false},
+		{ID: 3, Enabled: false, Active: true},
+	}
+	stops := []*shuttletracker.Stop{{ID: 1}}
+	ms.VehicleService.On("EnabledVehicles").Return(vehicles, nil)
+	ms.LocationService.On("LatestLocation", int64(1)).Return(location, nil)
+	ms.LocationService.On("LatestLocation", int64(2)).Return((*shuttletracker.Location)(nil), shuttletracker.ErrLocationNotFound)
+	ms.RouteService.On("Routes").Return(routes, nil)
+	ms.StopService.On("Stops").Return(stops, nil)
+
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	state, err := u.MapState()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(state.Vehicles) != 2 {
+		t.Errorf("got %d vehicles, expected 2", len(state.Vehicles))
+	}
+	if len(state.Locations) != 1 || state.Locations[0] != location {
+		t.Errorf("got locations %+v, expected only vehicle 1's", state.Locations)
+	}
+	if len(state.Routes) != 1 || state.Routes[0].ID != 1 {
+		t.Errorf("got routes %+v, expected only route 1", state.Routes)
+	}
+	if len(state.Stops) != 1 {
+		t.Errorf("got %d stops, expected 1", len(state.Stops))
+	}
+}
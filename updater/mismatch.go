@@ -0,0 +1,81 @@
+package updater
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// mismatchWindow is how long a vehicle must be guessed to be on a route other than its scheduled one
+// before it is reported, so that a guess flapping near where two routes overlap isn't.
+const mismatchWindow = time.Minute * 2
+
+// RouteMismatch describes a vehicle that is running a route other than the one it is scheduled to run.
+type RouteMismatch struct {
+	Vehicle          *shuttletracker.Vehicle `json:"vehicle"`
+	ScheduledRouteID int64                   `json:"scheduled_route_id"`
+
+	// GuessedRouteID is the route guessed for the vehicle's latest Location.
+	GuessedRouteID int64 `json:"guessed_route_id"`
+
+	// Since is the time of the earliest Location in the run of Locations that weren't guessed to be on
+	// the scheduled route. Runs are looked for at most twice mismatchWindow back.
+	Since time.Time `json:"since"`
+}
+
+type mismatchesByVehicleID []RouteMismatch
+
+func (m mismatchesByVehicleID) Len() int           { return len(m) }
+func (m mismatchesByVehicleID) Less(i, j int) bool { return m[i].Vehicle.ID < m[j].Vehicle.ID }
+func (m mismatchesByVehicleID) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+
+// RouteMismatches returns the vehicles in the freshness window whose Locations have been guessed to be
+// on a route other than their scheduled route for at least two minutes, ordered by vehicle ID. Vehicles
+// without a scheduled route are ignored, as are those whose latest Location isn't on any route, since
+// they may simply be off duty.
+func (u *Updater) RouteMismatches() ([]RouteMismatch, error) {
+	scheduled, err := u.ms.ScheduledRoutes()
+	if err != nil {
+		return nil, err
+	}
+	current, err := u.currentVehicles()
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := []RouteMismatch{}
+	for _, v := range current {
+		scheduledID, ok := scheduled[v.Vehicle.ID]
+		latest := v.Location
+		if !ok || latest.RouteID == nil || *latest.RouteID == scheduledID {
+			continue
+		}
+
+		// walk back from the latest Location until one is on the scheduled route or on none
+		locations, err := u.ms.LocationsSince(v.Vehicle.ID, latest.Time.Add(-mismatchWindow*2))
+		if err != nil {
+			return nil, err
+		}
+		since := latest.Time
+		for _, location := range locations {
+			if location.RouteID == nil || *location.RouteID == scheduledID {
+				break
+			}
+			if location.Time.Before(since) {
+				since = location.Time
+			}
+		}
+		if latest.Time.Sub(since) < mismatchWindow {
+			continue
+		}
+		mismatches = append(mismatches, RouteMismatch{
+			Vehicle:          v.Vehicle,
+			ScheduledRouteID: scheduledID,
+			GuessedRouteID:   *latest.RouteID,
+			Since:            since,
+		})
+	}
+	sort.Sort(mismatchesByVehicleID(mismatches))
+	return mismatches, nil
+}
@@ -0,0 +1,64 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/wtg/shuttletracker"
+	stmock "github.com/wtg/shuttletracker/mock"
+)
+
+func TestRouteMismatches(t *testing.T) {
+	west, east := int64(1), int64(2)
+	now := time.Now()
+	vehicles := []*shuttletracker.Vehicle{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}}
+	// newest first, a Location every 30 seconds
+	history := func(routeIDs ...*int64) []*shuttletracker.Location {
+		locations := []*shuttletracker.Location{}
+		for i, routeID := range routeIDs {
+			locations = append(locations, &shuttletracker.Location{Time: now.Add(-time.Second * 30 * time.Duration(i)), RouteID: routeID})
+		}
+		return locations
+	}
+	histories := map[int64][]*shuttletracker.Location{
+		// on east for the last three minutes
+		1: history(&east, &east, &east, &east, &east, &east, &east, &west),
+		// just flapped onto east
+		2: history(&east, &west, &east, &west, &west),
+		// on its scheduled route
+		3: history(&west, &west, &west, &west, &west),
+		// not on any route
+		4: history(nil, nil, nil, nil, nil),
+		// has no scheduled route
+		5: history(&east, &east, &east, &east, &east),
+	}
+
+	ms := &stmock.ModelService{}
+	ms.RouteService.On("ScheduledRoutes").Return(map[int64]int64{1: west, 2: west, 3: west, 4: west}, nil)
+	ms.VehicleService.On("EnabledVehicles").Return(vehicles, nil)
+	for id, locations := range histories {
+		ms.LocationService.On("LatestLocation", id).Return(locations[0], nil)
+		ms.LocationService.On("LocationsSince", id, mock.Anything).Return(locations, nil)
+	}
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	mismatches, err := u.RouteMismatches()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(mismatches) != 1 {
+		t.Fatalf("got %d mismatches, expected 1: %+v", len(mismatches), mismatches)
+	}
+	mismatch := mismatches[0]
+	if mismatch.Vehicle.ID != 1 || mismatch.ScheduledRouteID != west || mismatch.GuessedRouteID != east {
+		t.Errorf("got %+v, expected vehicle 1 on east instead of west", mismatch)
+	}
+	if !mismatch.Since.Equal(now.Add(-time.Minute * 3)) {
+		t.Errorf("got since %v, expected %v", mismatch.Since, now.Add(-time.Minute*3))
+	}
+}
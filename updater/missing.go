@@ -0,0 +1,70 @@
+package updater
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/log"
+)
+
+// MissingVehicle is an enabled Vehicle that has been absent from the data feed.
+type MissingVehicle struct {
+	Vehicle *shuttletracker.Vehicle `json:"vehicle"`
+
+	// MissedCycles is the number of consecutive updates that the Vehicle did not appear in.
+	MissedCycles int `json:"missed_cycles"`
+}
+
+// trackMissingVehicles updates the consecutive miss count of every enabled vehicle given the
+// tracker IDs that appeared in the latest data feed. A vehicle is present if any of its trackers is.
+func (u *Updater) trackMissingVehicles(seen map[string]bool) {
+	// vehicles outside of their schedule aren't expected to report
+	vehicles, err := u.EnabledVehiclesAt(time.Now())
+	if err != nil {
+		log.WithError(err).Error("unable to get enabled vehicles")
+		return
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	missing := map[int64]*MissingVehicle{}
+	for _, vehicle := range vehicles {
+		present := false
+		for _, trackerID := range append([]string{vehicle.TrackerID}, vehicle.TrackerIDs...) {
+			if seen[trackerID] {
+				present = true
+				break
+			}
+		}
+		if present {
+			continue
+		}
+
+		mv := &MissingVehicle{Vehicle: vehicle, MissedCycles: 1}
+		if previous, ok := u.missingVehicles[vehicle.ID]; ok {
+			mv.MissedCycles = previous.MissedCycles + 1
+		}
+		missing[vehicle.ID] = mv
+	}
+	u.missingVehicles = missing
+}
+
+// MissingVehicles returns the enabled Vehicles that were absent from the most recent data feed,
+// along with how many consecutive updates they have been missing from, ordered by Vehicle ID.
+func (u *Updater) MissingVehicles() []MissingVehicle {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	missing := make([]MissingVehicle, 0, len(u.missingVehicles))
+	for _, mv := range u.missingVehicles {
+		missing = append(missing, *mv)
+	}
+	sort.Sort(byVehicleID(missing))
+	return missing
+}
+
+type byVehicleID []MissingVehicle
+
+func (m byVehicleID) Len() int           { return len(m) }
+func (m byVehicleID) Less(i, j int) bool { return m[i].Vehicle.ID < m[j].Vehicle.ID }
+func (m byVehicleID) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
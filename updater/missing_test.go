@@ -0,0 +1,40 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestMissingVehicles(t *testing.T) {
+	ms := &mock.ModelService{}
+	vehicles := []*shuttletracker.Vehicle{
+		{ID: 1, Enabled: true, TrackerID: "1", TrackerIDs: []string{"1"}},
+		{ID: 2, Enabled: true, TrackerID: "2", TrackerIDs: []string{"2", "2b"}},
+	}
+	ms.VehicleService.On("EnabledVehicles").Return(vehicles, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	u.trackMissingVehicles(map[string]bool{"1": true})
+	u.trackMissingVehicles(map[string]bool{"1": true})
+	missing := u.MissingVehicles()
+	if len(missing) != 1 || missing[0].Vehicle.ID != 2 || missing[0].MissedCycles != 2 {
+		t.Errorf("got %+v, expected vehicle 2 missing for 2 cycles", missing)
+	}
+
+	// a backup tracker counts as the vehicle reappearing
+	u.trackMissingVehicles(map[string]bool{"2b": true})
+	missing = u.MissingVehicles()
+	if len(missing) != 1 || missing[0].Vehicle.ID != 1 || missing[0].MissedCycles != 1 {
+		t.Errorf("got %+v, expected vehicle 1 missing for 1 cycle", missing)
+	}
+
+	u.trackMissingVehicles(map[string]bool{"1": true, "2": true})
+	if missing = u.MissingVehicles(); len(missing) != 0 {
+		t.Errorf("got %+v, expected no missing vehicles", missing)
+	}
+}
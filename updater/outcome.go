@@ -0,0 +1,132 @@
+package updater
+
+import (
+	"strings"
+
+	"github.com/wtg/shuttletracker/log"
+)
+
+// recordDelimiter ends each record in an iTRAK data feed response body.
+const recordDelimiter = "eof"
+
+// FeedOutcome classifies the body of a successful data feed response.
+type FeedOutcome string
+
+// FeedOutcome values.
+const (
+	// FeedEmpty is a body without any records, e.g. during a maintenance window or when no vehicles
+	// are running.
+	FeedEmpty FeedOutcome = "empty"
+
+	// FeedRecords is a body containing at least one record.
+	FeedRecords FeedOutcome = "records"
+
+	// FeedMalformed is a body with content but no records, which usually means that the feed is
+	// down or has changed format.
+	FeedMalformed FeedOutcome = "malformed"
+)
+
+// splitRecords splits a data feed response body into its records. iTRAK ends each record with
+// recordDelimiter, and anything after the last delimiter is not a complete record. Bodies without the
+// delimiter have a record per line instead, and lines without a field the parser knows aren't records.
+// Content that isn't part of any record is returned as ignored.
+func (u *Updater) splitRecords(body []byte) (records []string, ignored string) {
+	if strings.Contains(string(body), recordDelimiter) {
+		parts := strings.Split(string(body), recordDelimiter)
+		return parts[:len(parts)-1], strings.TrimSpace(parts[len(parts)-1])
+	}
+
+	records = []string{}
+	skipped := []string{}
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case u.hasFields(line):
+			records = append(records, line)
+		default:
+			skipped = append(skipped, line)
+		}
+	}
+	return records, strings.Join(skipped, "\n")
+}
+
+// classifyFeed splits a data feed response body into its records and classifies it.
+func (u *Updater) classifyFeed(body []byte) ([]string, FeedOutcome) {
+	records, ignored := u.splitRecords(body)
+	records, ignored = u.dropPartialRecord(body, records, ignored)
+	nonEmpty := 0
+	for _, record := range records {
+		if strings.TrimSpace(record) != "" {
+			nonEmpty++
+		}
+	}
+
+	switch {
+	case nonEmpty > 0:
+		if ignored != "" {
+			log.Warnf("Ignoring %d bytes of the data feed that aren't part of a record.", len(ignored))
+		}
+		return records, FeedRecords
+	case ignored == "":
+		return records, FeedEmpty
+	default:
+		return records, FeedMalformed
+	}
+}
+
+// dropPartialRecord discards an incomplete record at the end of a data feed response body, which is
+// left when the connection is cut partway through the body, as long as complete records came before
+// it and Config.KeepPartialRecords isn't set. With the delimiter, this is ignored content that has
+// fields the parser knows. Without it, this is a final line that is missing a required field, with or
+// without a line break after it; a line cut within its last value can't be told apart from a complete
+// one.
+func (u *Updater) dropPartialRecord(body []byte, records []string, ignored string) ([]string, string) {
+	if u.config().KeepPartialRecords {
+		return records, ignored
+	}
+
+	if strings.Contains(string(body), recordDelimiter) {
+		if ignored == "" || !u.hasFields(ignored) {
+			return records, ignored
+		}
+		for _, record := range records {
+			if strings.TrimSpace(record) != "" {
+				log.Debugf("Discarding incomplete record at the end of the data feed: %q", ignored)
+				return records, ""
+			}
+		}
+		return records, ignored
+	}
+
+	if len(records) < 2 {
+		return records, ignored
+	}
+	last := records[len(records)-1]
+	if !strings.HasSuffix(strings.TrimSpace(string(body)), last) {
+		return records, ignored
+	}
+	if _, err := u.parseFields(last); err == nil {
+		return records, ignored
+	}
+	log.Debugf("Discarding incomplete record at the end of the data feed: %q", last)
+	return records[:len(records)-1], ignored
+}
+
+func (u *Updater) countFeedOutcome(outcome FeedOutcome) {
+	u.mutex.Lock()
+	u.feedOutcomes[outcome]++
+	u.mutex.Unlock()
+}
+
+// FeedOutcomes returns how many data feed responses have had each FeedOutcome since the Updater was
+// created.
+func (u *Updater) FeedOutcomes() map[FeedOutcome]int {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	counts := map[FeedOutcome]int{}
+	for outcome, count := range u.feedOutcomes {
+		counts[outcome] = count
+	}
+	return counts
+}
@@ -0,0 +1,80 @@
+package updater
+
+import (
+	"testing"
+)
+
+func TestClassifyFeed(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	record := "Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:52957 date:04162018"
+	cases := []struct {
+		body    string
+		records int
+		outcome FeedOutcome
+	}{
+		{"", 0, FeedEmpty},
+		{" \r\n", 0, FeedEmpty},
+		{"eof\n", 1, FeedEmpty},
+		{record + "eof", 1, FeedRecords},
+		{record + "eof" + record + "eof\n", 2, FeedRecords},
+		// a truncated final record is dropped but the rest are kept
+		{record + "eof" + record[:20], 1, FeedRecords},
+		{"<html>Service Unavailable</html>", 0, FeedMalformed},
+		// feeds without the delimiter have a record per line
+		{record, 1, FeedRecords},
+		{record + "\n" + record + "\n", 2, FeedRecords},
+		{record + "\r\n\r\n" + record, 2, FeedRecords},
+		{"Vehicles:\n" + record + "\n", 1, FeedRecords},
+		// and a truncated final line is dropped if complete records came before it
+		{record + "\n" + record[:40], 1, FeedRecords},
+		{record + "\n" + record[:40] + "\n", 1, FeedRecords},
+		{record + "\n" + record[:40] + " \r\n\n", 1, FeedRecords},
+		{record[:40], 1, FeedRecords},
+	}
+	for _, c := range cases {
+		records, outcome := u.classifyFeed([]byte(c.body))
+		if len(records) != c.records || outcome != c.outcome {
+			t.Errorf("got %d records and outcome %s for %q, expected %d and %s", len(records), outcome, c.body, c.records, c.outcome)
+		}
+	}
+}
+
+func TestClassifyFeedKeepPartialRecords(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s", KeepPartialRecords: true}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	record := "Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:52957 date:04162018"
+	body := record + "\n" + record[:40] + "\n"
+	records, outcome := u.classifyFeed([]byte(body))
+	if len(records) != 2 || outcome != FeedRecords {
+		t.Errorf("got %d records and outcome %s for %q, expected the truncated record to be kept", len(records), outcome, body)
+	}
+	records, ignored := u.dropPartialRecord([]byte(record+"eof"+record[:40]), []string{record}, record[:40])
+	if len(records) != 1 || ignored != record[:40] {
+		t.Errorf("got %d records and ignored %q, expected the truncated record to be left ignored", len(records), ignored)
+	}
+}
+
+func TestFeedOutcomes(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	u.countFeedOutcome(FeedEmpty)
+	u.countFeedOutcome(FeedEmpty)
+	u.countFeedOutcome(FeedMalformed)
+
+	counts := u.FeedOutcomes()
+	if counts[FeedEmpty] != 2 || counts[FeedMalformed] != 1 || counts[FeedRecords] != 0 {
+		t.Errorf("got counts %v", counts)
+	}
+	// the returned map is a copy
+	counts[FeedRecords] = 5
+	if u.FeedOutcomes()[FeedRecords] != 0 {
+		t.Error("modifying the returned counts changed the Updater's counts")
+	}
+}
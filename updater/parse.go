@@ -0,0 +1,292 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// fieldPattern matches a single key:value token in a data feed record, e.g. "lat:42.72943", where %s
+// is replaced by alternatives for configured keys that contain more than letters. Tokens are matched
+// individually so that records may list their fields in any order. Values may use a leading sign and
+// scientific notation, e.g. "lat:+4.27e1"; anything else that slips through the character class, such
+// as "4.2.7" or "e-", fails to parse as a number and is rejected.
+const fieldPattern = `(%s[A-Za-z]+):([\d\.eE+-]+)`
+
+// lettersRegexp matches keys that the generic part of fieldPattern already covers.
+var lettersRegexp = regexp.MustCompile(`^[A-Za-z]+$`)
+
+// FieldName maps a key used in data feed records to the name of a field that the parser reads.
+type FieldName struct {
+	Key   string
+	Field string
+}
+
+// itrakFieldNames maps the keys used in iTRAK data feed records to the field names used by the parser.
+// It is used unless Config.FieldNames is set.
+var itrakFieldNames = []FieldName{
+	{Key: "Vehicle ID", Field: "id"},
+	{Key: "lat", Field: "lat"},
+	{Key: "lon", Field: "lng"},
+	{Key: "dir", Field: "heading"},
+	{Key: "spd", Field: "speed"},
+	{Key: "lck", Field: "lock"},
+	{Key: "time", Field: "time"},
+	{Key: "date", Field: "date"},
+	{Key: "trig", Field: "status"},
+}
+
+// requiredFields must be present in a record for it to be stored.
+var requiredFields = []string{"id", "lat", "lng", "time", "date"}
+
+// fieldNames returns the mapping from record keys to field names that cfg configures.
+func fieldNames(cfg Config) []FieldName {
+	if len(cfg.FieldNames) == 0 {
+		return itrakFieldNames
+	}
+	return cfg.FieldNames
+}
+
+// fieldMap returns names keyed by record key.
+func fieldMap(names []FieldName) map[string]string {
+	m := make(map[string]string, len(names))
+	for _, name := range names {
+		m[name.Key] = name.Field
+	}
+	return m
+}
+
+type byLength []string
+
+func (s byLength) Len() int           { return len(s) }
+func (s byLength) Less(i, j int) bool { return len(s[i]) > len(s[j]) }
+func (s byLength) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// compileFieldRegexp validates a mapping from record keys to field names and compiles the expression
+// that extracts its fields from records. Every field name must be one the parser knows, every key may
+// appear only once, and every required field must have a key.
+func compileFieldRegexp(names []FieldName) (*regexp.Regexp, error) {
+	known := map[string]bool{}
+	for _, name := range itrakFieldNames {
+		known[name.Field] = true
+	}
+	keys := map[string]bool{}
+	mapped := map[string]bool{}
+	alternatives := []string{}
+	for _, fieldName := range names {
+		key, name := fieldName.Key, fieldName.Field
+		if key == "" || strings.Contains(key, ":") {
+			return nil, fmt.Errorf("invalid data feed key %q for field %s", key, name)
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("data feed key %q maps to unknown field %q", key, name)
+		}
+		if keys[key] {
+			return nil, fmt.Errorf("data feed key %q is mapped more than once", key)
+		}
+		keys[key] = true
+		mapped[name] = true
+		if !lettersRegexp.MatchString(key) {
+			alternatives = append(alternatives, regexp.QuoteMeta(key))
+		}
+	}
+	for _, name := range requiredFields {
+		if !mapped[name] {
+			return nil, fmt.Errorf("data feed field mapping has no key for required field %q", name)
+		}
+	}
+
+	// try longer keys first so that "Vehicle ID" isn't matched as "ID"
+	sort.Sort(byLength(alternatives))
+	prefix := ""
+	if len(alternatives) > 0 {
+		prefix = strings.Join(alternatives, "|") + "|"
+	}
+	return regexp.Compile(fmt.Sprintf(fieldPattern, prefix))
+}
+
+// maxRecordLength is the longest record that is included in full in a ParseError's message.
+const maxRecordLength = 200
+
+// ParseError describes a data feed record that could not be parsed.
+type ParseError struct {
+	// Record is the raw record that failed to parse.
+	Record string
+
+	// Field is the name of the field that failed, e.g. "lat".
+	Field string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	record := strings.TrimSpace(e.Record)
+	if len(record) > maxRecordLength {
+		// cut at the start of a rune so that a multibyte character isn't split
+		end := maxRecordLength
+		for end > 0 && !utf8.RuneStart(record[end]) {
+			end--
+		}
+		record = record[:end] + "..."
+	}
+	return fmt.Sprintf("unable to parse %s in record \"%s\": %s", e.Field, record, e.Err)
+}
+
+// minRecordTime is the earliest time that a record may have. Earlier times, e.g. in 1970, come from
+// trackers whose clocks have reset.
+var minRecordTime = time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// maxRecordFuture is how far ahead of the server's clock a record's time may be.
+const maxRecordFuture = time.Hour * 24
+
+// errMissingField indicates that a required field does not appear in a record.
+var errMissingField = errors.New("missing required field")
+
+// hasFields returns whether s contains a key:value token for any known field.
+func (u *Updater) hasFields(s string) bool {
+	u.mutex.Lock()
+	fieldRegexp := u.fieldRegexp
+	names := u.fieldNames
+	u.mutex.Unlock()
+
+	for _, match := range fieldRegexp.FindAllStringSubmatch(s, -1) {
+		if _, ok := names[match[1]]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFields extracts the known fields from a data feed record regardless of their order.
+// Keys that aren't known are ignored, and if a key appears more than once the first value wins.
+// A *ParseError is returned if any required field is missing.
+func (u *Updater) parseFields(record string) (map[string]string, error) {
+	u.mutex.Lock()
+	fieldRegexp := u.fieldRegexp
+	names := u.fieldNames
+	u.mutex.Unlock()
+
+	fields := map[string]string{}
+	for _, match := range fieldRegexp.FindAllStringSubmatch(record, -1) {
+		name, ok := names[match[1]]
+		if !ok {
+			continue
+		}
+		if _, ok := fields[name]; ok {
+			continue
+		}
+		fields[name] = match[2]
+	}
+
+	for _, name := range requiredFields {
+		if _, ok := fields[name]; !ok {
+			return fields, &ParseError{Record: record, Field: name, Err: errMissingField}
+		}
+	}
+	return fields, nil
+}
+
+// parseRecord turns a data feed record into an unsaved Location. The record's tracker ID is returned
+// whenever its fields could be extracted, even if their values could not be parsed. Errors are
+// always of type *ParseError.
+func (u *Updater) parseRecord(record string) (trackerID string, location *shuttletracker.Location, err error) {
+	result, err := u.parseFields(record)
+	if err != nil {
+		return "", nil, err
+	}
+	trackerID = result["id"]
+
+	latitude, err := strconv.ParseFloat(result["lat"], 64)
+	if err != nil {
+		return trackerID, nil, &ParseError{Record: record, Field: "lat", Err: err}
+	}
+	longitude, err := strconv.ParseFloat(result["lng"], 64)
+	if err != nil {
+		return trackerID, nil, &ParseError{Record: record, Field: "lng", Err: err}
+	}
+	// some trackers report -0 at the equator or prime meridian, which should be stored as 0
+	if latitude == 0 {
+		latitude = 0
+	}
+	if longitude == 0 {
+		longitude = 0
+	}
+	// Round away noise in the low digits before anything compares positions.
+	precision := u.config().CoordinatePrecision
+	latitude = roundCoordinate(latitude, precision)
+	longitude = roundCoordinate(longitude, precision)
+
+	// heading and speed are optional and default to zero when a record omits them
+	var heading float64
+	if value, ok := result["heading"]; ok {
+		heading, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return trackerID, nil, &ParseError{Record: record, Field: "heading", Err: err}
+		}
+	}
+	// convert KPH to MPH
+	var speedKMH float64
+	if value, ok := result["speed"]; ok {
+		speedKMH, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return trackerID, nil, &ParseError{Record: record, Field: "speed", Err: err}
+		}
+	}
+
+	u.mutex.Lock()
+	feedLocation := u.feedLocation
+	u.mutex.Unlock()
+	newTime, err := itrakTimeDateIn("time:"+result["time"], "date:"+result["date"], feedLocation)
+	if err != nil {
+		return trackerID, nil, &ParseError{Record: record, Field: "time", Err: err}
+	}
+	// a nonsense time from a reset clock would otherwise sort wrong in every vehicle's history
+	if newTime.Before(minRecordTime) || newTime.After(time.Now().Add(maxRecordFuture)) {
+		err = fmt.Errorf("%s is before %s or more than %s in the future", newTime, minRecordTime.Format("2006-01-02"), maxRecordFuture)
+		return trackerID, nil, &ParseError{Record: record, Field: "date", Err: err}
+	}
+
+	speed := kphToMPH(speedKMH)
+	location = &shuttletracker.Location{
+		TrackerID:     trackerID,
+		Latitude:      latitude,
+		Longitude:     longitude,
+		Heading:       heading,
+		Speed:         speed,
+		Time:          newTime,
+		ReportedSpeed: &speed,
+	}
+	return trackerID, location, nil
+}
+
+// FieldPattern returns the regular expression currently used to extract fields from data feed records.
+func (u *Updater) FieldPattern() string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.fieldRegexp.String()
+}
+
+// DebugParse parses a single data feed line as the Updater would and returns the fields extracted from
+// it by name, e.g. "lat". Fields are returned even if the line can't be stored, along with a *ParseError
+// describing the first field that is missing or has an invalid value. A line with several records only
+// has its first parsed. Nothing is stored.
+func (u *Updater) DebugParse(line string) (map[string]string, error) {
+	records, _ := u.splitRecords([]byte(line))
+	if len(records) > 0 {
+		line = records[0]
+	}
+	fields, err := u.parseFields(line)
+	if err != nil {
+		return fields, err
+	}
+	_, _, err = u.parseRecord(line)
+	return fields, err
+}
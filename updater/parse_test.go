@@ -0,0 +1,308 @@
+package updater
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseFields(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	type testCase struct {
+		record   string
+		expected map[string]string
+	}
+	cases := []testCase{
+		{
+			record: "Vehicle ID:1234 lat:42.72943 lon:-73.67543 dir:92 spd:12 lck:1 time:52957 date:04162018 trig:0",
+			expected: map[string]string{
+				"id": "1234", "lat": "42.72943", "lng": "-73.67543", "heading": "92", "speed": "12",
+				"lock": "1", "time": "52957", "date": "04162018", "status": "0",
+			},
+		},
+		// reordered fields with an unknown extra field
+		{
+			record: "lon:-73.67543 Vehicle ID:1234 temp:85.2 time:52957 lat:42.72943 date:04162018 spd:12 dir:92",
+			expected: map[string]string{
+				"id": "1234", "lat": "42.72943", "lng": "-73.67543", "heading": "92", "speed": "12",
+				"time": "52957", "date": "04162018",
+			},
+		},
+		// optional fields missing
+		{
+			record: "Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:52957 date:04162018",
+			expected: map[string]string{
+				"id": "1234", "lat": "42.72943", "lng": "-73.67543", "time": "52957", "date": "04162018",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		fields, err := u.parseFields(c.record)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", c.record, err)
+			continue
+		}
+		if len(fields) != len(c.expected) {
+			t.Errorf("got %d fields for %q, expected %d", len(fields), c.record, len(c.expected))
+		}
+		for name, value := range c.expected {
+			if fields[name] != value {
+				t.Errorf("got %s=%q for %q, expected %q", name, fields[name], c.record, value)
+			}
+		}
+	}
+}
+
+func TestParseFieldsMissingRequired(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	records := []string{
+		"lat:42.72943 lon:-73.67543 time:52957 date:04162018",
+		"Vehicle ID:1234 lon:-73.67543 time:52957 date:04162018",
+		"Vehicle ID:1234 lat:42.72943 lon:-73.67543 date:04162018",
+		"",
+	}
+	for _, record := range records {
+		_, err := u.parseFields(record)
+		if err == nil {
+			t.Errorf("expected error for %q", record)
+		}
+	}
+}
+
+func TestParseRecordError(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	record := "Vehicle ID:1234 lat:42.7.2943 lon:-73.67543 time:52957 date:04162018"
+	trackerID, _, err := u.parseRecord(record)
+	if trackerID != "1234" {
+		t.Errorf("got tracker ID %s, expected 1234", trackerID)
+	}
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("got error %v, expected a *ParseError", err)
+	}
+	if parseErr.Field != "lat" || parseErr.Record != record {
+		t.Errorf("got field %s and record %q", parseErr.Field, parseErr.Record)
+	}
+	if !strings.Contains(parseErr.Error(), record) {
+		t.Errorf("error message %q does not include the record", parseErr.Error())
+	}
+
+	long := record + strings.Repeat(" foo:1", 100)
+	parseErr = &ParseError{Record: long, Field: "lat", Err: errMissingField}
+	if len(parseErr.Error()) > maxRecordLength+100 {
+		t.Errorf("error message is %d characters long, expected the record to be truncated", len(parseErr.Error()))
+	}
+
+	// a multibyte character straddling the limit is dropped rather than split
+	multibyte := strings.Repeat("a", maxRecordLength-1) + "é" + long
+	parseErr = &ParseError{Record: multibyte, Field: "lat", Err: errMissingField}
+	if !utf8.ValidString(parseErr.Error()) || strings.Contains(parseErr.Error(), "é") {
+		t.Errorf("error message %q splits or keeps the multibyte character at the limit", parseErr.Error())
+	}
+}
+
+func TestParseRecordNumberFormats(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	type testCase struct {
+		lat, lng       string
+		expLat, expLng float64
+	}
+	cases := []testCase{
+		{lat: "4.272943e1", lng: "-7.367543E+1", expLat: 42.72943, expLng: -73.67543},
+		{lat: "+42.72943", lng: "-73.67543", expLat: 42.72943, expLng: -73.67543},
+		{lat: "+4272943e-5", lng: "-.7367543e2", expLat: 42.72943, expLng: -73.67543},
+		{lat: "-0", lng: "-0.0", expLat: 0, expLng: 0},
+	}
+	for _, c := range cases {
+		record := "Vehicle ID:1234 lat:" + c.lat + " lon:" + c.lng + " time:52957 date:04162018"
+		_, location, err := u.parseRecord(record)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", record, err)
+			continue
+		}
+		if math.Abs(location.Latitude-c.expLat) > 1e-9 || math.Abs(location.Longitude-c.expLng) > 1e-9 {
+			t.Errorf("got %f, %f for %q, expected %f, %f", location.Latitude, location.Longitude, record, c.expLat, c.expLng)
+		}
+		if math.Signbit(location.Latitude) && location.Latitude == 0 {
+			t.Errorf("got negative zero latitude for %q", record)
+		}
+		if math.Signbit(location.Longitude) && location.Longitude == 0 {
+			t.Errorf("got negative zero longitude for %q", record)
+		}
+	}
+
+	// garbage that uses only number characters is still rejected
+	for _, lat := range []string{"e", "+-42", "4.2.7", "42e", "1e400"} {
+		record := "Vehicle ID:1234 lat:" + lat + " lon:-73.67543 time:52957 date:04162018"
+		_, _, err := u.parseRecord(record)
+		parseErr, ok := err.(*ParseError)
+		if !ok || parseErr.Field != "lat" {
+			t.Errorf("got error %v for %q, expected a *ParseError for lat", err, record)
+		}
+	}
+}
+
+func TestParseFieldsCustomNames(t *testing.T) {
+	names := []FieldName{
+		{Key: "Unit ID", Field: "id"},
+		{Key: "ID", Field: "status"},
+		{Key: "lat", Field: "lat"},
+		{Key: "lng", Field: "lng"},
+		{Key: "hdg", Field: "heading"},
+		{Key: "speed", Field: "speed"},
+		{Key: "time", Field: "time"},
+		{Key: "date", Field: "date"},
+	}
+	u, err := New(Config{UpdateInterval: "10s", FieldNames: names}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fields, err := u.parseFields("Unit ID:1234 lat:42.72943 lng:-73.67543 hdg:92 speed:12 spd:99 ID:3 time:52957 date:04162018")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]string{
+		"id": "1234", "lat": "42.72943", "lng": "-73.67543", "heading": "92", "speed": "12",
+		"status": "3", "time": "52957", "date": "04162018",
+	}
+	if len(fields) != len(expected) {
+		t.Errorf("got %d fields, expected %d", len(fields), len(expected))
+	}
+	for name, value := range expected {
+		if fields[name] != value {
+			t.Errorf("got %s=%q, expected %q", name, fields[name], value)
+		}
+	}
+}
+
+func TestFieldNamesValidation(t *testing.T) {
+	missing := []FieldName{{"Vehicle ID", "id"}, {"lat", "lat"}, {"lon", "lng"}, {"time", "time"}}
+	_, err := New(Config{UpdateInterval: "10s", FieldNames: missing}, nil)
+	if err == nil || !strings.Contains(err.Error(), `"date"`) {
+		t.Errorf("got error %v, expected one naming the missing date field", err)
+	}
+
+	unknown := []FieldName{{"Vehicle ID", "id"}, {"lat", "lat"}, {"lon", "lng"}, {"time", "time"}, {"date", "date"}, {"alt", "altitude"}}
+	_, err = New(Config{UpdateInterval: "10s", FieldNames: unknown}, nil)
+	if err == nil {
+		t.Error("expected an error for a key mapped to an unknown field")
+	}
+
+	duplicate := []FieldName{{"Vehicle ID", "id"}, {"lat", "lat"}, {"lon", "lng"}, {"time", "time"}, {"date", "date"}, {"lat", "heading"}}
+	_, err = New(Config{UpdateInterval: "10s", FieldNames: duplicate}, nil)
+	if err == nil {
+		t.Error("expected an error for a key mapped more than once")
+	}
+}
+
+func TestFieldNamesFromConfigFile(t *testing.T) {
+	v := viper.New()
+	cfg := NewConfig(v)
+	v.SetConfigType("json")
+	err := v.ReadConfig(strings.NewReader(`{"updater": {"fieldnames": [
+		{"key": "Vehicle ID", "field": "id"},
+		{"key": "Lat", "field": "lat"},
+		{"key": "Lon", "field": "lng"},
+		{"key": "time", "field": "time"},
+		{"key": "date", "field": "date"}
+	]}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := v.UnmarshalKey("updater", cfg); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	u, err := New(*cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fields, err := u.parseFields("Vehicle ID:1234 Lat:42.72943 Lon:-73.67543 time:52957 date:04162018")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fields["id"] != "1234" || fields["lat"] != "42.72943" || fields["lng"] != "-73.67543" {
+		t.Errorf("got fields %v, expected id, lat, and lng from the case-sensitive keys", fields)
+	}
+}
+
+func TestParseRecordImpossibleDate(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	future := time.Now().Add(time.Hour * 48).UTC().Format("01022006")
+	for _, date := range []string{"01011970", "12312014", future, "01012099"} {
+		record := "Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:52957 date:" + date
+		trackerID, _, err := u.parseRecord(record)
+		parseErr, ok := err.(*ParseError)
+		if !ok || parseErr.Field != "date" {
+			t.Errorf("got error %v for %q, expected a *ParseError for date", err, record)
+		}
+		if trackerID != "1234" {
+			t.Errorf("got tracker ID %s, expected 1234", trackerID)
+		}
+	}
+
+	// a clock slightly ahead of the server's is allowed
+	today := time.Now().UTC().Format("01022006")
+	_, _, err = u.parseRecord("Vehicle ID:1234 lat:42.72943 lon:-73.67543 time:235959 date:" + today)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestDebugParse(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(u.FieldPattern(), "Vehicle ID") {
+		t.Errorf("got pattern %q, expected it to include the Vehicle ID key", u.FieldPattern())
+	}
+
+	fields, err := u.DebugParse("Vehicle ID:1234 lat:42.72943 lon:-73.67543 dir:90 time:52957 date:04162018 eof")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if fields["id"] != "1234" || fields["lng"] != "-73.67543" || fields["heading"] != "90" {
+		t.Errorf("got fields %v", fields)
+	}
+
+	// fields that were found are still returned when another is invalid
+	fields, err = u.DebugParse("Vehicle ID:1234 lat:42.7.2943 lon:-73.67543 time:52957 date:04162018")
+	if parseErr, ok := err.(*ParseError); !ok || parseErr.Field != "lat" {
+		t.Errorf("got error %v, expected a *ParseError for lat", err)
+	}
+	if fields["id"] != "1234" {
+		t.Errorf("got fields %v, expected the tracker ID", fields)
+	}
+
+	_, err = u.DebugParse("<html>Service Unavailable</html>")
+	if err == nil {
+		t.Error("expected an error for a line without fields")
+	}
+}
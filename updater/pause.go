@@ -0,0 +1,52 @@
+package updater
+
+import (
+	"github.com/wtg/shuttletracker/log"
+)
+
+// Pause stops the Updater from fetching the data feed until Resume is called, e.g. during database
+// maintenance. Run keeps going, but its scheduled updates do nothing while paused. An update that is
+// already in progress is allowed to finish.
+func (u *Updater) Pause() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if !u.paused {
+		log.Info("Updater paused.")
+	}
+	u.paused = true
+}
+
+// Resume undoes Pause. Updates start again at the next tick of the update interval.
+func (u *Updater) Resume() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.paused {
+		log.Info("Updater resumed.")
+	}
+	u.paused = false
+}
+
+// Paused returns whether the Updater is paused.
+func (u *Updater) Paused() bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.paused
+}
+
+// updateUnlessPaused calls update if the Updater isn't paused.
+func (u *Updater) updateUnlessPaused() {
+	if u.Paused() {
+		log.Debug("Updater is paused; skipping update.")
+		return
+	}
+	u.update()
+}
+
+// autoDisableUnlessPaused calls autoDisable if the Updater isn't paused. Vehicles stop reporting while
+// nothing is fetched, so they would otherwise be disabled by a long pause.
+func (u *Updater) autoDisableUnlessPaused() {
+	if u.Paused() {
+		return
+	}
+	u.autoDisable()
+}
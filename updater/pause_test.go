@@ -0,0 +1,45 @@
+package updater
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestPause(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	u, err := New(Config{UpdateInterval: "10s", DataFeed: server.URL}, &mock.ModelService{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if u.Paused() {
+		t.Error("expected a new Updater not to be paused")
+	}
+
+	u.Pause()
+	if !u.Paused() {
+		t.Error("expected the Updater to be paused")
+	}
+	u.updateUnlessPaused()
+	u.autoDisableUnlessPaused()
+	if requests != 0 {
+		t.Errorf("got %d data feed requests while paused, expected none", requests)
+	}
+
+	u.Resume()
+	if u.Paused() {
+		t.Error("expected the Updater to be resumed")
+	}
+	u.updateUnlessPaused()
+	if requests != 1 {
+		t.Errorf("got %d data feed requests after resuming, expected 1", requests)
+	}
+}
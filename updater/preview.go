@@ -0,0 +1,78 @@
+package updater
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// ParseIssue describes a data feed record that would not be stored.
+type ParseIssue struct {
+	Record string `json:"record"`
+
+	// TrackerID is empty if the record's fields could not be extracted.
+	TrackerID string `json:"tracker_id"`
+	Message   string `json:"message"`
+}
+
+// PreviewFeed fetches the data feed and returns the Locations that its records parse into, along with
+// an issue for every record that could not be parsed or whose tracker doesn't belong to a Vehicle.
+// Returned Locations have their VehicleID set but no RouteID. Nothing is stored or pruned, and the
+// Updater's last data feed response is left unchanged.
+func (u *Updater) PreviewFeed() ([]*shuttletracker.Location, []ParseIssue, error) {
+	cfg := u.config()
+	u.mutex.Lock()
+	client := u.client
+	u.mutex.Unlock()
+
+	feedURL, err := u.feedURL(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := client.Get(feedURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("data feed status code %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u.previewBody(cfg, body)
+}
+
+// previewBody parses a data feed response body as PreviewFeed does.
+func (u *Updater) previewBody(cfg Config, body []byte) ([]*shuttletracker.Location, []ParseIssue, error) {
+	locations := []*shuttletracker.Location{}
+	issues := []ParseIssue{}
+	records, _ := u.splitRecords(body)
+	for _, record := range records {
+		record = strings.TrimSpace(record)
+		trackerID, location, err := u.parseRecord(record)
+		if err != nil {
+			issues = append(issues, ParseIssue{Record: record, TrackerID: trackerID, Message: err.Error()})
+			continue
+		}
+		if !trackerAllowed(cfg.TrackerAllowlist, trackerID) {
+			issues = append(issues, ParseIssue{Record: record, TrackerID: trackerID, Message: "tracker is not in the allowlist"})
+			continue
+		}
+
+		vehicle, err := u.ms.VehicleWithTrackerID(trackerID)
+		if err == shuttletracker.ErrVehicleNotFound {
+			issues = append(issues, ParseIssue{Record: record, TrackerID: trackerID, Message: "unknown tracker"})
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		location.VehicleID = &vehicle.ID
+		locations = append(locations, location)
+	}
+	return locations, issues, nil
+}
@@ -0,0 +1,52 @@
+package updater
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestPreviewFeed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Vehicle ID:1234 lat:42.72943 lon:-73.67543 dir:92 spd:10 time:52957 date:04162018eof"+
+			"Vehicle ID:5678 lat:42.72943 lon:-73.67543 time:52957 date:04162018eof"+
+			"Vehicle ID:1234 lon:-73.67543 time:52957 date:04162018eof")
+	}))
+	defer server.Close()
+
+	vehicle := &shuttletracker.Vehicle{ID: 1, TrackerID: "1234"}
+	ms := &mock.ModelService{}
+	ms.VehicleService.On("VehicleWithTrackerID", "1234").Return(vehicle, nil)
+	ms.VehicleService.On("VehicleWithTrackerID", "5678").Return(&shuttletracker.Vehicle{}, shuttletracker.ErrVehicleNotFound)
+	u, err := New(Config{UpdateInterval: "10s", DataFeed: server.URL}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	locations, issues, err := u.PreviewFeed()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(locations) != 1 {
+		t.Fatalf("got %d locations, expected 1", len(locations))
+	}
+	if locations[0].VehicleID == nil || *locations[0].VehicleID != 1 || locations[0].TrackerID != "1234" {
+		t.Errorf("got location %+v, expected one for vehicle 1", locations[0])
+	}
+	if len(issues) != 2 {
+		t.Fatalf("got issues %+v, expected 2", issues)
+	}
+	if issues[0].TrackerID != "5678" || issues[1].TrackerID != "" {
+		t.Errorf("got issues %+v", issues)
+	}
+
+	// nothing was stored and the last response wasn't replaced
+	ms.LocationService.AssertNotCalled(t, "CreateLocation")
+	if u.GetLastResponse() != nil {
+		t.Error("previewing the feed replaced the last response")
+	}
+}
@@ -0,0 +1,24 @@
+package updater
+
+import (
+	"math"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+// RouteProgress returns how far a vehicle has traveled along its current route from the route's first
+// point, as a fraction of the route's length from 0 to 1. On loop routes this goes back to 0 each time
+// the vehicle passes the first point. ErrNotOnRoute is returned if the vehicle is not on a route.
+func (u *Updater) RouteProgress(vehicleID int64) (float64, error) {
+	route, location, err := u.currentRoute(vehicleID)
+	if err != nil {
+		return 0, err
+	}
+	length := spatial.Length(route.Points)
+	if length == 0 {
+		return 0, nil
+	}
+	position := spatial.Project(shuttletracker.Point{Latitude: location.Latitude, Longitude: location.Longitude}, route.Points).Along
+	return math.Max(0, math.Min(1, position/length)), nil
+}
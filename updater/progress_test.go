@@ -0,0 +1,58 @@
+package updater
+
+import (
+	"math"
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestRouteProgress(t *testing.T) {
+	// a square loop
+	route := &shuttletracker.Route{
+		ID:      1,
+		Name:    "Loop",
+		Enabled: true,
+		Active:  true,
+		Points: []shuttletracker.Point{
+			{Latitude: 42.730, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.670},
+			{Latitude: 42.740, Longitude: -73.670},
+			{Latitude: 42.740, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.680},
+		},
+	}
+	cases := []struct {
+		position shuttletracker.Point
+		progress float64
+	}{
+		{shuttletracker.Point{Latitude: 42.730, Longitude: -73.680}, 0},
+		{shuttletracker.Point{Latitude: 42.740, Longitude: -73.670}, 0.5},
+		{shuttletracker.Point{Latitude: 42.732, Longitude: -73.680}, 0.95},
+	}
+	for _, c := range cases {
+		u := newRouteTestUpdater(t, route, []*shuttletracker.Stop{}, c.position)
+		progress, err := u.RouteProgress(1)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		// degrees of longitude are shorter than degrees of latitude, so the sides differ in length
+		if math.Abs(progress-c.progress) > 0.02 {
+			t.Errorf("got progress %f at %+v, expected %f", progress, c.position, c.progress)
+		}
+	}
+}
+
+func TestRouteProgressNotOnRoute(t *testing.T) {
+	ms := &mock.ModelService{}
+	ms.LocationService.On("LatestLocation", int64(1)).Return((*shuttletracker.Location)(nil), shuttletracker.ErrLocationNotFound)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = u.RouteProgress(1)
+	if err != ErrNotOnRoute {
+		t.Errorf("got error %v, expected ErrNotOnRoute", err)
+	}
+}
@@ -0,0 +1,78 @@
+package updater
+
+import (
+	"net/http"
+
+	"github.com/wtg/shuttletracker/log"
+)
+
+// config returns a copy of the Updater's current Config.
+func (u *Updater) config() Config {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.cfg
+}
+
+// Reload validates cfg and replaces the Updater's Config with it. The new data feed, proxy, and parser are
+// used starting with the next update, and if the update interval changed, the ticker in Run is restarted.
+// If cfg is invalid, an error is returned and the current Config is kept.
+func (u *Updater) Reload(cfg Config) error {
+	parsed, err := parseConfig(cfg)
+	if err != nil {
+		return err
+	}
+	client, err := newFeedClient(cfg)
+	if err != nil {
+		return err
+	}
+	feedLocation, maxClockSkew, err := parseClockConfig(cfg)
+	if err != nil {
+		return err
+	}
+	campusLocation, err := loadLocation(cfg.CampusTimezone)
+	if err != nil {
+		return err
+	}
+	feedArchive, err := newFeedArchive(cfg)
+	if err != nil {
+		return err
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	changed := parsed.interval != u.updateInterval
+	previousClient := u.client
+	u.cfg = cfg
+	u.updateInterval = parsed.interval
+	u.fieldRegexp = parsed.fieldRegexp
+	u.fieldNames = fieldMap(fieldNames(cfg))
+	u.client = client
+	if u.providedClient != nil {
+		u.client = u.providedClient
+	}
+	// don't leave the replaced client's pooled connections open; a fetch still using it just
+	// opens a new one
+	if previousClient != u.client {
+		if transport, ok := previousClient.Transport.(*http.Transport); ok {
+			transport.CloseIdleConnections()
+		}
+	}
+	u.feedLocation = feedLocation
+	u.maxClockSkew = maxClockSkew
+	u.campusLocation = campusLocation
+	u.tripIdleGap = parsed.tripIdleGap
+	u.locationFreshness = parsed.locationFreshness
+	u.feedArchive = feedArchive
+	u.autoDisableAfter = parsed.autoDisableAfter
+
+	if changed {
+		// Replace any interval change that Run hasn't picked up yet.
+		select {
+		case <-u.intervalChanges:
+		default:
+		}
+		u.intervalChanges <- parsed.interval
+	}
+	log.Info("Updater configuration reloaded.")
+	return nil
+}
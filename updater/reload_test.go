@@ -0,0 +1,93 @@
+package updater
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s", DataFeed: "https://example.com/datafeed"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = u.Reload(Config{UpdateInterval: "not a duration", DataFeed: "https://example.com/other"})
+	if err == nil {
+		t.Error("expected error for invalid interval")
+	}
+	err = u.Reload(Config{UpdateInterval: "0s"})
+	if err == nil {
+		t.Error("expected error for zero interval")
+	}
+	if u.config().DataFeed != "https://example.com/datafeed" {
+		t.Errorf("got data feed %s after invalid reload", u.config().DataFeed)
+	}
+
+	// reloading twice before Run notices should only leave the latest interval pending
+	for _, interval := range []string{"5s", "3s"} {
+		err = u.Reload(Config{UpdateInterval: interval, DataFeed: "https://example.com/other"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if u.config().DataFeed != "https://example.com/other" {
+		t.Errorf("got data feed %s, expected https://example.com/other", u.config().DataFeed)
+	}
+	select {
+	case interval := <-u.intervalChanges:
+		if interval != 3*time.Second {
+			t.Errorf("got interval %s, expected 3s", interval)
+		}
+	default:
+		t.Error("expected pending interval change")
+	}
+
+	// the same interval shouldn't restart the ticker
+	err = u.Reload(Config{UpdateInterval: "3s"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	select {
+	case interval := <-u.intervalChanges:
+		t.Errorf("got unexpected interval change to %s", interval)
+	default:
+	}
+}
+
+func TestReloadClosesIdleConnections(t *testing.T) {
+	closed := make(chan struct{}, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	u, err := New(Config{UpdateInterval: "10s", DataFeed: server.URL}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// leave a connection idle in the current client's pool
+	resp, err := u.client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	err = u.Reload(Config{UpdateInterval: "10s", DataFeed: server.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second * 5):
+		t.Error("expected the replaced client's idle connection to be closed")
+	}
+}
@@ -0,0 +1,30 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// inService returns whether a vehicle's schedule includes t in the campus time zone.
+func (u *Updater) inService(vehicle *shuttletracker.Vehicle, t time.Time) bool {
+	u.mutex.Lock()
+	loc := u.campusLocation
+	u.mutex.Unlock()
+	return vehicle.Schedule.ActiveAt(t.In(loc))
+}
+
+// EnabledVehiclesAt returns the enabled Vehicles whose schedules include t in the campus time zone.
+func (u *Updater) EnabledVehiclesAt(t time.Time) ([]*shuttletracker.Vehicle, error) {
+	vehicles, err := u.ms.EnabledVehicles()
+	if err != nil {
+		return nil, err
+	}
+	scheduled := []*shuttletracker.Vehicle{}
+	for _, vehicle := range vehicles {
+		if u.inService(vehicle, t) {
+			scheduled = append(scheduled, vehicle)
+		}
+	}
+	return scheduled, nil
+}
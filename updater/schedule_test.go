@@ -0,0 +1,77 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestVehicleScheduleActiveAt(t *testing.T) {
+	// weekdays from 7 AM to 11 PM, plus Saturday night into Sunday morning
+	schedule := shuttletracker.VehicleSchedule{
+		{StartDay: time.Monday, StartTime: "07:00", EndDay: time.Monday, EndTime: "23:00"},
+		{StartDay: time.Saturday, StartTime: "22:00", EndDay: time.Sunday, EndTime: "02:00"},
+	}
+
+	type testCase struct {
+		time     time.Time
+		expected bool
+	}
+	// April 16, 2018 was a Monday
+	cases := []testCase{
+		{time.Date(2018, time.April, 16, 6, 59, 0, 0, time.UTC), false},
+		{time.Date(2018, time.April, 16, 7, 0, 0, 0, time.UTC), true},
+		{time.Date(2018, time.April, 16, 22, 59, 0, 0, time.UTC), true},
+		{time.Date(2018, time.April, 16, 23, 0, 0, 0, time.UTC), false},
+		{time.Date(2018, time.April, 21, 23, 30, 0, 0, time.UTC), true},
+		{time.Date(2018, time.April, 22, 1, 30, 0, 0, time.UTC), true},
+		{time.Date(2018, time.April, 22, 2, 30, 0, 0, time.UTC), false},
+	}
+	for _, c := range cases {
+		if active := schedule.ActiveAt(c.time); active != c.expected {
+			t.Errorf("%v: got %t, expected %t", c.time, active, c.expected)
+		}
+	}
+
+	if !(shuttletracker.VehicleSchedule{}).ActiveAt(time.Now()) {
+		t.Error("an empty schedule should always be active")
+	}
+}
+
+func TestEnabledVehiclesAt(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %s", err)
+	}
+	vehicles := []*shuttletracker.Vehicle{
+		{ID: 1, Enabled: true},
+		{ID: 2, Enabled: true, Schedule: shuttletracker.VehicleSchedule{
+			{StartDay: time.Monday, StartTime: "07:00", EndDay: time.Monday, EndTime: "23:00"},
+		}},
+	}
+	ms := &mock.ModelService{}
+	ms.VehicleService.On("EnabledVehicles").Return(vehicles, nil)
+	u, err := New(Config{UpdateInterval: "10s", CampusTimezone: "America/New_York"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// 2 AM UTC on Tuesday is still Monday night on campus
+	scheduled, err := u.EnabledVehiclesAt(time.Date(2018, time.April, 17, 2, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(scheduled) != 2 {
+		t.Errorf("got %d vehicles, expected 2", len(scheduled))
+	}
+
+	scheduled, err = u.EnabledVehiclesAt(time.Date(2018, time.April, 17, 4, 0, 0, 0, loc))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(scheduled) != 1 || scheduled[0].ID != 1 {
+		t.Errorf("got %+v, expected only vehicle 1", scheduled)
+	}
+}
@@ -0,0 +1,85 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// RouteSegment is a period during which a Vehicle's Locations were all on the same route.
+type RouteSegment struct {
+	// RouteID is the route the Vehicle was on, or nil if it was off-route.
+	RouteID *int64    `json:"route_id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+
+	// Duration is End minus Start.
+	Duration time.Duration `json:"duration"`
+}
+
+// RouteSegments collapses a Vehicle's Locations between start and end into consecutive segments on the
+// same route. Each segment starts at its first Location and ends when the next segment starts, and the
+// final segment is open-ended so it extends to end.
+func (u *Updater) RouteSegments(vehicleID int64, start, end time.Time) ([]RouteSegment, error) {
+	locations, err := u.ms.LocationsBetween(vehicleID, start, end, shuttletracker.Collapse{})
+	if err != nil {
+		return nil, err
+	}
+	return routeSegments(locations, end), nil
+}
+
+// VehicleRouteTimeline returns the routes that a Vehicle served during the campus day containing day,
+// in order, e.g. for a daily report. Unlike RouteSegments, time spent off-route is left out and the
+// last segment ends at the Vehicle's last Location of the day rather than at midnight.
+func (u *Updater) VehicleRouteTimeline(vehicleID int64, day time.Time) ([]RouteSegment, error) {
+	u.mutex.Lock()
+	loc := u.campusLocation
+	u.mutex.Unlock()
+
+	local := day.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	locations, err := u.ms.LocationsBetween(vehicleID, start, start.AddDate(0, 0, 1), shuttletracker.Collapse{})
+	if err != nil {
+		return nil, err
+	}
+	timeline := []RouteSegment{}
+	if len(locations) == 0 {
+		return timeline, nil
+	}
+	for _, segment := range routeSegments(locations, locations[len(locations)-1].Time) {
+		if segment.RouteID != nil {
+			timeline = append(timeline, segment)
+		}
+	}
+	return timeline, nil
+}
+
+// routeSegments collapses time-ordered Locations into consecutive segments on the same route, with the
+// final segment ending at end.
+func routeSegments(locations []*shuttletracker.Location, end time.Time) []RouteSegment {
+	segments := []RouteSegment{}
+	for _, location := range locations {
+		if len(segments) > 0 && sameRoute(segments[len(segments)-1].RouteID, location.RouteID) {
+			continue
+		}
+		if len(segments) > 0 {
+			segments[len(segments)-1].End = location.Time
+		}
+		segments = append(segments, RouteSegment{RouteID: location.RouteID, Start: location.Time})
+	}
+	if len(segments) > 0 {
+		segments[len(segments)-1].End = end
+	}
+	for i := range segments {
+		segments[i].Duration = segments[i].End.Sub(segments[i].Start)
+	}
+	return segments
+}
+
+// sameRoute returns whether two possibly nil route IDs refer to the same route.
+func sameRoute(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,108 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestRouteSegments(t *testing.T) {
+	west := int64(1)
+	east := int64(2)
+	start := time.Date(2018, time.April, 16, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+	at := func(hour, minute int) time.Time {
+		return start.Add(time.Hour*time.Duration(hour) + time.Minute*time.Duration(minute))
+	}
+	locations := []*shuttletracker.Location{
+		{RouteID: &west, Time: at(6, 0)},
+		{RouteID: &west, Time: at(7, 30)},
+		{Time: at(9, 15)},
+		{Time: at(9, 17)},
+		{RouteID: &east, Time: at(9, 20)},
+		{RouteID: &east, Time: at(12, 0)},
+	}
+	ms := &mock.ModelService{}
+	ms.LocationService.On("LocationsBetween", int64(1), start, end, shuttletracker.Collapse{}).Return(locations, nil)
+	ms.LocationService.On("LocationsBetween", int64(2), start, end, shuttletracker.Collapse{}).Return([]*shuttletracker.Location{}, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	segments, err := u.RouteSegments(1, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []RouteSegment{
+		{RouteID: &west, Start: at(6, 0), End: at(9, 15)},
+		{Start: at(9, 15), End: at(9, 20)},
+		{RouteID: &east, Start: at(9, 20), End: end},
+	}
+	if len(segments) != len(expected) {
+		t.Fatalf("got %d segments, expected %d", len(segments), len(expected))
+	}
+	for i, segment := range segments {
+		e := expected[i]
+		if !sameRoute(segment.RouteID, e.RouteID) || !segment.Start.Equal(e.Start) || !segment.End.Equal(e.End) {
+			t.Errorf("got segment %d %+v, expected %+v", i, segment, e)
+		}
+	}
+
+	segments, err = u.RouteSegments(2, start, end)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(segments) != 0 {
+		t.Errorf("got %d segments for a vehicle without locations", len(segments))
+	}
+}
+
+func TestVehicleRouteTimeline(t *testing.T) {
+	west := int64(1)
+	east := int64(2)
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("unable to load time zone: %s", err)
+	}
+	start := time.Date(2018, time.April, 16, 0, 0, 0, 0, loc)
+	at := func(hour, minute int) time.Time {
+		return start.Add(time.Hour*time.Duration(hour) + time.Minute*time.Duration(minute))
+	}
+	locations := []*shuttletracker.Location{
+		{RouteID: &west, Time: at(6, 0)},
+		{RouteID: &west, Time: at(8, 30)},
+		{RouteID: &east, Time: at(9, 0)},
+		{Time: at(12, 0)},
+		{RouteID: &west, Time: at(12, 10)},
+		{RouteID: &west, Time: at(15, 0)},
+	}
+	ms := &mock.ModelService{}
+	ms.LocationService.On("LocationsBetween", int64(1), start, start.AddDate(0, 0, 1), shuttletracker.Collapse{}).Return(locations, nil)
+	u, err := New(Config{UpdateInterval: "10s", CampusTimezone: "America/New_York"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// a time late in the campus day is already the next day in UTC
+	timeline, err := u.VehicleRouteTimeline(1, at(23, 0).UTC())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []RouteSegment{
+		{RouteID: &west, Start: at(6, 0), End: at(9, 0), Duration: time.Hour * 3},
+		{RouteID: &east, Start: at(9, 0), End: at(12, 0), Duration: time.Hour * 3},
+		{RouteID: &west, Start: at(12, 10), End: at(15, 0), Duration: time.Hour*2 + time.Minute*50},
+	}
+	if len(timeline) != len(expected) {
+		t.Fatalf("got %d segments, expected %d", len(timeline), len(expected))
+	}
+	for i, segment := range timeline {
+		e := expected[i]
+		if !sameRoute(segment.RouteID, e.RouteID) || !segment.Start.Equal(e.Start) || !segment.End.Equal(e.End) || segment.Duration != e.Duration {
+			t.Errorf("got segment %d %+v, expected %+v", i, segment, e)
+		}
+	}
+}
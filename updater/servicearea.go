@@ -0,0 +1,29 @@
+package updater
+
+import (
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+// RouteServiceArea returns the convex hull of a route's polylines and stops, counterclockwise from its
+// westernmost vertex, for drawing the area the route covers. A route with fewer than three distinct
+// points has them returned as they are.
+func (u *Updater) RouteServiceArea(routeID int64) ([]shuttletracker.Point, error) {
+	route, err := u.ms.Route(routeID)
+	if err != nil {
+		return nil, err
+	}
+	stops, err := u.stopsForRoute(route)
+	if err != nil {
+		return nil, err
+	}
+
+	points := []shuttletracker.Point{}
+	for _, polyline := range route.Polylines() {
+		points = append(points, polyline...)
+	}
+	for _, stop := range stops {
+		points = append(points, shuttletracker.Point{Latitude: stop.Latitude, Longitude: stop.Longitude})
+	}
+	return spatial.ConvexHull(points), nil
+}
@@ -0,0 +1,49 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestRouteServiceArea(t *testing.T) {
+	route := &shuttletracker.Route{
+		ID:      1,
+		StopIDs: []int64{1},
+		Points: []shuttletracker.Point{
+			{Latitude: 42.73, Longitude: -73.68},
+			{Latitude: 42.73, Longitude: -73.67},
+			{Latitude: 42.735, Longitude: -73.675}, // inside the hull
+			{Latitude: 42.74, Longitude: -73.67},
+		},
+	}
+	// the stop extends the area to the north-west
+	stops := []*shuttletracker.Stop{{ID: 1, Latitude: 42.74, Longitude: -73.68}}
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Route", route.ID).Return(route, nil)
+	ms.StopService.On("Stops").Return(stops, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	area, err := u.RouteServiceArea(route.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []shuttletracker.Point{
+		{Latitude: 42.73, Longitude: -73.68},
+		{Latitude: 42.73, Longitude: -73.67},
+		{Latitude: 42.74, Longitude: -73.67},
+		{Latitude: 42.74, Longitude: -73.68},
+	}
+	if len(area) != len(expected) {
+		t.Fatalf("got %d points, expected %d: %+v", len(area), len(expected), area)
+	}
+	for i := range expected {
+		if area[i] != expected[i] {
+			t.Errorf("got point %d %+v, expected %+v", i, area[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package updater
+
+import (
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+// SimplifiedRoute returns a route's points with those that deviate less than tolerance meters from
+// the rest of the route removed. The route's endpoints are always included, and a tolerance of zero
+// returns every point.
+func (u *Updater) SimplifiedRoute(routeID int64, tolerance float64) ([]shuttletracker.Point, error) {
+	route, err := u.ms.Route(routeID)
+	if err != nil {
+		return nil, err
+	}
+	return spatial.Simplify(route.Points, tolerance), nil
+}
@@ -0,0 +1,42 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestSimplifiedRoute(t *testing.T) {
+	route := &shuttletracker.Route{
+		ID: 1,
+		Points: []shuttletracker.Point{
+			{Latitude: 42.730, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.675},
+			{Latitude: 42.730, Longitude: -73.670},
+		},
+	}
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Route", route.ID).Return(route, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	points, err := u.SimplifiedRoute(route.ID, 1)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(points) != 2 || points[0] != route.Points[0] || points[1] != route.Points[2] {
+		t.Errorf("got %+v, expected only the endpoints", points)
+	}
+
+	points, err = u.SimplifiedRoute(route.ID, 0)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if len(points) != len(route.Points) {
+		t.Errorf("got %d points, expected %d", len(points), len(route.Points))
+	}
+	ms.RouteService.AssertExpectations(t)
+}
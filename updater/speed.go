@@ -0,0 +1,33 @@
+package updater
+
+import (
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+// deriveSpeed replaces an update's reported speed of zero with the average speed since its vehicle's
+// previous Location when the vehicle has moved at least minDistance meters since then. The reported
+// speed is kept in the update's ReportedSpeed. A nil previous leaves the speed as it is.
+func deriveSpeed(previous, update *shuttletracker.Location, minDistance float64) {
+	if update.Speed != 0 || previous == nil {
+		return
+	}
+	elapsed := update.Time.Sub(previous.Time)
+	if elapsed <= 0 {
+		return
+	}
+
+	from := shuttletracker.Point{Latitude: previous.Latitude, Longitude: previous.Longitude}
+	to := shuttletracker.Point{Latitude: update.Latitude, Longitude: update.Longitude}
+	distance := spatial.DistanceBetween(from, to)
+	if distance < minDistance {
+		return
+	}
+	if update.ReportedSpeed == nil {
+		reported := update.Speed
+		update.ReportedSpeed = &reported
+	}
+	// meters per second to kilometers per hour
+	update.Speed = kphToMPH(distance / elapsed.Seconds() * 3.6)
+	update.SpeedDerived = true
+}
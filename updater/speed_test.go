@@ -0,0 +1,47 @@
+package updater
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+func TestDeriveSpeed(t *testing.T) {
+	now := time.Now()
+	// about 82 meters west of the moving updates and 10 seconds earlier, which averages 18.3 mph
+	previous := &shuttletracker.Location{Latitude: 42.73, Longitude: -73.68, Time: now.Add(-time.Second * 10)}
+
+	cases := []struct {
+		name    string
+		update  *shuttletracker.Location
+		speed   float64
+		derived bool
+	}{
+		{"moved", &shuttletracker.Location{Latitude: 42.73, Longitude: -73.679, Time: now}, 18.27, true},
+		{"reported", &shuttletracker.Location{Latitude: 42.73, Longitude: -73.679, Speed: 5, Time: now}, 5, false},
+		{"stationary", &shuttletracker.Location{Latitude: 42.73, Longitude: -73.6799, Time: now}, 0, false},
+		{"out of order", &shuttletracker.Location{Latitude: 42.73, Longitude: -73.679, Time: now.Add(-time.Minute)}, 0, false},
+	}
+	for _, c := range cases {
+		deriveSpeed(previous, c.update, 20)
+		if math.Abs(c.update.Speed-c.speed) > 0.1 || c.update.SpeedDerived != c.derived {
+			t.Errorf("%s: got speed %f derived %t, expected %f derived %t", c.name, c.update.Speed, c.update.SpeedDerived, c.speed, c.derived)
+		}
+		if c.derived && (c.update.ReportedSpeed == nil || *c.update.ReportedSpeed != 0) {
+			t.Errorf("%s: got reported speed %v, expected the reported zero to be kept", c.name, c.update.ReportedSpeed)
+		}
+	}
+
+	update := &shuttletracker.Location{Latitude: 42.73, Longitude: -73.679, Time: now}
+	deriveSpeed(nil, update, 20)
+	if update.Speed != 0 || update.SpeedDerived {
+		t.Errorf("got speed %f derived %t without a previous location, expected 0", update.Speed, update.SpeedDerived)
+	}
+
+	_, err := New(Config{UpdateInterval: "10s", DeriveSpeedDistance: -1}, nil)
+	if err == nil {
+		t.Error("expected an error for a negative derive speed distance")
+	}
+}
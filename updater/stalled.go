@@ -0,0 +1,76 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+const (
+	// stalledMovement is how far in meters a vehicle may drift while stopped, which covers GPS noise.
+	stalledMovement = 15.0
+
+	// stalledLookback is how far before the stopped period Locations are fetched, so that there is one
+	// showing where the vehicle was when the period began.
+	stalledLookback = time.Minute
+)
+
+// StalledVehicles returns the vehicles on a route that haven't moved for at least minStopped, which may
+// mean that they have broken down. A vehicle has not moved if every Location since the start of that
+// period, including the last one before it, is within a few meters of its latest Location. Vehicles
+// within stopRadius meters of any Stop are dwelling there and are excluded, as are vehicles whose
+// latest Location isn't fresh.
+func (u *Updater) StalledVehicles(minStopped time.Duration, stopRadius float64) ([]*shuttletracker.Vehicle, error) {
+	current, err := u.currentVehicles()
+	if err != nil {
+		return nil, err
+	}
+	stops, err := u.ms.Stops()
+	if err != nil {
+		return nil, err
+	}
+
+	stalled := []*shuttletracker.Vehicle{}
+	for _, v := range current {
+		latest := v.Location
+		if latest.RouteID == nil {
+			continue
+		}
+		position := shuttletracker.Point{Latitude: latest.Latitude, Longitude: latest.Longitude}
+		atStop := false
+		for _, stop := range stops {
+			if spatial.DistanceBetween(position, shuttletracker.Point{Latitude: stop.Latitude, Longitude: stop.Longitude}) <= stopRadius {
+				atStop = true
+				break
+			}
+		}
+		if atStop {
+			continue
+		}
+
+		start := latest.Time.Add(-minStopped)
+		// newest first
+		locations, err := u.ms.LocationsSince(v.Vehicle.ID, start.Add(-stalledLookback))
+		if err != nil {
+			return nil, err
+		}
+		covered := false
+		moved := false
+		for _, location := range locations {
+			point := shuttletracker.Point{Latitude: location.Latitude, Longitude: location.Longitude}
+			if spatial.DistanceBetween(position, point) > stalledMovement {
+				moved = true
+				break
+			}
+			if !location.Time.After(start) {
+				covered = true
+				break
+			}
+		}
+		if covered && !moved {
+			stalled = append(stalled, v.Vehicle)
+		}
+	}
+	return stalled, nil
+}
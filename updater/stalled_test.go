@@ -0,0 +1,57 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/wtg/shuttletracker"
+	stmock "github.com/wtg/shuttletracker/mock"
+)
+
+func TestStalledVehicles(t *testing.T) {
+	now := time.Now()
+	routeID := int64(1)
+	stalledAt := func(minutesAgo int) *shuttletracker.Location {
+		return &shuttletracker.Location{Latitude: 42.7300, Longitude: -73.6800, RouteID: &routeID, Time: now.Add(-time.Minute * time.Duration(minutesAgo))}
+	}
+	vehicles := []*shuttletracker.Vehicle{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}}
+	stops := []*shuttletracker.Stop{{ID: 10, Latitude: 42.7400, Longitude: -73.6800}}
+	atStop := &shuttletracker.Location{Latitude: 42.7401, Longitude: -73.6800, RouteID: &routeID, Time: now}
+	offRoute := &shuttletracker.Location{Latitude: 42.7300, Longitude: -73.6800, Time: now}
+
+	ms := &stmock.ModelService{}
+	ms.VehicleService.On("EnabledVehicles").Return(vehicles, nil)
+	ms.StopService.On("Stops").Return(stops, nil)
+	// stopped for ten minutes with a little GPS drift
+	drift := stalledAt(0)
+	drift.Latitude += 0.00005
+	ms.LocationService.On("LatestLocation", int64(1)).Return(stalledAt(0), nil)
+	ms.LocationService.On("LocationsSince", int64(1), mock.Anything).Return([]*shuttletracker.Location{stalledAt(0), drift, stalledAt(5), stalledAt(11)}, nil)
+	// stopped for only two minutes after moving
+	moving := stalledAt(3)
+	moving.Latitude += 0.01
+	ms.LocationService.On("LatestLocation", int64(2)).Return(stalledAt(0), nil)
+	ms.LocationService.On("LocationsSince", int64(2), mock.Anything).Return([]*shuttletracker.Location{stalledAt(0), stalledAt(2), moving}, nil)
+	// dwelling at a stop and parked off-route
+	ms.LocationService.On("LatestLocation", int64(3)).Return(atStop, nil)
+	ms.LocationService.On("LatestLocation", int64(4)).Return(offRoute, nil)
+	// only reporting for the last two minutes, so there's no evidence of a longer stop
+	ms.LocationService.On("LatestLocation", int64(5)).Return(stalledAt(0), nil)
+	ms.LocationService.On("LocationsSince", int64(5), mock.Anything).Return([]*shuttletracker.Location{stalledAt(0), stalledAt(2)}, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	stalled, err := u.StalledVehicles(time.Minute*10, 30)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(stalled) != 1 || stalled[0].ID != 1 {
+		t.Errorf("got %+v, expected only vehicle 1", stalled)
+	}
+	ms.LocationService.AssertNotCalled(t, "LocationsSince", int64(3), mock.Anything)
+	ms.LocationService.AssertNotCalled(t, "LocationsSince", int64(4), mock.Anything)
+}
@@ -0,0 +1,81 @@
+package updater
+
+import (
+	"sort"
+	"time"
+)
+
+// FeedStats describes how the records in the most recent data feed update were processed.
+type FeedStats struct {
+	Time    time.Time `json:"time"`
+	Records int       `json:"records"`
+
+	// ProcessingP50 and ProcessingP95 are percentiles of the time taken to handle each record.
+	ProcessingP50 time.Duration `json:"processing_p50"`
+	ProcessingP95 time.Duration `json:"processing_p95"`
+
+	// SlowestTrackerID is the tracker whose record took the longest to handle.
+	SlowestTrackerID  string        `json:"slowest_tracker_id"`
+	SlowestProcessing time.Duration `json:"slowest_processing"`
+}
+
+// recordTiming is how long handling a single data feed record took.
+type recordTiming struct {
+	trackerID string
+	stored    bool
+	duration  time.Duration
+}
+
+func newFeedStats(timings []recordTiming) FeedStats {
+	stats := FeedStats{
+		Time:    time.Now(),
+		Records: len(timings),
+	}
+	if len(timings) == 0 {
+		return stats
+	}
+
+	durations := make([]time.Duration, len(timings))
+	for i, timing := range timings {
+		durations[i] = timing.duration
+		if timing.duration > stats.SlowestProcessing {
+			stats.SlowestProcessing = timing.duration
+			stats.SlowestTrackerID = timing.trackerID
+		}
+	}
+	sort.Sort(byDuration(durations))
+	stats.ProcessingP50 = percentile(durations, 50)
+	stats.ProcessingP95 = percentile(durations, 95)
+	return stats
+}
+
+type byDuration []time.Duration
+
+func (d byDuration) Len() int           { return len(d) }
+func (d byDuration) Less(i, j int) bool { return d[i] < d[j] }
+func (d byDuration) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// percentile returns the nearest-rank percentile p of sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func (u *Updater) setFeedStats(stats FeedStats) {
+	u.mutex.Lock()
+	u.feedStats = stats
+	u.mutex.Unlock()
+}
+
+// FeedStats returns statistics about the most recent data feed update.
+func (u *Updater) FeedStats() FeedStats {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.feedStats
+}
@@ -0,0 +1,34 @@
+package updater
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewFeedStats(t *testing.T) {
+	timings := []recordTiming{}
+	for i := 1; i <= 20; i++ {
+		timings = append(timings, recordTiming{trackerID: "fast", duration: time.Duration(i) * time.Millisecond})
+	}
+	timings[7] = recordTiming{trackerID: "slow", duration: time.Second}
+
+	stats := newFeedStats(timings)
+	if stats.Records != 20 {
+		t.Errorf("got %d records, expected 20", stats.Records)
+	}
+	if stats.SlowestTrackerID != "slow" || stats.SlowestProcessing != time.Second {
+		t.Errorf("got slowest %s (%s), expected slow (1s)", stats.SlowestTrackerID, stats.SlowestProcessing)
+	}
+	// durations are 1-20ms with 8ms replaced by 1s
+	if stats.ProcessingP50 != 11*time.Millisecond {
+		t.Errorf("got p50 %s, expected 11ms", stats.ProcessingP50)
+	}
+	if stats.ProcessingP95 != 20*time.Millisecond {
+		t.Errorf("got p95 %s, expected 20ms", stats.ProcessingP95)
+	}
+
+	stats = newFeedStats(nil)
+	if stats.Records != 0 || stats.ProcessingP95 != 0 || stats.SlowestTrackerID != "" {
+		t.Errorf("got %+v, expected empty stats", stats)
+	}
+}
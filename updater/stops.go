@@ -0,0 +1,173 @@
+package updater
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+// ErrNotOnRoute indicates that a vehicle is not currently on any route that could be guessed.
+var ErrNotOnRoute = errors.New("vehicle is not on a route")
+
+// loopTolerance is how close in meters the ends of a route's polyline must be for it to be a loop.
+const loopTolerance = 50.0
+
+// stopsForRoute returns the route's Stops in route order.
+func (u *Updater) stopsForRoute(route *shuttletracker.Route) ([]*shuttletracker.Stop, error) {
+	stops, err := u.ms.Stops()
+	if err != nil {
+		return nil, err
+	}
+	idsToStop := map[int64]*shuttletracker.Stop{}
+	for _, stop := range stops {
+		idsToStop[stop.ID] = stop
+	}
+
+	ordered := []*shuttletracker.Stop{}
+	for _, id := range route.StopIDs {
+		if stop, ok := idsToStop[id]; ok {
+			ordered = append(ordered, stop)
+		}
+	}
+	return ordered, nil
+}
+
+// currentRoute returns the route that a vehicle's latest Location was guessed to be on when it was
+// stored, along with that Location. ErrNotOnRoute is returned if the Location isn't on a route or is
+// older than the freshness window.
+func (u *Updater) currentRoute(vehicleID int64) (*shuttletracker.Route, *shuttletracker.Location, error) {
+	u.mutex.Lock()
+	freshness := u.locationFreshness
+	u.mutex.Unlock()
+
+	location, err := u.ms.LatestLocation(vehicleID)
+	if err == shuttletracker.ErrLocationNotFound {
+		return nil, nil, ErrNotOnRoute
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if location.RouteID == nil || location.Time.Before(time.Now().Add(-freshness)) {
+		return nil, nil, ErrNotOnRoute
+	}
+	route, err := u.ms.Route(*location.RouteID)
+	if err == shuttletracker.ErrRouteNotFound {
+		return nil, nil, ErrNotOnRoute
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if len(route.Points) == 0 {
+		return nil, nil, ErrNotOnRoute
+	}
+	return route, location, nil
+}
+
+// UpcomingStops returns the next k Stops that a vehicle will reach on its current route, in order.
+// Loop routes wrap around from their last Stop to their first, but no Stop is returned twice.
+// ErrNotOnRoute is returned if the vehicle is not on a route.
+func (u *Updater) UpcomingStops(vehicleID int64, k int) ([]*shuttletracker.Stop, error) {
+	route, location, err := u.currentRoute(vehicleID)
+	if err != nil {
+		return nil, err
+	}
+	stops, err := u.stopsForRoute(route)
+	if err != nil {
+		return nil, err
+	}
+
+	loop := spatial.IsLoop(route.Points, loopTolerance)
+	length := spatial.Length(route.Points)
+	position := spatial.Project(shuttletracker.Point{Latitude: location.Latitude, Longitude: location.Longitude}, route.Points).Along
+
+	// find the closest stop ahead of the vehicle along the route
+	next := -1
+	nearest := math.Inf(0)
+	for i, stop := range stops {
+		ahead := spatial.Project(shuttletracker.Point{Latitude: stop.Latitude, Longitude: stop.Longitude}, route.Points).Along - position
+		if ahead < 0 {
+			if !loop {
+				continue
+			}
+			ahead += length
+		}
+		if ahead < nearest {
+			nearest = ahead
+			next = i
+		}
+	}
+
+	upcoming := []*shuttletracker.Stop{}
+	if next == -1 {
+		// past the last stop of a route that doesn't loop
+		return upcoming, nil
+	}
+	for i := next; len(upcoming) < k && len(upcoming) < len(stops); i++ {
+		if i == len(stops) {
+			if !loop {
+				break
+			}
+			i = 0
+		}
+		upcoming = append(upcoming, stops[i])
+	}
+	return upcoming, nil
+}
+
+// NoStop is returned by CurrentSegment in place of a Stop ID when a vehicle on a route that doesn't
+// loop is before its first Stop or after its last.
+const NoStop int64 = 0
+
+// CurrentSegment returns the route a vehicle is on and the Stops that it is between: the last Stop it
+// passed and the next one it will reach. On loop routes these wrap around, so a vehicle past the last
+// Stop is between it and the first. ErrNotOnRoute is returned if the vehicle is not on a route.
+func (u *Updater) CurrentSegment(vehicleID int64) (routeID int64, fromStopID, toStopID int64, err error) {
+	route, location, err := u.currentRoute(vehicleID)
+	if err != nil {
+		return 0, NoStop, NoStop, err
+	}
+	stops, err := u.stopsForRoute(route)
+	if err != nil {
+		return 0, NoStop, NoStop, err
+	}
+
+	loop := spatial.IsLoop(route.Points, loopTolerance)
+	position := spatial.Project(shuttletracker.Point{Latitude: location.Latitude, Longitude: location.Longitude}, route.Points).Along
+
+	// find the stops closest behind and ahead of the vehicle, and the first and last along the route
+	var from, to, first, last *shuttletracker.Stop
+	var fromAlong, toAlong, firstAlong, lastAlong float64
+	for _, stop := range stops {
+		along := spatial.Project(shuttletracker.Point{Latitude: stop.Latitude, Longitude: stop.Longitude}, route.Points).Along
+		if along <= position && (from == nil || along > fromAlong) {
+			from, fromAlong = stop, along
+		}
+		if along > position && (to == nil || along < toAlong) {
+			to, toAlong = stop, along
+		}
+		if first == nil || along < firstAlong {
+			first, firstAlong = stop, along
+		}
+		if last == nil || along > lastAlong {
+			last, lastAlong = stop, along
+		}
+	}
+	if loop {
+		if from == nil {
+			from = last
+		}
+		if to == nil {
+			to = first
+		}
+	}
+
+	fromStopID, toStopID = NoStop, NoStop
+	if from != nil {
+		fromStopID = from.ID
+	}
+	if to != nil {
+		toStopID = to.ID
+	}
+	return route.ID, fromStopID, toStopID, nil
+}
@@ -0,0 +1,193 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+// newRouteTestUpdater returns an Updater whose vehicle 1 was just stored at position on route.
+func newRouteTestUpdater(t *testing.T, route *shuttletracker.Route, stops []*shuttletracker.Stop, position shuttletracker.Point) *Updater {
+	ms := &mock.ModelService{}
+	location := &shuttletracker.Location{Latitude: position.Latitude, Longitude: position.Longitude, Time: time.Now(), RouteID: &route.ID}
+	ms.LocationService.On("LatestLocation", int64(1)).Return(location, nil)
+	ms.RouteService.On("Route", route.ID).Return(route, nil)
+	ms.StopService.On("Stops").Return(stops, nil)
+
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return u
+}
+
+func stopIDs(stops []*shuttletracker.Stop) []int64 {
+	ids := []int64{}
+	for _, stop := range stops {
+		ids = append(ids, stop.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpcomingStops(t *testing.T) {
+	// a square loop with a stop in the middle of each side
+	route := &shuttletracker.Route{
+		ID:      1,
+		Name:    "Loop",
+		Enabled: true,
+		Active:  true,
+		StopIDs: []int64{1, 2, 3, 4},
+		Points: []shuttletracker.Point{
+			{Latitude: 42.730, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.670},
+			{Latitude: 42.740, Longitude: -73.670},
+			{Latitude: 42.740, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.680},
+		},
+	}
+	stops := []*shuttletracker.Stop{
+		{ID: 1, Latitude: 42.730, Longitude: -73.675},
+		{ID: 2, Latitude: 42.735, Longitude: -73.670},
+		{ID: 3, Latitude: 42.740, Longitude: -73.675},
+		{ID: 4, Latitude: 42.735, Longitude: -73.680},
+	}
+
+	// on the second side, before stop 2
+	u := newRouteTestUpdater(t, route, stops, shuttletracker.Point{Latitude: 42.732, Longitude: -73.670})
+	upcoming, err := u.UpcomingStops(1, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ids := stopIDs(upcoming); !equalIDs(ids, []int64{2, 3, 4}) {
+		t.Errorf("got stops %v, expected [2 3 4]", ids)
+	}
+
+	// asking for more stops than the route has wraps around without repeating
+	upcoming, err = u.UpcomingStops(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ids := stopIDs(upcoming); !equalIDs(ids, []int64{2, 3, 4, 1}) {
+		t.Errorf("got stops %v, expected [2 3 4 1]", ids)
+	}
+
+	// without the closing side the route is no longer a loop, so stops 1 and 2 are behind the vehicle
+	route.Points = route.Points[:4]
+	u = newRouteTestUpdater(t, route, stops[:3], shuttletracker.Point{Latitude: 42.740, Longitude: -73.672})
+	upcoming, err = u.UpcomingStops(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ids := stopIDs(upcoming); !equalIDs(ids, []int64{3}) {
+		t.Errorf("got stops %v, expected [3]", ids)
+	}
+
+	// past the last stop there is nothing upcoming
+	u = newRouteTestUpdater(t, route, stops[:3], shuttletracker.Point{Latitude: 42.740, Longitude: -73.678})
+	upcoming, err = u.UpcomingStops(1, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(upcoming) != 0 {
+		t.Errorf("got stops %v, expected none", stopIDs(upcoming))
+	}
+}
+
+func TestUpcomingStopsNotOnRoute(t *testing.T) {
+	route := &shuttletracker.Route{ID: 1}
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Route", route.ID).Return(route, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, latest := range []*shuttletracker.Location{
+		// stored without a route
+		{Time: time.Now()},
+		// on the route, but too long ago
+		{Time: time.Now().Add(-time.Hour), RouteID: &route.ID},
+	} {
+		ms.LocationService.On("LatestLocation", int64(1)).Return(latest, nil).Once()
+		_, err := u.UpcomingStops(1, 3)
+		if err != ErrNotOnRoute {
+			t.Errorf("got error %v for %+v, expected %v", err, latest, ErrNotOnRoute)
+		}
+	}
+
+	// asking doesn't guess the vehicle's route
+	ms.LocationService.AssertNotCalled(t, "LocationsSince", int64(1))
+}
+
+func TestCurrentSegment(t *testing.T) {
+	loop := &shuttletracker.Route{
+		ID:      1,
+		Name:    "Loop",
+		Enabled: true,
+		Active:  true,
+		StopIDs: []int64{1, 2, 3, 4},
+		Points: []shuttletracker.Point{
+			{Latitude: 42.730, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.670},
+			{Latitude: 42.740, Longitude: -73.670},
+			{Latitude: 42.740, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.680},
+		},
+	}
+	line := &shuttletracker.Route{
+		ID:      2,
+		Name:    "Line",
+		Enabled: true,
+		Active:  true,
+		StopIDs: []int64{1, 2},
+		Points: []shuttletracker.Point{
+			{Latitude: 42.730, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.670},
+			{Latitude: 42.740, Longitude: -73.670},
+		},
+	}
+	stops := []*shuttletracker.Stop{
+		{ID: 1, Latitude: 42.730, Longitude: -73.675},
+		{ID: 2, Latitude: 42.735, Longitude: -73.670},
+		{ID: 3, Latitude: 42.740, Longitude: -73.675},
+		{ID: 4, Latitude: 42.735, Longitude: -73.680},
+	}
+
+	cases := []struct {
+		name     string
+		route    *shuttletracker.Route
+		position shuttletracker.Point
+		from, to int64
+	}{
+		{"loop between stops", loop, shuttletracker.Point{Latitude: 42.732, Longitude: -73.670}, 1, 2},
+		{"loop past the last stop", loop, shuttletracker.Point{Latitude: 42.732, Longitude: -73.680}, 4, 1},
+		{"loop before the first stop", loop, shuttletracker.Point{Latitude: 42.730, Longitude: -73.678}, 4, 1},
+		{"line before the first stop", line, shuttletracker.Point{Latitude: 42.730, Longitude: -73.678}, NoStop, 1},
+		{"line between stops", line, shuttletracker.Point{Latitude: 42.730, Longitude: -73.672}, 1, 2},
+		{"line past the last stop", line, shuttletracker.Point{Latitude: 42.738, Longitude: -73.670}, 2, NoStop},
+	}
+	for _, c := range cases {
+		u := newRouteTestUpdater(t, c.route, stops, c.position)
+		routeID, from, to, err := u.CurrentSegment(1)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", c.name, err)
+		}
+		if routeID != c.route.ID || from != c.from || to != c.to {
+			t.Errorf("%s: got route %d from %d to %d, expected route %d from %d to %d", c.name, routeID, from, to, c.route.ID, c.from, c.to)
+		}
+	}
+}
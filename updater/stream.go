@@ -0,0 +1,67 @@
+package updater
+
+import (
+	"encoding/json"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/log"
+)
+
+// locationStreamBuffer is how many Locations a subscriber can fall behind by before new ones are
+// dropped for it.
+const locationStreamBuffer = 64
+
+// locationSubscriber receives newly stored Locations.
+type locationSubscriber struct {
+	c chan []byte
+
+	// dropping is set while the subscriber's buffer is full so that overflow is logged only once.
+	dropping bool
+}
+
+// SubscribeLocations returns a channel that receives every newly stored Location serialized as a
+// single line of JSON in the same shape as the REST API, ready to write as a Server-Sent Events data
+// field. Subscribers that fall too far behind miss Locations rather than slowing down updates.
+// The returned function ends the subscription and closes the channel.
+func (u *Updater) SubscribeLocations() (<-chan []byte, func()) {
+	sub := &locationSubscriber{c: make(chan []byte, locationStreamBuffer)}
+	u.mutex.Lock()
+	u.locationSubscribers[sub] = true
+	u.mutex.Unlock()
+
+	cancel := func() {
+		u.mutex.Lock()
+		defer u.mutex.Unlock()
+		if u.locationSubscribers[sub] {
+			delete(u.locationSubscribers, sub)
+			close(sub.c)
+		}
+	}
+	return sub.c, cancel
+}
+
+// publishLocation sends a newly stored Location to every subscriber without blocking.
+func (u *Updater) publishLocation(location *shuttletracker.Location) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if len(u.locationSubscribers) == 0 {
+		return
+	}
+
+	b, err := json.Marshal(location)
+	if err != nil {
+		log.WithError(err).Error("Unable to serialize location.")
+		return
+	}
+	for sub := range u.locationSubscribers {
+		select {
+		case sub.c <- b:
+			sub.dropping = false
+		default:
+			if !sub.dropping {
+				log.Warn("Location subscriber is too slow; dropping locations.")
+				sub.dropping = true
+			}
+		}
+	}
+}
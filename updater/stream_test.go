@@ -0,0 +1,43 @@
+package updater
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+)
+
+func TestSubscribeLocations(t *testing.T) {
+	u, err := New(Config{UpdateInterval: "10s"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	c, cancel := u.SubscribeLocations()
+	routeID := int64(3)
+	location := &shuttletracker.Location{ID: 1, TrackerID: "1234", Latitude: 42.73, Longitude: -73.68, RouteID: &routeID}
+	u.publishLocation(location)
+
+	b := <-c
+	expected, err := json.Marshal(location)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(b) != string(expected) {
+		t.Errorf("got %s, expected %s", b, expected)
+	}
+
+	// a full buffer drops new locations instead of blocking
+	for i := 0; i < locationStreamBuffer+10; i++ {
+		u.publishLocation(location)
+	}
+	if len(c) != locationStreamBuffer {
+		t.Errorf("got %d buffered locations, expected %d", len(c), locationStreamBuffer)
+	}
+
+	cancel()
+	cancel()
+	for range c {
+	}
+	// publishing after the subscription ends doesn't panic
+	u.publishLocation(location)
+}
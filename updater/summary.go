@@ -0,0 +1,95 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// movingSpeed is the speed in miles per hour above which a vehicle is considered to be in service.
+const movingSpeed = 2.0
+
+// ServiceSummary describes how much a Vehicle was used during a single day.
+type ServiceSummary struct {
+	VehicleID int64     `json:"vehicle_id"`
+	Day       time.Time `json:"day"`
+
+	// FirstSeen and LastSeen are the tracker times of the first and last Locations of the day.
+	// They are zero if the Vehicle reported no Locations.
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// ActiveMinutes is the number of distinct minutes with at least one Location faster than movingSpeed.
+	ActiveMinutes int `json:"active_minutes"`
+}
+
+// VehicleServiceSummary summarizes a Vehicle's Locations during the campus day containing day.
+func (u *Updater) VehicleServiceSummary(vehicleID int64, day time.Time) (ServiceSummary, error) {
+	u.mutex.Lock()
+	loc := u.campusLocation
+	u.mutex.Unlock()
+
+	local := day.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	// AddDate keeps the wall clock time, so days with a DST transition are handled correctly.
+	end := start.AddDate(0, 0, 1)
+	summary := ServiceSummary{
+		VehicleID: vehicleID,
+		Day:       start,
+	}
+
+	locations, err := u.ms.LocationsBetween(vehicleID, start, end, shuttletracker.Collapse{})
+	if err != nil {
+		return ServiceSummary{}, err
+	}
+	if len(locations) == 0 {
+		return summary, nil
+	}
+	summary.FirstSeen = locations[0].Time
+	summary.LastSeen = locations[len(locations)-1].Time
+
+	activeMinutes := map[time.Time]bool{}
+	for _, location := range locations {
+		if location.Speed > movingSpeed {
+			activeMinutes[location.Time.Truncate(time.Minute)] = true
+		}
+	}
+	summary.ActiveMinutes = len(activeMinutes)
+	return summary, nil
+}
+
+// idleSpeed returns the speed below which cfg considers a vehicle idle.
+func idleSpeed(cfg Config) float64 {
+	if cfg.IdleSpeed == 0 {
+		return movingSpeed
+	}
+	return cfg.IdleSpeed
+}
+
+// VehicleIdleTime returns how long a Vehicle spent idling during the campus day containing day. This
+// is the total time between consecutive Locations that are both slower than the configured idle speed.
+// Gaps longer than the trip idle gap are not counted, since the tracker was probably off.
+func (u *Updater) VehicleIdleTime(vehicleID int64, day time.Time) (time.Duration, error) {
+	speed := idleSpeed(u.config())
+	u.mutex.Lock()
+	loc := u.campusLocation
+	gap := u.tripIdleGap
+	u.mutex.Unlock()
+
+	local := day.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	locations, err := u.ms.LocationsBetween(vehicleID, start, start.AddDate(0, 0, 1), shuttletracker.Collapse{})
+	if err != nil {
+		return 0, err
+	}
+
+	var idle time.Duration
+	for i := 1; i < len(locations); i++ {
+		previous, location := locations[i-1], locations[i]
+		elapsed := location.Time.Sub(previous.Time)
+		if previous.Speed < speed && location.Speed < speed && elapsed <= gap {
+			idle += elapsed
+		}
+	}
+	return idle, nil
+}
@@ -0,0 +1,104 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestVehicleServiceSummary(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %s", err)
+	}
+	start := time.Date(2018, time.April, 16, 0, 0, 0, 0, loc)
+	end := time.Date(2018, time.April, 17, 0, 0, 0, 0, loc)
+	first := time.Date(2018, time.April, 16, 7, 0, 10, 0, loc)
+	locations := []*shuttletracker.Location{
+		{Time: first, Speed: 0},
+		{Time: first.Add(time.Second * 20), Speed: 10},
+		{Time: first.Add(time.Second * 40), Speed: 12},
+		{Time: first.Add(time.Minute * 5), Speed: 15},
+		{Time: first.Add(time.Minute * 6), Speed: 1},
+	}
+
+	ms := &mock.ModelService{}
+	ms.LocationService.On("LocationsBetween", int64(1), start, end, shuttletracker.Collapse{}).Return(locations, nil)
+	u, err := New(Config{UpdateInterval: "10s", CampusTimezone: "America/New_York"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// 2 AM UTC on the 17th is still the 16th on campus
+	summary, err := u.VehicleServiceSummary(1, time.Date(2018, time.April, 17, 2, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !summary.Day.Equal(start) {
+		t.Errorf("got day %v, expected %v", summary.Day, start)
+	}
+	if !summary.FirstSeen.Equal(first) || !summary.LastSeen.Equal(locations[4].Time) {
+		t.Errorf("got first seen %v and last seen %v", summary.FirstSeen, summary.LastSeen)
+	}
+	if summary.ActiveMinutes != 2 {
+		t.Errorf("got %d active minutes, expected 2", summary.ActiveMinutes)
+	}
+}
+
+func TestVehicleIdleTime(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %s", err)
+	}
+	start := time.Date(2018, time.April, 16, 0, 0, 0, 0, loc)
+	end := time.Date(2018, time.April, 17, 0, 0, 0, 0, loc)
+	first := time.Date(2018, time.April, 16, 7, 0, 0, 0, loc)
+	locations := []*shuttletracker.Location{
+		{Time: first, Speed: 0},
+		{Time: first.Add(time.Minute * 2), Speed: 1},
+		// moving
+		{Time: first.Add(time.Minute * 3), Speed: 15},
+		{Time: first.Add(time.Minute * 4), Speed: 0},
+		{Time: first.Add(time.Minute * 5), Speed: 0.5},
+		// the tracker was off for an hour
+		{Time: first.Add(time.Minute * 65), Speed: 0},
+		{Time: first.Add(time.Minute * 68), Speed: 2.5},
+	}
+
+	ms := &mock.ModelService{}
+	ms.LocationService.On("LocationsBetween", int64(1), start, end, shuttletracker.Collapse{}).Return(locations, nil)
+	u, err := New(Config{UpdateInterval: "10s", CampusTimezone: "America/New_York", TripIdleGap: "10m"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// 2 AM UTC on the 17th is still the 16th on campus
+	day := time.Date(2018, time.April, 17, 2, 0, 0, 0, time.UTC)
+	idle, err := u.VehicleIdleTime(1, day)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if idle != time.Minute*3 {
+		t.Errorf("got idle time %s, expected 3m", idle)
+	}
+
+	// a higher idle speed counts the last span too
+	u, err = New(Config{UpdateInterval: "10s", CampusTimezone: "America/New_York", TripIdleGap: "10m", IdleSpeed: 3}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	idle, err = u.VehicleIdleTime(1, day)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if idle != time.Minute*6 {
+		t.Errorf("got idle time %s, expected 6m", idle)
+	}
+
+	_, err = New(Config{UpdateInterval: "10s", IdleSpeed: -1}, ms)
+	if err == nil {
+		t.Error("expected an error for a negative idle speed")
+	}
+}
@@ -0,0 +1,117 @@
+package updater
+
+import (
+	"math"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+// transferDistance is how close in meters two routes must come for riders to transfer between them
+// where neither has a shared stop.
+const transferDistance = 50.0
+
+// transferGeometry caches where one route's polylines come close to another's, for the route
+// versions it was computed from.
+type transferGeometry struct {
+	versionA int64
+	versionB int64
+	points   []shuttletracker.Point
+}
+
+// TransferPoints returns where riders can transfer from routeA to routeB. Stops served by both
+// routes come first, in routeA's order. They are followed by places where routeA's polylines come
+// within 50 meters of routeB's away from those stops, which are returned as Stops with an ID of
+// NoStop and only a position. The latter are cached until either route's geometry changes.
+func (u *Updater) TransferPoints(routeA, routeB int64) ([]*shuttletracker.Stop, error) {
+	a, err := u.ms.Route(routeA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := u.ms.Route(routeB)
+	if err != nil {
+		return nil, err
+	}
+	stops, err := u.stopsForRoute(a)
+	if err != nil {
+		return nil, err
+	}
+
+	onB := map[int64]bool{}
+	for _, id := range b.StopIDs {
+		onB[id] = true
+	}
+	transfers := []*shuttletracker.Stop{}
+	for _, stop := range stops {
+		if onB[stop.ID] {
+			transfers = append(transfers, stop)
+		}
+	}
+
+	shared := len(transfers)
+	for _, point := range u.closePoints(a, b) {
+		atStop := false
+		for _, stop := range transfers[:shared] {
+			stopPoint := shuttletracker.Point{Latitude: stop.Latitude, Longitude: stop.Longitude}
+			if spatial.DistanceBetween(point, stopPoint) <= transferDistance {
+				atStop = true
+				break
+			}
+		}
+		if !atStop {
+			transfers = append(transfers, &shuttletracker.Stop{ID: NoStop, Latitude: point.Latitude, Longitude: point.Longitude})
+		}
+	}
+	return transfers, nil
+}
+
+// closePoints returns the point of a's polylines closest to b's in each run of consecutive points
+// that are within transferDistance of them.
+func (u *Updater) closePoints(a, b *shuttletracker.Route) []shuttletracker.Point {
+	key := [2]int64{a.ID, b.ID}
+	u.mutex.Lock()
+	cached, ok := u.transferCache[key]
+	u.mutex.Unlock()
+	if ok && cached.versionA == a.Version && cached.versionB == b.Version {
+		return cached.points
+	}
+
+	points := []shuttletracker.Point{}
+	for _, polyline := range a.Polylines() {
+		inRun := false
+		var closest shuttletracker.Point
+		closestDistance := math.Inf(0)
+		for _, p := range polyline {
+			d := distanceToPolylines(p, b.Polylines())
+			if d <= transferDistance {
+				if d < closestDistance {
+					closest, closestDistance = p, d
+				}
+				inRun = true
+				continue
+			}
+			if inRun {
+				points = append(points, closest)
+				inRun = false
+				closestDistance = math.Inf(0)
+			}
+		}
+		if inRun {
+			points = append(points, closest)
+		}
+	}
+
+	u.mutex.Lock()
+	u.transferCache[key] = transferGeometry{versionA: a.Version, versionB: b.Version, points: points}
+	u.mutex.Unlock()
+	return points
+}
+
+// distanceToPolylines returns the distance in meters from p to the closest of polylines.
+func distanceToPolylines(p shuttletracker.Point, polylines [][]shuttletracker.Point) float64 {
+	distance := math.Inf(0)
+	for _, polyline := range polylines {
+		distance = math.Min(distance, spatial.Project(p, polyline).Distance)
+	}
+	return distance
+}
@@ -0,0 +1,80 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestTransferPoints(t *testing.T) {
+	// a runs north and b runs east, crossing at 42.73, -73.68
+	a := &shuttletracker.Route{
+		ID:      1,
+		Version: 1,
+		StopIDs: []int64{1, 2},
+		Points: []shuttletracker.Point{
+			{Latitude: 42.720, Longitude: -73.68},
+			{Latitude: 42.725, Longitude: -73.68},
+			{Latitude: 42.730, Longitude: -73.68},
+			{Latitude: 42.735, Longitude: -73.68},
+			{Latitude: 42.740, Longitude: -73.68},
+		},
+	}
+	b := &shuttletracker.Route{
+		ID:      2,
+		Version: 1,
+		StopIDs: []int64{2, 3},
+		Points: []shuttletracker.Point{
+			{Latitude: 42.73, Longitude: -73.69},
+			{Latitude: 42.73, Longitude: -73.67},
+		},
+	}
+	stops := []*shuttletracker.Stop{
+		{ID: 1, Latitude: 42.720, Longitude: -73.68},
+		{ID: 2, Latitude: 42.740, Longitude: -73.68},
+		{ID: 3, Latitude: 42.730, Longitude: -73.67},
+	}
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Route", a.ID).Return(a, nil)
+	ms.RouteService.On("Route", b.ID).Return(b, nil)
+	ms.StopService.On("Stops").Return(stops, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	check := func(name string, expected []shuttletracker.Stop) {
+		transfers, err := u.TransferPoints(a.ID, b.ID)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		if len(transfers) != len(expected) {
+			t.Fatalf("%s: got %d transfer points, expected %d", name, len(transfers), len(expected))
+		}
+		for i, transfer := range transfers {
+			e := expected[i]
+			if transfer.ID != e.ID || transfer.Latitude != e.Latitude || transfer.Longitude != e.Longitude {
+				t.Errorf("%s: got transfer point %d %+v, expected %+v", name, i, transfer, e)
+			}
+		}
+	}
+	check("crossing", []shuttletracker.Stop{
+		{ID: 2, Latitude: 42.740, Longitude: -73.68},
+		{ID: NoStop, Latitude: 42.730, Longitude: -73.68},
+	})
+
+	// geometry is cached until a route's version changes
+	b.Points = []shuttletracker.Point{
+		{Latitude: 42.75, Longitude: -73.69},
+		{Latitude: 42.75, Longitude: -73.67},
+	}
+	check("cached", []shuttletracker.Stop{
+		{ID: 2, Latitude: 42.740, Longitude: -73.68},
+		{ID: NoStop, Latitude: 42.730, Longitude: -73.68},
+	})
+	b.Version++
+	check("moved", []shuttletracker.Stop{
+		{ID: 2, Latitude: 42.740, Longitude: -73.68},
+	})
+}
@@ -0,0 +1,51 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/wtg/shuttletracker"
+)
+
+// defaultTripIdleGap is used when Config.TripIdleGap is empty.
+const defaultTripIdleGap = time.Minute * 10
+
+// trip is the run of Locations that a vehicle is currently adding to.
+type trip struct {
+	id      int64
+	routeID int64
+	last    time.Time
+}
+
+// assignTrip returns the ID of the trip that a vehicle's new Location belongs to. A new trip starts
+// when the vehicle joins a route, changes routes, or hasn't reported for longer than the idle gap.
+// Locations that aren't on a route don't belong to a trip. Trips are tracked in memory, so every
+// vehicle starts a new trip when the Updater is restarted.
+func (u *Updater) assignTrip(vehicleID int64, location *shuttletracker.Location) (*int64, error) {
+	u.tripMutex.Lock()
+	defer u.tripMutex.Unlock()
+
+	if location.RouteID == nil {
+		delete(u.trips, vehicleID)
+		return nil, nil
+	}
+
+	u.mutex.Lock()
+	gap := u.tripIdleGap
+	u.mutex.Unlock()
+
+	current, ok := u.trips[vehicleID]
+	if !ok || current.routeID != *location.RouteID || location.Time.Sub(current.last) > gap {
+		id, err := u.ms.NewTripID()
+		if err != nil {
+			return nil, err
+		}
+		current = &trip{id: id, routeID: *location.RouteID, last: location.Time}
+		u.trips[vehicleID] = current
+	}
+	// records that arrive out of order join the current trip without moving it back in time
+	if location.Time.After(current.last) {
+		current.last = location.Time
+	}
+	id := current.id
+	return &id, nil
+}
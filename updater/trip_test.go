@@ -0,0 +1,68 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestAssignTrip(t *testing.T) {
+	ms := &mock.ModelService{}
+	ms.LocationService.On("NewTripID").Return(int64(1), nil).Once()
+	ms.LocationService.On("NewTripID").Return(int64(2), nil).Once()
+	ms.LocationService.On("NewTripID").Return(int64(3), nil).Once()
+	u, err := New(Config{UpdateInterval: "10s", TripIdleGap: "5m"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	west, east := int64(1), int64(2)
+	start := time.Date(2018, time.April, 16, 8, 0, 0, 0, time.UTC)
+	cases := []struct {
+		routeID *int64
+		time    time.Time
+		tripID  int64
+	}{
+		{&west, start, 1},
+		{&west, start.Add(time.Minute), 1},
+		// a late record stays on the current trip
+		{&west, start.Add(time.Second * 30), 1},
+		// changing routes starts a new trip
+		{&east, start.Add(time.Minute * 2), 2},
+		// so does a long idle gap
+		{&east, start.Add(time.Minute * 8), 3},
+		// off-route Locations aren't on a trip
+		{nil, start.Add(time.Minute * 9), 0},
+	}
+	for i, c := range cases {
+		tripID, err := u.assignTrip(1, &shuttletracker.Location{RouteID: c.routeID, Time: c.time})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if c.tripID == 0 {
+			if tripID != nil {
+				t.Errorf("got trip %d for Location %d, expected none", *tripID, i)
+			}
+			continue
+		}
+		if tripID == nil || *tripID != c.tripID {
+			t.Errorf("got trip %v for Location %d, expected %d", tripID, i, c.tripID)
+		}
+	}
+	ms.LocationService.AssertNumberOfCalls(t, "NewTripID", 3)
+}
+
+func TestParseTripIdleGap(t *testing.T) {
+	parsed, err := parseConfig(Config{UpdateInterval: "10s"})
+	if err != nil || parsed.tripIdleGap != defaultTripIdleGap {
+		t.Errorf("got %+v and error %v, expected the default idle gap", parsed, err)
+	}
+	for _, value := range []string{"soon", "-1m", "0s"} {
+		_, err = parseConfig(Config{UpdateInterval: "10s", TripIdleGap: value})
+		if err == nil {
+			t.Errorf("expected an error for trip idle gap %q", value)
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package updater
 
 import (
+	"fmt"
 	"io/ioutil"
 	"math"
 	"net/http"
+	"net/url"
 	"regexp"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -27,92 +27,455 @@ type DataFeedResponse struct {
 type Updater struct {
 	cfg                  Config
 	updateInterval       time.Duration
-	dataRegexp           *regexp.Regexp
+	fieldRegexp          *regexp.Regexp
+	fieldNames           map[string]string
 	ms                   shuttletracker.ModelService
 	mutex                *sync.Mutex
+	cycleMutex           *sync.RWMutex
 	lastDataFeedResponse *DataFeedResponse
+	feedStats            FeedStats
+	feedOutcomes         map[FeedOutcome]int
+	fetchHistory         []fetchOutcome
+	feedDownUntil        time.Time
+	paused               bool
+	guessDebug           map[int64]*RouteGuessDebug
+	missingVehicles      map[int64]*MissingVehicle
+	duplicateTrackers    []string
+	transferCache        map[[2]int64]transferGeometry
+	previousRoutes       map[int64]int64
+	intervalChanges      chan time.Duration
+	client               *http.Client
+	providedClient       *http.Client
+	feedURLProvider      FeedURLProvider
+	feedArchive          *FeedArchive
+	feedLocation         *time.Location
+	campusLocation       *time.Location
+	maxClockSkew         time.Duration
+	clockSkew            map[string]time.Duration
+	tripIdleGap          time.Duration
+	locationFreshness    time.Duration
+	autoDisableAfter     time.Duration
+	tripMutex            *sync.Mutex
+	trips                map[int64]*trip
+	locationSubscribers  map[*locationSubscriber]bool
 }
 
 type Config struct {
 	DataFeed       string
 	UpdateInterval string
+
+	// FeedProxyURL is the proxy that data feed requests are sent through, including any redirects
+	// that the data feed responds with. Credentials may be included in the URL. If it is empty, the
+	// HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables are used instead.
+	FeedProxyURL string
+
+	// FeedTimezone is the IANA name of the time zone that data feed timestamps are in. Empty means UTC.
+	FeedTimezone string
+
+	// MaxClockSkew is the largest difference between a tracker's reported time and the server's
+	// clock that is tolerated before a warning is logged. Empty disables the warning.
+	MaxClockSkew string
+
+	// CampusTimezone is the IANA name of the time zone that the shuttles operate in, which
+	// determines where days begin and end for reports. Empty means UTC.
+	CampusTimezone string
+
+	// TrackerAllowlist is the set of tracker IDs whose records are processed. Records from other
+	// trackers are skipped. An empty list processes every record.
+	TrackerAllowlist []string
+
+	// DisablePrune stops the Updater from deleting old Locations after each update, for deployments
+	// that manage retention in the database itself.
+	DisablePrune bool
+
+	// TripIdleGap is how long a vehicle may go without reporting before its next Location starts a
+	// new trip. Empty uses the default of 10m.
+	TripIdleGap string
+
+	// LocationFreshness is how recently a vehicle must have reported for its latest Location to be
+	// treated as its current position. Empty uses the default of 5m.
+	LocationFreshness string
+
+	// AutoDisableAfter is how long an enabled vehicle may go without reporting, or without being
+	// modified, before it is disabled automatically, e.g. "720h" for decommissioned shuttles. Empty
+	// disables this.
+	AutoDisableAfter string
+
+	// PersistCurrentRoute enables storing each route guess as the vehicle's current route so that it
+	// can be looked up without reading the vehicle's latest Location.
+	PersistCurrentRoute bool
+
+	// FieldNames maps the keys used in data feed records to the fields the parser reads: id, lat, lng,
+	// heading, speed, lock, time, date, and status. Every one of id, lat, lng, time, and date must have
+	// a key. Empty uses the iTRAK keys, e.g. "lon" for lng and "spd" for speed. It is a list rather
+	// than a map because the config loader lowercases map keys, and record keys like "Vehicle ID" are
+	// case-sensitive.
+	FieldNames []FieldName
+
+	// RouteGuessDebug enables recording the per-route distances computed for each route guess.
+	RouteGuessDebug bool
+
+	// CoordinatePrecision is the number of decimal places that latitudes and longitudes are rounded
+	// to before being stored. Zero disables rounding.
+	CoordinatePrecision int
+
+	// FeedArchiveDir is the directory that raw data feed response bodies are archived in so that they
+	// can be replayed later. Empty disables archiving.
+	FeedArchiveDir string
+
+	// FeedArchiveCount is the number of bodies kept in FeedArchiveDir before the oldest are deleted.
+	// Zero uses the default of 100.
+	FeedArchiveCount int
+
+	// HeadingWeight is how much a vehicle's heading counts in route guesses, which distinguishes
+	// parallel routes that run in opposite directions. A moving sample adds up to this much to a
+	// route's average distance, the most when its heading is opposite to the route's direction at
+	// the nearest point. Samples slower than 2 mph don't have a reliable heading and only count
+	// their position. Zero disables the heading term.
+	HeadingWeight float64
+
+	// ComputeHeading fills in the heading of records that report zero or an invalid heading. Moving
+	// vehicles get the bearing from their previous Location, and stationary vehicles keep their
+	// previous heading since GPS noise would otherwise point them anywhere.
+	ComputeHeading bool
+
+	// SlowFeedFraction is the fraction of the update interval that a data feed request may take
+	// before a warning is logged. Zero uses the default of 0.5.
+	SlowFeedFraction float64
+
+	// OnRouteThreshold is the average distance from a route that a vehicle must be within before it
+	// is considered to have joined that route. Zero uses the default of 5.
+	OnRouteThreshold float64
+
+	// OffRouteThreshold is the average distance from its current route that a vehicle must exceed
+	// before it is considered to have left that route. It must be at least OnRouteThreshold so that
+	// vehicles near the boundary don't flap between routes. Zero uses the default of 10.
+	OffRouteThreshold float64
+
+	// DeriveSpeedDistance is how far in meters a vehicle must have moved since its previous Location
+	// for a record that reports a speed of zero to get its speed from the distance and time between
+	// them instead, since a stalled speed sensor reports zero while the vehicle moves. Such Locations
+	// are marked SpeedDerived. Zero disables this.
+	DeriveSpeedDistance float64
+
+	// IdleSpeed is the speed in miles per hour below which a reporting vehicle is considered idle by
+	// VehicleIdleTime. Zero uses the default of 2, the speed above which a vehicle is in service.
+	IdleSpeed float64
+
+	// KeepPartialRecords passes an incomplete record at the end of the data feed, which is left when
+	// the connection is cut partway through the body, on to the parser like any other record so that
+	// it is logged as a parse error. By default it is discarded with a debug log instead.
+	KeepPartialRecords bool
 }
 
-// New creates an Updater.
-func New(cfg Config, ms shuttletracker.ModelService) (*Updater, error) {
+const (
+	defaultOnRouteThreshold  = 5
+	defaultOffRouteThreshold = 10
+)
+
+// New creates an Updater. Unless an Option provides one, it fetches the data feed with a client that
+// times out after five seconds.
+func New(cfg Config, ms shuttletracker.ModelService, opts ...Option) (*Updater, error) {
 	// Create Updater object
 	updater := &Updater{
-		cfg:   cfg,
-		ms:    ms,
-		mutex: &sync.Mutex{},
+		cfg:                 cfg,
+		ms:                  ms,
+		mutex:               &sync.Mutex{},
+		cycleMutex:          &sync.RWMutex{},
+		guessDebug:          map[int64]*RouteGuessDebug{},
+		missingVehicles:     map[int64]*MissingVehicle{},
+		previousRoutes:      map[int64]int64{},
+		transferCache:       map[[2]int64]transferGeometry{},
+		clockSkew:           map[string]time.Duration{},
+		feedOutcomes:        map[FeedOutcome]int{},
+		fetchHistory:        []fetchOutcome{},
+		tripMutex:           &sync.Mutex{},
+		trips:               map[int64]*trip{},
+		locationSubscribers: map[*locationSubscriber]bool{},
+		intervalChanges:     make(chan time.Duration, 1),
+	}
+
+	parsed, err := parseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	updater.updateInterval = parsed.interval
+	updater.fieldRegexp = parsed.fieldRegexp
+	updater.tripIdleGap = parsed.tripIdleGap
+	updater.locationFreshness = parsed.locationFreshness
+	updater.autoDisableAfter = parsed.autoDisableAfter
+	updater.fieldNames = fieldMap(fieldNames(cfg))
+
+	for _, opt := range opts {
+		opt(updater)
+	}
+
+	client, err := newFeedClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	updater.client = client
+	if updater.providedClient != nil {
+		updater.client = updater.providedClient
+	}
+
+	feedArchive, err := newFeedArchive(cfg)
+	if err != nil {
+		return nil, err
+	}
+	updater.feedArchive = feedArchive
+
+	feedLocation, maxClockSkew, err := parseClockConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
+	updater.feedLocation = feedLocation
+	updater.maxClockSkew = maxClockSkew
 
+	campusLocation, err := loadLocation(cfg.CampusTimezone)
+	if err != nil {
+		return nil, err
+	}
+	updater.campusLocation = campusLocation
+
+	return updater, nil
+}
+
+// parsedConfig holds the values that parseConfig derives from a Config.
+type parsedConfig struct {
+	interval          time.Duration
+	fieldRegexp       *regexp.Regexp
+	tripIdleGap       time.Duration
+	locationFreshness time.Duration
+	autoDisableAfter  time.Duration
+}
+
+// parseConfig validates a Config and derives the values that New and Reload install from it.
+func parseConfig(cfg Config) (*parsedConfig, error) {
 	// err gets filled and returns "nil" if ParseDuration returns an error
 	interval, err := time.ParseDuration(cfg.UpdateInterval)
 	if err != nil {
 		return nil, err
 	}
-	updater.updateInterval = interval
+	if interval <= 0 {
+		return nil, fmt.Errorf("update interval must be positive, got %s", interval)
+	}
+
+	_, err = url.Parse(cfg.DataFeed)
+	if err != nil {
+		return nil, err
+	}
 
-	// Match each API field with any number (+)
-	//   of the previous expressions (\d digit, \. escaped period, - negative number)
-	//   Specify named capturing groups to store each field from data feed
-	updater.dataRegexp = regexp.MustCompile(`(?P<id>Vehicle ID:([\d\.]+)) (?P<lat>lat:([\d\.-]+)) (?P<lng>lon:([\d\.-]+)) (?P<heading>dir:([\d\.-]+)) (?P<speed>spd:([\d\.-]+)) (?P<lock>lck:([\d\.-]+)) (?P<time>time:([\d]+)) (?P<date>date:([\d]+)) (?P<status>trig:([\d]+))`)
+	onThreshold, offThreshold := routeThresholds(cfg)
+	if onThreshold < 0 || offThreshold < onThreshold {
+		return nil, fmt.Errorf("off-route threshold (%v) must be at least the on-route threshold (%v)", offThreshold, onThreshold)
+	}
 
-	return updater, nil
+	if cfg.HeadingWeight < 0 {
+		return nil, fmt.Errorf("heading weight must not be negative, got %v", cfg.HeadingWeight)
+	}
+	if cfg.DeriveSpeedDistance < 0 {
+		return nil, fmt.Errorf("derive speed distance must not be negative, got %v", cfg.DeriveSpeedDistance)
+	}
+	if cfg.IdleSpeed < 0 {
+		return nil, fmt.Errorf("idle speed must not be negative, got %v", cfg.IdleSpeed)
+	}
+	if cfg.SlowFeedFraction < 0 {
+		return nil, fmt.Errorf("slow feed fraction must not be negative, got %v", cfg.SlowFeedFraction)
+	}
+
+	fieldRegexp, err := compileFieldRegexp(fieldNames(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	tripIdleGap, err := parsePositiveDuration("trip idle gap", cfg.TripIdleGap, defaultTripIdleGap)
+	if err != nil {
+		return nil, err
+	}
+	locationFreshness, err := parsePositiveDuration("location freshness", cfg.LocationFreshness, defaultLocationFreshness)
+	if err != nil {
+		return nil, err
+	}
+	// vehicles are never disabled if no threshold is set
+	autoDisableAfter, err := parsePositiveDuration("auto-disable threshold", cfg.AutoDisableAfter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsedConfig{
+		interval:          interval,
+		fieldRegexp:       fieldRegexp,
+		tripIdleGap:       tripIdleGap,
+		locationFreshness: locationFreshness,
+		autoDisableAfter:  autoDisableAfter,
+	}, nil
+}
+
+// parsePositiveDuration parses the optional duration value of the setting called name. It returns
+// def if value is empty and an error if value isn't a positive duration.
+func parsePositiveDuration(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive, got %s", name, d)
+	}
+	return d, nil
 }
 
 func NewConfig(v *viper.Viper) *Config {
 	// Create Config object
 	cfg := &Config{
-		UpdateInterval: "10s",
-		DataFeed:       "https://shuttles.rpi.edu/datafeed",
+		UpdateInterval:    "10s",
+		DataFeed:          "https://shuttles.rpi.edu/datafeed",
+		MaxClockSkew:      "2m",
+		CampusTimezone:    "America/New_York",
+		TripIdleGap:       "10m",
+		LocationFreshness: "5m",
 	}
 	v.SetDefault("updater.updateinterval", cfg.UpdateInterval)
 	v.SetDefault("updater.datafeed", cfg.DataFeed)
+	v.SetDefault("updater.feedproxyurl", cfg.FeedProxyURL)
+	v.SetDefault("updater.feedtimezone", cfg.FeedTimezone)
+	v.SetDefault("updater.maxclockskew", cfg.MaxClockSkew)
+	v.SetDefault("updater.campustimezone", cfg.CampusTimezone)
+	v.SetDefault("updater.trackerallowlist", cfg.TrackerAllowlist)
+	v.SetDefault("updater.disableprune", cfg.DisablePrune)
+	v.SetDefault("updater.tripidlegap", cfg.TripIdleGap)
+	v.SetDefault("updater.locationfreshness", cfg.LocationFreshness)
+	v.SetDefault("updater.autodisableafter", cfg.AutoDisableAfter)
+	v.SetDefault("updater.persistcurrentroute", cfg.PersistCurrentRoute)
+	v.SetDefault("updater.fieldnames", cfg.FieldNames)
+	v.SetDefault("updater.routeguessdebug", cfg.RouteGuessDebug)
+	v.SetDefault("updater.coordinateprecision", cfg.CoordinatePrecision)
+	v.SetDefault("updater.feedarchivedir", cfg.FeedArchiveDir)
+	v.SetDefault("updater.feedarchivecount", cfg.FeedArchiveCount)
+	v.SetDefault("updater.headingweight", cfg.HeadingWeight)
+	v.SetDefault("updater.computeheading", cfg.ComputeHeading)
+	v.SetDefault("updater.slowfeedfraction", cfg.SlowFeedFraction)
+	v.SetDefault("updater.onroutethreshold", cfg.OnRouteThreshold)
+	v.SetDefault("updater.offroutethreshold", cfg.OffRouteThreshold)
+	v.SetDefault("updater.derivespeeddistance", cfg.DeriveSpeedDistance)
+	v.SetDefault("updater.idlespeed", cfg.IdleSpeed)
+	v.SetDefault("updater.keeppartialrecords", cfg.KeepPartialRecords)
 	return cfg
 }
 
 // Run updater forever.
 func (u *Updater) Run() {
 	log.Debug("Updater started.")
-	ticker := time.Tick(u.updateInterval)
+	u.mutex.Lock()
+	ticker := time.NewTicker(u.updateInterval)
+	u.mutex.Unlock()
 
-	// Do one initial update.
-	u.update()
+	autoDisableTicker := time.NewTicker(autoDisableInterval)
 
-	// Call update() every updateInterval.
-	for range ticker {
-		u.update()
+	// Do one initial update.
+	u.updateUnlessPaused()
+	u.autoDisableUnlessPaused()
+
+	// Call update() every updateInterval, restarting the ticker if the interval is reloaded.
+	for {
+		select {
+		case <-ticker.C:
+			u.updateUnlessPaused()
+		case <-autoDisableTicker.C:
+			u.autoDisableUnlessPaused()
+		case interval := <-u.intervalChanges:
+			ticker.Stop()
+			ticker = time.NewTicker(interval)
+			log.Infof("Update interval changed to %s.", interval)
+		}
 	}
 }
 
 // Send a request to iTrak API, get updated shuttle info,
 // store updated records in the database, and remove old records.
 func (u *Updater) update() {
+	cfg := u.config()
+	start := time.Now()
+
 	// Make request to iTrak data feed
-	client := http.Client{Timeout: time.Second * 5}
+	u.mutex.Lock()
+	client := u.client
+	interval := u.updateInterval
+	feedArchive := u.feedArchive
+	u.mutex.Unlock()
 	// HTTP GET request from https://shuttles.rpi.edu/datafeed
-	resp, err := client.Get(u.cfg.DataFeed)
+	requested := time.Now()
+	feedURL, err := u.feedURL(cfg)
+	if err != nil {
+		u.recordFetch(requested, false, time.Since(requested))
+		if u.feedDownAcknowledged() {
+			log.WithError(err).Debug("Could not get data feed URL; the outage is acknowledged.")
+		} else {
+			log.WithError(err).Error("Could not get data feed URL.")
+		}
+		return
+	}
+	req, err := conditionalRequest(feedURL, u.GetLastResponse())
+	if err != nil {
+		log.WithError(err).Error("Could not create data feed request.")
+		return
+	}
+	requested = time.Now()
+	resp, err := client.Do(req)
 	if err != nil {
-		log.WithError(err).Error("Could not get data feed.")
+		u.recordFetch(requested, false, time.Since(requested))
+		if u.feedDownAcknowledged() {
+			log.WithError(err).Debug("Could not get data feed; the outage is acknowledged.")
+		} else {
+			log.WithError(err).Error("Could not get data feed.")
+		}
+		return
+	}
+	fetched := time.Now()
+	u.recordFetch(requested, resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusNotModified, fetched.Sub(requested))
+	// a slow feed eats into the next cycle, so warn before it gets slow enough to fail
+	if latency := fetched.Sub(requested); latency > slowFeedThreshold(cfg, interval) {
+		log.WithFields(log.Fields{"url": redactedURL(feedURL), "latency": latency}).Warn("Data feed is slow to respond.")
+	}
+
+	// Nothing has changed since the last response, which is kept as is.
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		log.Debug("Data feed not modified.")
 		return
 	}
 
 	// Prints errors in the case that the GET request doesn't give StatusOK
 	if resp.StatusCode != http.StatusOK {
-		log.Errorf("data feed status code %d", resp.StatusCode)
+		if u.feedDownAcknowledged() {
+			log.Debugf("data feed status code %d; the outage is acknowledged", resp.StatusCode)
+		} else {
+			log.Errorf("data feed status code %d", resp.StatusCode)
+		}
 		return
 	}
 
 	// Read response body content
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		log.WithError(err).Error("Could not read data feed.")
+		if u.feedDownAcknowledged() {
+			log.WithError(err).Debug("Could not read data feed; the outage is acknowledged.")
+		} else {
+			log.WithError(err).Error("Could not read data feed.")
+		}
 		return
 	}
 	resp.Body.Close()
 
+	if feedArchive != nil {
+		if err := feedArchive.Write(body, fetched); err != nil {
+			log.WithError(err).Error("Unable to archive data feed response.")
+		}
+	}
+
 	// Create a DataFeedResponseobject in dfresp
 	dfresp := &DataFeedResponse{
 		Body:       body,
@@ -122,27 +485,69 @@ func (u *Updater) update() {
 	// Sets the variable lastDataFeedResponse to dfresp in a protected manner
 	u.setLastResponse(dfresp)
 
-	delim := "eof"
-	// split the body of response by delimiter
-	vehiclesData := strings.Split(string(body), delim)
-	vehiclesData = vehiclesData[:len(vehiclesData)-1] // last element is EOF
+	vehiclesData, outcome := u.classifyFeed(body)
+	u.countFeedOutcome(outcome)
+	switch outcome {
+	case FeedEmpty:
+		log.Info("Data feed contains no records; no vehicles are reporting.")
+	case FeedMalformed:
+		// don't treat every vehicle as missing when the feed itself is broken
+		if u.feedDownAcknowledged() {
+			log.WithField("length", len(body)).Debug("Data feed body contains no records; the outage is acknowledged.")
+		} else {
+			log.WithField("length", len(body)).Error("Data feed body contains no records.")
+		}
+		return
+	}
+
+	vehiclesData = u.dropDuplicateTrackers(vehiclesData)
 
-	// TODO: Figure out if this handles == 1 vehicle correctly or always assumes > 1.
-	if len(vehiclesData) <= 1 {
-		log.Warnf("Found no vehicles delineated by '%s'.", delim)
+	// every record in this cycle is matched against the same routes, fetched once
+	routes := []*shuttletracker.Route{}
+	if len(vehiclesData) > 0 {
+		routes, err = u.ms.Routes()
+		if err != nil {
+			log.WithError(err).Error("Unable to fetch routes.")
+			return
+		}
 	}
 
+	// hold off MapState readers until every record from this cycle has been stored
+	u.cycleMutex.Lock()
 	wg := sync.WaitGroup{}
+	// each goroutine only writes its own index, so no locking is needed
+	timings := make([]recordTiming, len(vehiclesData))
 	// for parsed data, update each vehicle
-	for _, vehicleData := range vehiclesData {
+	for i, vehicleData := range vehiclesData {
 		wg.Add(1)
-		go func(vehicleData string) {
-			u.handleVehicleData(vehicleData)
+		go func(i int, vehicleData string) {
+			start := time.Now()
+			trackerID, stored := u.handleVehicleData(vehicleData, fetched, routes)
+			timings[i] = recordTiming{trackerID: trackerID, stored: stored, duration: time.Since(start)}
 			wg.Done()
-		}(vehicleData)
+		}(i, vehicleData)
 	}
 	wg.Wait()
-	log.Debugf("Updated vehicles.")
+	u.cycleMutex.Unlock()
+	u.setFeedStats(newFeedStats(timings))
+	seen := map[string]bool{}
+	stored := 0
+	for _, timing := range timings {
+		seen[timing.trackerID] = true
+		if timing.stored {
+			stored++
+		}
+	}
+	u.trackMissingVehicles(seen)
+	log.WithFields(log.Fields{
+		"records":  len(vehiclesData),
+		"stored":   stored,
+		"duration": time.Since(start),
+	}).Info("Updated vehicles.")
+
+	if cfg.DisablePrune {
+		return
+	}
 
 	// Prune updates older than one month
 	deleted, err := u.ms.DeleteLocationsBefore(time.Now().AddDate(0, -1, 0))
@@ -155,19 +560,23 @@ func (u *Updater) update() {
 	}
 }
 
-// nolint: gocyclo
-func (u *Updater) handleVehicleData(vehicleData string) {
-	match := u.dataRegexp.FindAllStringSubmatch(vehicleData, -1)[0]
-	// Store named capturing group and matching expression as a key value pair
-	result := map[string]string{}
-	for i, item := range match {
-		result[u.dataRegexp.SubexpNames()[i]] = item
+// handleVehicleData stores a single data feed record fetched at the provided time and returns the
+// tracker ID it belonged to, or an empty string if the record could not be parsed, along with whether
+// a new Location was stored. routes are the routes that the record's vehicle may be on.
+func (u *Updater) handleVehicleData(vehicleData string, fetched time.Time, routes []*shuttletracker.Route) (itrakID string, stored bool) {
+	itrakID, update, err := u.parseRecord(vehicleData)
+	if err != nil {
+		// the error includes the record so that the failure can be reproduced
+		log.WithError(err).WithField("tracker_id", itrakID).Warn("Skipping data feed record.")
+		return
+	}
+	// skip trackers we aren't interested in before spending any queries on them
+	if !trackerAllowed(u.config().TrackerAllowlist, itrakID) {
+		return
 	}
 
 	// Create new vehicle update & insert update into database
 
-	// Parses out the string "Vehicle ID:" so itrakID is just the ID itself
-	itrakID := strings.Replace(result["id"], "Vehicle ID:", "", -1)
 	vehicle, err := u.ms.VehicleWithTrackerID(itrakID)
 	// Handles error checking in the case vehicles are unknown
 	if err == shuttletracker.ErrVehicleNotFound {
@@ -177,76 +586,91 @@ func (u *Updater) handleVehicleData(vehicleData string) {
 		log.WithError(err).Error("Unable to fetch vehicle.")
 		return
 	}
-
-	// determine if this is a new update from itrak by comparing timestamps
-	newTime, err := itrakTimeDate(result["time"], result["date"])
-	if err != nil {
-		log.WithError(err).Error("unable to parse iTRAK time and date")
+	if !u.inService(vehicle, update.Time) {
+		log.Debugf("%s is outside of its schedule; not updating.", vehicle.Name)
 		return
 	}
 
-	lastUpdate, err := u.ms.LatestLocation(vehicle.ID)
-	if err != nil && err != shuttletracker.ErrLocationNotFound {
-		log.WithError(err).Error("unable to retrieve last update")
+	// determine if this is a new update from itrak by checking whether its timestamp has been stored
+	// before. This also handles records that arrive out of order.
+	exists, err := u.ms.ExistsLocation(itrakID, update.Time)
+	if err != nil {
+		log.WithError(err).Error("unable to check for existing update")
 		return
 	}
-	if err != shuttletracker.ErrLocationNotFound && newTime.Equal(lastUpdate.Time) {
+	if exists {
 		// Timestamp is not new; don't store update.
 		return
 	}
-	log.Debugf("Updating %s.", vehicle.Name)
+	u.recordClockSkew(itrakID, fetched.Sub(update.Time))
+	// a derived speed lets a heading be computed for a vehicle whose speed sensor is stuck
+	cfg := u.config()
+	previous := u.previousLocation(vehicle, update, cfg)
+	if cfg.DeriveSpeedDistance > 0 {
+		deriveSpeed(previous, update, cfg.DeriveSpeedDistance)
+	}
+	if cfg.ComputeHeading {
+		fillHeading(previous, update)
+	}
+	log.WithFields(log.Fields{"vehicle": vehicle.Name, "tracker_id": itrakID}).Debug("Updating vehicle.")
 
 	// vehicle found and no error
-	route, err := u.GuessRouteForVehicle(vehicle)
+	guess, err := u.guessRoute(vehicle, routes, u.previousRoute(vehicle.ID))
 	if err != nil {
 		log.WithError(err).Error("Unable to guess route for vehicle.")
 		return
 	}
-
-	// Sets latitude and longitude by removing the strings "lat:", "lon" and
-	// "dir" from the numbers themselves
-	latitude, err := strconv.ParseFloat(strings.Replace(result["lat"], "lat:", "", -1), 64)
+	u.commitRouteGuess(vehicle, guess)
+	route, err := u.guessedRoute(vehicle, guess)
 	if err != nil {
-		log.WithError(err).Error("unable to parse latitude as float")
+		log.WithError(err).Error("Unable to guess route for vehicle.")
 		return
 	}
-	longitude, err := strconv.ParseFloat(strings.Replace(result["lng"], "lon:", "", -1), 64)
-	if err != nil {
-		log.WithError(err).Error("unable to parse longitude as float")
-		return
+	if route != nil {
+		update.RouteID = &route.ID
+		update.RouteVersion = &route.Version
 	}
-	heading, err := strconv.ParseFloat(strings.Replace(result["heading"], "dir:", "", -1), 64)
+	tripID, err := u.assignTrip(vehicle.ID, update)
 	if err != nil {
-		log.WithError(err).Error("unable to parse heading as float")
-		return
+		// the Location is still worth storing without a trip
+		log.WithError(err).Error("Unable to assign trip.")
 	}
-	// convert KPH to MPH
-	speedKMH, err := strconv.ParseFloat(strings.Replace(result["speed"], "spd:", "", -1), 64)
-	if err != nil {
-		log.Error(err)
+	update.TripID = tripID
+
+	// Creates the location if err isn't nil: in line command
+	if err := u.ms.CreateLocation(update); err != nil {
+		log.WithError(err).Errorf("could not create location")
 		return
 	}
-	speedMPH := kphToMPH(speedKMH)
-
-	trackerID := strings.Replace(result["id"], "Vehicle ID:", "", -1)
+	stored = true
+	u.publishLocation(update)
+	return
+}
 
-	// Create a new shuttletracker.Location object in update
-	update := &shuttletracker.Location{
-		TrackerID: trackerID,
-		Latitude:  latitude,
-		Longitude: longitude,
-		Heading:   heading,
-		Speed:     speedMPH,
-		Time:      newTime,
+// roundCoordinate rounds a latitude or longitude to precision decimal places.
+// A precision of zero or less leaves the value unchanged.
+func roundCoordinate(value float64, precision int) float64 {
+	if precision <= 0 {
+		return value
 	}
-	if route != nil {
-		update.RouteID = &route.ID
+	scale := math.Pow(10, float64(precision))
+	if value < 0 {
+		return -math.Floor(-value*scale+0.5) / scale
 	}
+	return math.Floor(value*scale+0.5) / scale
+}
 
-	// Creates the location if err isn't nil: in line command
-	if err := u.ms.CreateLocation(update); err != nil {
-		log.WithError(err).Errorf("could not create location")
+// trackerAllowed returns whether records from trackerID should be processed given an allowlist.
+func trackerAllowed(allowlist []string, trackerID string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+	for _, allowed := range allowlist {
+		if allowed == trackerID {
+			return true
+		}
 	}
+	return false
 }
 
 // Convert kmh to mph
@@ -255,15 +679,50 @@ func kphToMPH(kmh float64) float64 {
 }
 
 // GuessRouteForVehicle returns a guess at what route the vehicle is on.
-// It may return an empty route if it does not believe a vehicle is on any route.
-// nolint: gocyclo
+// It may return an empty route if it does not believe a vehicle is on any route. The guess starts
+// from the route that the update cycle last guessed, but doesn't change it; only the update cycle
+// records its guesses.
 func (u *Updater) GuessRouteForVehicle(vehicle *shuttletracker.Vehicle) (route *shuttletracker.Route, err error) {
 	// ms.Routes() just grabs the routes in a pointer array format
 	routes, err := u.ms.Routes()
 	if err != nil {
 		return nil, err
 	}
+	guess, err := u.guessRoute(vehicle, routes, u.previousRoute(vehicle.ID))
+	if err != nil {
+		return nil, err
+	}
+	return u.guessedRoute(vehicle, guess)
+}
+
+// routeSwitchMargin is how much lower another route's average distance must be than that of the
+// route a vehicle was previously on for the vehicle to switch to it while still within the off-route
+// threshold. Each far sample adds 50 divided by the number of samples, so this is one far sample in
+// twenty.
+const routeSwitchMargin = 2.5
+
+// routeGuess is the outcome of guessing a vehicle's route from its recent Locations.
+type routeGuess struct {
+	// routeID is the guessed route, or 0 if the vehicle is not on a route.
+	routeID int64
 
+	// distance is the vehicle's average distance from the guessed route, or from the nearest route
+	// if it isn't on one.
+	distance float64
+
+	// distances maps route IDs to the vehicle's average distance from that route.
+	distances map[int64]float64
+
+	// updates is the number of recent Locations the guess was made from.
+	updates int
+}
+
+// guessRoute guesses the route that a vehicle is on from the candidate routes, which are fetched once
+// per update cycle so that every vehicle sees the same routes. previousRouteID is the route the vehicle
+// was last guessed to be on, or 0. A nil guess means that there were too few recent Locations to make
+// one. Nothing about the Updater's state is changed.
+// nolint: gocyclo
+func (u *Updater) guessRoute(vehicle *shuttletracker.Vehicle, routes []*shuttletracker.Route, previousRouteID int64) (*routeGuess, error) {
 	// Create new dynamic array routeDistances, and the loop initializes all to 0
 	// routeDistances will hold the route distances, and the min is the approximate
 	routeDistances := make(map[int64]float64)
@@ -272,12 +731,17 @@ func (u *Updater) GuessRouteForVehicle(vehicle *shuttletracker.Vehicle) (route *
 	}
 
 	updates, err := u.ms.LocationsSince(vehicle.ID, time.Now().Add(time.Minute*-15))
+	if err != nil {
+		return nil, err
+	}
 	if len(updates) < 5 {
 		// Can't make a guess with fewer than 5 updates.
 		log.Debugf("%v has too few recent updates (%d) to guess route.", vehicle.Name, len(updates))
-		return
+		return nil, nil
 	}
 
+	headingWeight := u.config().HeadingWeight
+
 	// Uses updates to approximate route
 	for _, update := range updates {
 		for _, route := range routes {
@@ -285,17 +749,27 @@ func (u *Updater) GuessRouteForVehicle(vehicle *shuttletracker.Vehicle) (route *
 				routeDistances[route.ID] += math.Inf(0)
 			}
 			nearestDistance := math.Inf(0)
-			// Calculate distance with basic distance formula, and update nearest
-			for _, point := range route.Points {
-				distance := math.Sqrt(math.Pow(update.Latitude-point.Latitude, 2) +
-					math.Pow(update.Longitude-point.Longitude, 2))
-				if distance < nearestDistance {
-					nearestDistance = distance
+			var nearestPolyline []shuttletracker.Point
+			nearest := -1
+			// Calculate distance with basic distance formula, and update nearest across the route and
+			// its branches
+			for _, polyline := range route.Polylines() {
+				for i, point := range polyline {
+					distance := math.Sqrt(math.Pow(update.Latitude-point.Latitude, 2) +
+						math.Pow(update.Longitude-point.Longitude, 2))
+					if distance < nearestDistance {
+						nearestDistance = distance
+						nearestPolyline = polyline
+						nearest = i
+					}
 				}
 			}
 			if nearestDistance > .003 {
 				nearestDistance += 50
 			}
+			if headingWeight > 0 && update.Speed > movingSpeed {
+				nearestDistance += headingWeight * headingPenalty(update.Heading, nearestPolyline, nearest)
+			}
 			// Append to routeDistances
 			routeDistances[route.ID] += nearestDistance
 		}
@@ -306,34 +780,77 @@ func (u *Updater) GuessRouteForVehicle(vehicle *shuttletracker.Vehicle) (route *
 	minDistance := math.Inf(0)
 	var minRouteID int64
 	for id := range routeDistances {
-		distance := routeDistances[id] / float64(len(updates))
+		routeDistances[id] /= float64(len(updates))
+		distance := routeDistances[id]
 		if distance < minDistance {
 			minDistance = distance
 			minRouteID = id
-			// If more than ~5% of the last 100 samples were far away from a route, say the shuttle is not on a route
-			// This is extremely aggressive and requires a shuttle to be on a route for ~5 minutes before it registers as on the route
-			if minDistance > 5 {
-				minRouteID = 0
-			}
 		}
 	}
 
+	// If too many recent samples were far away from a route, say the shuttle is not on a route.
+	// A vehicle already on a route gets the looser off-route threshold so that it doesn't flicker
+	// off and back on at intersections, unless another route is clearly closer, e.g. after it
+	// switched routes along a shared corridor.
+	onThreshold, offThreshold := routeThresholds(u.config())
+	if distance, ok := routeDistances[previousRouteID]; ok && distance <= offThreshold && minDistance >= distance-routeSwitchMargin {
+		minRouteID = previousRouteID
+		minDistance = distance
+	} else if minDistance > onThreshold {
+		minRouteID = 0
+	}
+	return &routeGuess{routeID: minRouteID, distance: minDistance, distances: routeDistances, updates: len(updates)}, nil
+}
+
+// commitRouteGuess remembers a guess as the route that the vehicle was last guessed to be on, which
+// the next guess starts from, and records it for persistence and debugging if those are enabled. Only
+// the update cycle commits guesses. A nil guess leaves everything as it was.
+func (u *Updater) commitRouteGuess(vehicle *shuttletracker.Vehicle, guess *routeGuess) {
+	if guess == nil {
+		return
+	}
+	cfg := u.config()
+	u.setPreviousRoute(vehicle.ID, guess.routeID)
+	if cfg.PersistCurrentRoute {
+		_, offThreshold := routeThresholds(cfg)
+		u.recordCurrentRoute(vehicle, guess.routeID, guess.distance, offThreshold)
+	}
+	if cfg.RouteGuessDebug {
+		u.recordGuessDebug(&RouteGuessDebug{
+			VehicleID: vehicle.ID,
+			Time:      time.Now(),
+			Updates:   guess.updates,
+			Distances: guess.distances,
+			RouteID:   guess.routeID,
+		})
+	}
+}
+
+// guessedRoute returns the Route that a guess is for, or nil if the vehicle isn't on a route.
+func (u *Updater) guessedRoute(vehicle *shuttletracker.Vehicle, guess *routeGuess) (*shuttletracker.Route, error) {
 	// not on a route
-	if minRouteID == 0 {
-		log.Debugf("%v not on route; distance from nearest: %v", vehicle.Name, minDistance)
+	if guess == nil || guess.routeID == 0 {
+		if guess != nil {
+			log.Debugf("%v not on route; distance from nearest: %v", vehicle.Name, guess.distance)
+		}
 		return nil, nil
 	}
 
 	// Create "route" as the guess and return it
-	route, err = u.ms.Route(minRouteID)
+	route, err := u.ms.Route(guess.routeID)
 	if err != nil {
 		return route, err
 	}
 	log.Debugf("%v on %s route.", vehicle.Name, route.Name)
-	return route, err
+	return route, nil
 }
 
 func itrakTimeDate(itrakTime, itrakDate string) (time.Time, error) {
+	return itrakTimeDateIn(itrakTime, itrakDate, time.UTC)
+}
+
+// itrakTimeDateIn parses an iTRAK time and date that are in the provided location.
+func itrakTimeDateIn(itrakTime, itrakDate string, loc *time.Location) (time.Time, error) {
 	// Add leading zeros to the time value if they're missing. time.Parse expects this.
 	if len(itrakTime) < 11 {
 		builder := itrakTime[:5]
@@ -345,7 +862,7 @@ func itrakTimeDate(itrakTime, itrakDate string) (time.Time, error) {
 	}
 
 	combined := itrakDate + " " + itrakTime
-	return time.Parse("date:01022006 time:150405", combined)
+	return time.ParseInLocation("date:01022006 time:150405", combined, loc)
 }
 
 // Locks and unlocks the mutex in order to avoid errors in synchronization
@@ -1,8 +1,12 @@
 package updater
 
 import (
+	"math"
 	"testing"
 	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
 )
 
 func TestITrakTimeDate(t *testing.T) {
@@ -60,3 +64,76 @@ func TestITrakTimeDate(t *testing.T) {
 		t.Errorf("got %+v, expected %+v", parsed, expected)
 	}
 }
+
+func TestRoundCoordinate(t *testing.T) {
+	type testCase struct {
+		value     float64
+		precision int
+		expected  float64
+	}
+	cases := []testCase{
+		{42.7302846, 0, 42.7302846},
+		{42.7302846, 5, 42.73028},
+		{42.7302856, 5, 42.73029},
+		{-73.6789351, 4, -73.6789},
+		{-73.6789551, 4, -73.679},
+	}
+	for _, c := range cases {
+		rounded := roundCoordinate(c.value, c.precision)
+		if math.Abs(rounded-c.expected) > 1e-9 {
+			t.Errorf("got %v rounding %v to %d places, expected %v", rounded, c.value, c.precision, c.expected)
+		}
+	}
+}
+
+func TestTrackerAllowed(t *testing.T) {
+	if !trackerAllowed(nil, "1234") {
+		t.Error("an empty allowlist should allow every tracker")
+	}
+	allowlist := []string{"1234", "5678"}
+	if !trackerAllowed(allowlist, "5678") {
+		t.Error("tracker 5678 is in the allowlist but was not allowed")
+	}
+	if trackerAllowed(allowlist, "9999") {
+		t.Error("tracker 9999 is not in the allowlist but was allowed")
+	}
+}
+
+func TestGuessRouteBranches(t *testing.T) {
+	// the vehicle is on a branch of the first route, far from either route's main polyline
+	branching := &shuttletracker.Route{
+		ID:      1,
+		Enabled: true,
+		Active:  true,
+		Points:  []shuttletracker.Point{{Latitude: 42.730, Longitude: -73.680}, {Latitude: 42.730, Longitude: -73.670}},
+		Branches: []shuttletracker.RouteBranch{{
+			Name:   "Detour",
+			Points: []shuttletracker.Point{{Latitude: 42.730, Longitude: -73.670}, {Latitude: 42.740, Longitude: -73.670}},
+		}},
+	}
+	other := &shuttletracker.Route{
+		ID:      2,
+		Enabled: true,
+		Active:  true,
+		Points:  []shuttletracker.Point{{Latitude: 42.745, Longitude: -73.690}, {Latitude: 42.745, Longitude: -73.680}},
+	}
+	vehicle := &shuttletracker.Vehicle{ID: 1, Name: "Vehicle 1", Enabled: true}
+	location := &shuttletracker.Location{Latitude: 42.740, Longitude: -73.670}
+
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Routes").Return([]*shuttletracker.Route{branching, other}, nil)
+	ms.RouteService.On("Route", branching.ID).Return(branching, nil)
+	ms.LocationService.On("LocationsSince", int64(1)).Return([]*shuttletracker.Location{location, location, location, location, location}, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	guess, err := u.GuessRouteForVehicle(vehicle)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if guess == nil || guess.ID != branching.ID {
+		t.Errorf("got %+v, expected the route with the branch", guess)
+	}
+}
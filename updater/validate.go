@@ -0,0 +1,58 @@
+package updater
+
+import (
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+// maxPointGap is the distance in meters between consecutive route points above which the points are
+// assumed to be a mistake, such as a mistyped coordinate.
+const maxPointGap = 500.0
+
+// RouteIssueKind describes a problem with a route's geometry.
+type RouteIssueKind string
+
+// RouteIssueKind values.
+const (
+	// RouteIssueGap is a point that is more than maxPointGap from the next point.
+	RouteIssueGap RouteIssueKind = "gap"
+
+	// RouteIssueCrossing is a segment that crosses a later segment of the route.
+	RouteIssueCrossing RouteIssueKind = "crossing"
+)
+
+// RouteIssue is a problem found in a route's points.
+type RouteIssue struct {
+	Kind RouteIssueKind `json:"kind"`
+
+	// Index is the index of the first point of the problematic segment.
+	Index int `json:"index"`
+
+	// OtherIndex is the index of the first point of the segment that a crossing segment crosses. It
+	// is zero for gaps.
+	OtherIndex int `json:"other_index"`
+
+	// Distance is the length of a gap in meters. It is zero for crossings.
+	Distance float64 `json:"distance"`
+}
+
+// ValidateRoute checks a route's points for consecutive points that are suspiciously far apart and for
+// segments that cross each other. It doesn't change the route. Gaps are listed before crossings, each
+// in order of their points.
+func (u *Updater) ValidateRoute(routeID int64) ([]RouteIssue, error) {
+	route, err := u.ms.Route(routeID)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []RouteIssue{}
+	for i := 0; i+1 < len(route.Points); i++ {
+		distance := spatial.DistanceBetween(route.Points[i], route.Points[i+1])
+		if distance > maxPointGap {
+			issues = append(issues, RouteIssue{Kind: RouteIssueGap, Index: i, Distance: distance})
+		}
+	}
+	for _, crossing := range spatial.Crossings(route.Points) {
+		issues = append(issues, RouteIssue{Kind: RouteIssueCrossing, Index: crossing.First, OtherIndex: crossing.Second})
+	}
+	return issues, nil
+}
@@ -0,0 +1,58 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestValidateRoute(t *testing.T) {
+	typo := &shuttletracker.Route{
+		ID: 1,
+		Points: []shuttletracker.Point{
+			{Latitude: 42.730, Longitude: -73.680},
+			{Latitude: 42.731, Longitude: -73.680},
+			// 42.832 instead of 42.732
+			{Latitude: 42.832, Longitude: -73.680},
+			{Latitude: 42.733, Longitude: -73.680},
+		},
+	}
+	bowtie := &shuttletracker.Route{
+		ID: 2,
+		Points: []shuttletracker.Point{
+			{Latitude: 42.730, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.678},
+			{Latitude: 42.732, Longitude: -73.680},
+			{Latitude: 42.732, Longitude: -73.678},
+			{Latitude: 42.730, Longitude: -73.680},
+		},
+	}
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Route", typo.ID).Return(typo, nil)
+	ms.RouteService.On("Route", bowtie.ID).Return(bowtie, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	issues, err := u.ValidateRoute(typo.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// the mistyped point is far from both of its neighbors
+	if len(issues) != 2 || issues[0].Kind != RouteIssueGap || issues[0].Index != 1 || issues[1].Index != 2 {
+		t.Fatalf("got issues %+v, expected gaps after points 1 and 2", issues)
+	}
+	if issues[0].Distance < 11000 {
+		t.Errorf("got gap of %f m, expected about 11 km", issues[0].Distance)
+	}
+
+	issues, err = u.ValidateRoute(bowtie.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(issues) != 1 || issues[0].Kind != RouteIssueCrossing || issues[0].Index != 1 || issues[0].OtherIndex != 3 {
+		t.Errorf("got issues %+v, expected segments 1 and 3 to cross", issues)
+	}
+}
@@ -0,0 +1,158 @@
+package updater
+
+import (
+	"sort"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+// defaultLocationFreshness is used when Config.LocationFreshness is empty.
+const defaultLocationFreshness = time.Minute * 5
+
+// VehicleWithLocation is a Vehicle along with its latest Location.
+type VehicleWithLocation struct {
+	Vehicle  *shuttletracker.Vehicle  `json:"vehicle"`
+	Location *shuttletracker.Location `json:"location"`
+
+	// Distance is how far in meters the Location is from the point passed to NearestVehicles. It is
+	// zero for results of other methods.
+	Distance float64 `json:"distance"`
+}
+
+// LocationFreshness returns how recently a vehicle must have reported for its latest Location to be
+// treated as its current position.
+func (u *Updater) LocationFreshness() time.Duration {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.locationFreshness
+}
+
+// currentVehicles returns each enabled Vehicle whose latest Location is within the freshness window.
+func (u *Updater) currentVehicles() ([]VehicleWithLocation, error) {
+	u.mutex.Lock()
+	freshness := u.locationFreshness
+	u.mutex.Unlock()
+
+	vehicles, err := u.ms.EnabledVehicles()
+	if err != nil {
+		return nil, err
+	}
+	since := time.Now().Add(-freshness)
+	current := []VehicleWithLocation{}
+	for _, vehicle := range vehicles {
+		location, err := u.ms.LatestLocation(vehicle.ID)
+		if err == shuttletracker.ErrLocationNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if location.Time.Before(since) {
+			continue
+		}
+		current = append(current, VehicleWithLocation{Vehicle: vehicle, Location: location})
+	}
+	return current, nil
+}
+
+type byDistance []VehicleWithLocation
+
+func (v byDistance) Len() int           { return len(v) }
+func (v byDistance) Less(i, j int) bool { return v[i].Distance < v[j].Distance }
+func (v byDistance) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+
+// NearestVehicles returns up to n enabled Vehicles closest to a point along with their latest
+// Locations and distances, nearest first. Vehicles without a Location in the freshness window are
+// excluded.
+func (u *Updater) NearestVehicles(lat, lng float64, n int) ([]VehicleWithLocation, error) {
+	current, err := u.currentVehicles()
+	if err != nil {
+		return nil, err
+	}
+	point := shuttletracker.Point{Latitude: lat, Longitude: lng}
+	for i := range current {
+		location := current[i].Location
+		current[i].Distance = spatial.DistanceBetween(point, shuttletracker.Point{Latitude: location.Latitude, Longitude: location.Longitude})
+	}
+	sort.Stable(byDistance(current))
+	if n < 0 {
+		n = 0
+	}
+	if len(current) > n {
+		current = current[:n]
+	}
+	return current, nil
+}
+
+// VehiclesOnRoute returns the enabled Vehicles whose latest Location is on a route, along with those
+// Locations. Vehicles without a Location in the freshness window are excluded, so one parked on the
+// route hours ago isn't shown as active.
+func (u *Updater) VehiclesOnRoute(routeID int64) ([]VehicleWithLocation, error) {
+	current, err := u.currentVehicles()
+	if err != nil {
+		return nil, err
+	}
+	onRoute := []VehicleWithLocation{}
+	for _, v := range current {
+		if v.Location.RouteID != nil && *v.Location.RouteID == routeID {
+			onRoute = append(onRoute, v)
+		}
+	}
+	return onRoute, nil
+}
+
+// VehiclesByRecentActivity returns every enabled Vehicle along with its latest Location, most recently
+// active first. Vehicles that have never reported a Location are last and have a nil Location.
+func (u *Updater) VehiclesByRecentActivity() ([]VehicleWithLocation, error) {
+	activity, err := u.ms.VehiclesByRecentActivity()
+	if err != nil {
+		return nil, err
+	}
+	active := []VehicleWithLocation{}
+	for _, va := range activity {
+		active = append(active, VehicleWithLocation{Vehicle: va.Vehicle, Location: va.Location})
+	}
+	return active, nil
+}
+
+// NearestStopPerVehicle returns the Stop closest to the latest Location of each enabled Vehicle, keyed
+// by Vehicle ID. Vehicles without a Location in the freshness window are omitted, as are all Vehicles
+// if there are no Stops.
+func (u *Updater) NearestStopPerVehicle() (map[int64]*shuttletracker.Stop, error) {
+	u.mutex.Lock()
+	freshness := u.locationFreshness
+	u.mutex.Unlock()
+
+	vehicles, err := u.ms.EnabledVehicles()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := u.ms.LatestLocations()
+	if err != nil {
+		return nil, err
+	}
+	stops, err := u.ms.Stops()
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-freshness)
+	nearest := map[int64]*shuttletracker.Stop{}
+	for _, vehicle := range vehicles {
+		location, ok := latest[vehicle.ID]
+		if !ok || location.Time.Before(since) {
+			continue
+		}
+		point := shuttletracker.Point{Latitude: location.Latitude, Longitude: location.Longitude}
+		bestDistance := 0.0
+		for _, stop := range stops {
+			distance := spatial.DistanceBetween(point, shuttletracker.Point{Latitude: stop.Latitude, Longitude: stop.Longitude})
+			if nearest[vehicle.ID] == nil || distance < bestDistance {
+				nearest[vehicle.ID] = stop
+				bestDistance = distance
+			}
+		}
+	}
+	return nearest, nil
+}
@@ -0,0 +1,165 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestNearestVehicles(t *testing.T) {
+	now := time.Now()
+	vehicles := []*shuttletracker.Vehicle{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}, {ID: 5}}
+	ms := &mock.ModelService{}
+	ms.VehicleService.On("EnabledVehicles").Return(vehicles, nil)
+	ms.LocationService.On("LatestLocation", int64(1)).Return(&shuttletracker.Location{Latitude: 42.74, Longitude: -73.68, Time: now}, nil)
+	ms.LocationService.On("LatestLocation", int64(2)).Return(&shuttletracker.Location{Latitude: 42.731, Longitude: -73.68, Time: now}, nil)
+	ms.LocationService.On("LatestLocation", int64(3)).Return(&shuttletracker.Location{Latitude: 42.735, Longitude: -73.68, Time: now}, nil)
+	// stale and missing locations are excluded
+	ms.LocationService.On("LatestLocation", int64(4)).Return(&shuttletracker.Location{Latitude: 42.73, Longitude: -73.68, Time: now.Add(-time.Hour)}, nil)
+	ms.LocationService.On("LatestLocation", int64(5)).Return((*shuttletracker.Location)(nil), shuttletracker.ErrLocationNotFound)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	nearest, err := u.NearestVehicles(42.73, -73.68, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(nearest) != 2 || nearest[0].Vehicle.ID != 2 || nearest[1].Vehicle.ID != 3 {
+		t.Fatalf("got %+v, expected vehicles 2 and 3", nearest)
+	}
+	// 0.001 degrees of latitude is about 111 m
+	if nearest[0].Distance < 105 || nearest[0].Distance > 117 {
+		t.Errorf("got distance %f, expected about 111", nearest[0].Distance)
+	}
+
+	all, err := u.NearestVehicles(42.73, -73.68, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("got %d vehicles, expected 3", len(all))
+	}
+}
+
+func TestParseLocationFreshness(t *testing.T) {
+	parsed, err := parseConfig(Config{UpdateInterval: "10s"})
+	if err != nil || parsed.locationFreshness != defaultLocationFreshness {
+		t.Errorf("got %+v and error %v, expected the default freshness", parsed, err)
+	}
+	_, err = parseConfig(Config{UpdateInterval: "10s", LocationFreshness: "-5m"})
+	if err == nil {
+		t.Error("expected an error for a negative freshness window")
+	}
+}
+
+func TestVehiclesOnRoute(t *testing.T) {
+	now := time.Now()
+	routeID := int64(7)
+	otherRouteID := int64(8)
+	vehicles := []*shuttletracker.Vehicle{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	ms := &mock.ModelService{}
+	ms.VehicleService.On("EnabledVehicles").Return(vehicles, nil)
+	ms.LocationService.On("LatestLocation", int64(1)).Return(&shuttletracker.Location{RouteID: &routeID, Time: now}, nil)
+	ms.LocationService.On("LatestLocation", int64(2)).Return(&shuttletracker.Location{RouteID: &otherRouteID, Time: now}, nil)
+	ms.LocationService.On("LatestLocation", int64(3)).Return(&shuttletracker.Location{Time: now}, nil)
+	// a vehicle that stopped reporting on the route a while ago is excluded
+	ms.LocationService.On("LatestLocation", int64(4)).Return(&shuttletracker.Location{RouteID: &routeID, Time: now.Add(-time.Hour)}, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	onRoute, err := u.VehiclesOnRoute(routeID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(onRoute) != 1 || onRoute[0].Vehicle.ID != 1 || onRoute[0].Location == nil {
+		t.Errorf("got %+v, expected only vehicle 1", onRoute)
+	}
+
+	// a longer freshness window includes the older location
+	u.locationFreshness = time.Hour * 2
+	onRoute, err = u.VehiclesOnRoute(routeID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(onRoute) != 2 {
+		t.Errorf("got %d vehicles, expected 2", len(onRoute))
+	}
+}
+
+func TestVehiclesByRecentActivity(t *testing.T) {
+	now := time.Now()
+	activity := []shuttletracker.VehicleActivity{
+		{Vehicle: &shuttletracker.Vehicle{ID: 3}, Location: &shuttletracker.Location{Time: now}},
+		{Vehicle: &shuttletracker.Vehicle{ID: 1}, Location: &shuttletracker.Location{Time: now.Add(-time.Hour)}},
+		{Vehicle: &shuttletracker.Vehicle{ID: 2}},
+	}
+	ms := &mock.ModelService{}
+	ms.VehicleService.On("VehiclesByRecentActivity").Return(activity, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	active, err := u.VehiclesByRecentActivity()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []int64{3, 1, 2}
+	if len(active) != len(expected) {
+		t.Fatalf("got %d vehicles, expected %d", len(active), len(expected))
+	}
+	for i, id := range expected {
+		if active[i].Vehicle.ID != id {
+			t.Errorf("got vehicle %d at position %d, expected %d", active[i].Vehicle.ID, i, id)
+		}
+	}
+	if active[2].Location != nil {
+		t.Errorf("got location %+v for a vehicle that has never reported", active[2].Location)
+	}
+	ms.VehicleService.AssertNumberOfCalls(t, "VehiclesByRecentActivity", 1)
+}
+
+func TestNearestStopPerVehicle(t *testing.T) {
+	now := time.Now()
+	vehicles := []*shuttletracker.Vehicle{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	latest := map[int64]*shuttletracker.Location{
+		1: {Time: now, Latitude: 42.7301, Longitude: -73.68},
+		2: {Time: now, Latitude: 42.7399, Longitude: -73.67},
+		// stale
+		3: {Time: now.Add(-time.Hour), Latitude: 42.73, Longitude: -73.68},
+		// disabled vehicles aren't returned
+		5: {Time: now, Latitude: 42.73, Longitude: -73.68},
+	}
+	stops := []*shuttletracker.Stop{
+		{ID: 1, Latitude: 42.73, Longitude: -73.68},
+		{ID: 2, Latitude: 42.74, Longitude: -73.67},
+	}
+	ms := &mock.ModelService{}
+	ms.VehicleService.On("EnabledVehicles").Return(vehicles, nil)
+	ms.LocationService.On("LatestLocations").Return(latest, nil)
+	ms.StopService.On("Stops").Return(stops, nil)
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	nearest, err := u.NearestStopPerVehicle()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[int64]int64{1: 1, 2: 2}
+	if len(nearest) != len(expected) {
+		t.Fatalf("got %d vehicles, expected %d: %+v", len(nearest), len(expected), nearest)
+	}
+	for vehicleID, stopID := range expected {
+		if stop, ok := nearest[vehicleID]; !ok || stop.ID != stopID {
+			t.Errorf("got stop %+v for vehicle %d, expected stop %d", stop, vehicleID, stopID)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package updater
+
+import (
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/spatial"
+)
+
+const (
+	// wrongWaySamples is the number of consecutive backward moves needed to flag a vehicle, so that
+	// backing up or GPS noise doesn't.
+	wrongWaySamples = 5
+
+	// wrongWayMinMovement is the distance in meters along a route below which a move between two
+	// Locations is treated as stationary and neither continues nor breaks a run of backward moves.
+	wrongWayMinMovement = 3.0
+)
+
+// travelDelta returns how far a vehicle moved along a route between two positions, measured in
+// meters along the route. It is negative if the vehicle moved against the order of the route's
+// points. On loops the shorter way around is assumed, so crossing the start isn't a reversal.
+func travelDelta(from, to, length float64, loop bool) float64 {
+	delta := to - from
+	if loop {
+		if delta > length/2 {
+			delta -= length
+		} else if delta < -length/2 {
+			delta += length
+		}
+	}
+	return delta
+}
+
+// WrongWayVehicles returns the vehicles on a route that are travelling against the route's canonical
+// direction, which is the order of its points. A vehicle is included once its latest several moves
+// along the route have all been backward.
+func (u *Updater) WrongWayVehicles(routeID int64) ([]*shuttletracker.Vehicle, error) {
+	route, err := u.ms.Route(routeID)
+	if err != nil {
+		return nil, err
+	}
+	vehicles, err := u.ms.RecentlyActiveVehicles(headwayActivity)
+	if err != nil {
+		return nil, err
+	}
+	length := spatial.Length(route.Points)
+	loop := spatial.IsLoop(route.Points, loopTolerance)
+
+	wrongWay := []*shuttletracker.Vehicle{}
+	for _, vehicle := range vehicles {
+		latest, err := u.ms.LatestLocation(vehicle.ID)
+		if err == shuttletracker.ErrLocationNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if latest.RouteID == nil || *latest.RouteID != routeID {
+			continue
+		}
+
+		// fetch extra Locations since some may be stationary
+		recent, err := u.ms.RecentLocations(vehicle.ID, wrongWaySamples*2+1)
+		if err != nil {
+			return nil, err
+		}
+		backward := 0
+		// recent is ordered newest first, so walk back in time until a forward move
+		for i := 0; i+1 < len(recent) && backward < wrongWaySamples; i++ {
+			newer := spatial.Project(shuttletracker.Point{Latitude: recent[i].Latitude, Longitude: recent[i].Longitude}, route.Points)
+			older := spatial.Project(shuttletracker.Point{Latitude: recent[i+1].Latitude, Longitude: recent[i+1].Longitude}, route.Points)
+			delta := travelDelta(older.Along, newer.Along, length, loop)
+			if delta >= wrongWayMinMovement {
+				break
+			}
+			if delta <= -wrongWayMinMovement {
+				backward++
+			}
+		}
+		if backward >= wrongWaySamples {
+			wrongWay = append(wrongWay, vehicle)
+		}
+	}
+	return wrongWay, nil
+}
@@ -0,0 +1,69 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/wtg/shuttletracker"
+	"github.com/wtg/shuttletracker/mock"
+)
+
+func TestTravelDelta(t *testing.T) {
+	if d := travelDelta(100, 150, 1000, false); d != 50 {
+		t.Errorf("got %f, expected 50", d)
+	}
+	if d := travelDelta(150, 100, 1000, false); d != -50 {
+		t.Errorf("got %f, expected -50", d)
+	}
+	// crossing the start of a loop is still forward
+	if d := travelDelta(980, 20, 1000, true); d != 40 {
+		t.Errorf("got %f, expected 40", d)
+	}
+	if d := travelDelta(20, 980, 1000, true); d != -40 {
+		t.Errorf("got %f, expected -40", d)
+	}
+}
+
+func TestWrongWayVehicles(t *testing.T) {
+	routeID := int64(1)
+	route := &shuttletracker.Route{
+		ID: routeID,
+		Points: []shuttletracker.Point{
+			{Latitude: 42.730, Longitude: -73.680},
+			{Latitude: 42.730, Longitude: -73.670},
+		},
+	}
+	// each step is about 80 m along the route
+	step := func(i int) *shuttletracker.Location {
+		return &shuttletracker.Location{Latitude: 42.730, Longitude: -73.680 + float64(i)*0.001, RouteID: &routeID}
+	}
+	// newest first, so a vehicle moving forward has decreasing steps
+	forward := []*shuttletracker.Location{}
+	backward := []*shuttletracker.Location{}
+	for i := 0; i < wrongWaySamples*2+1; i++ {
+		forward = append(forward, step(9-i))
+		backward = append(backward, step(i))
+	}
+	// backing up briefly after driving forward
+	reversed := append([]*shuttletracker.Location{}, step(5), step(6), step(5), step(4), step(3), step(2), step(1))
+
+	vehicles := []*shuttletracker.Vehicle{{ID: 1}, {ID: 2}, {ID: 3}}
+	ms := &mock.ModelService{}
+	ms.RouteService.On("Route", routeID).Return(route, nil)
+	ms.VehicleService.On("RecentlyActiveVehicles", headwayActivity).Return(vehicles, nil)
+	for i, recent := range [][]*shuttletracker.Location{forward, backward, reversed} {
+		ms.LocationService.On("LatestLocation", vehicles[i].ID).Return(recent[0], nil)
+		ms.LocationService.On("RecentLocations", vehicles[i].ID, wrongWaySamples*2+1).Return(recent, nil)
+	}
+	u, err := New(Config{UpdateInterval: "10s"}, ms)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wrongWay, err := u.WrongWayVehicles(routeID)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(wrongWay) != 1 || wrongWay[0].ID != 2 {
+		t.Errorf("got %+v, expected only vehicle 2", wrongWay)
+	}
+}
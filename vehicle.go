@@ -2,6 +2,7 @@ package shuttletracker
 
 import (
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -16,6 +17,100 @@ type Vehicle struct {
 	Updated   time.Time `json:"updated"`
 	Enabled   bool      `json:"enabled"`
 	TrackerID string    `json:"tracker_id"`
+
+	// DisplayName is the name shown to riders, while Name is used internally. It may be empty; use
+	// PublicName to fall back to Name.
+	DisplayName string `json:"display_name"`
+
+	// Tags are free-form labels such as "accessible" or "charter". Each tag appears at most once.
+	Tags []string `json:"tags"`
+
+	// TrackerIDs contains every tracker ID that resolves to this Vehicle, including TrackerID.
+	TrackerIDs []string `json:"tracker_ids"`
+
+	// Schedule contains the hours during which this Vehicle is in service. A Vehicle without a
+	// Schedule is always in service.
+	Schedule VehicleSchedule `json:"schedule"`
+}
+
+// PublicName returns the name that riders should see, which is DisplayName unless it is empty.
+func (v *Vehicle) PublicName() string {
+	if v.DisplayName == "" {
+		return v.Name
+	}
+	return v.DisplayName
+}
+
+// VehicleActiveInterval represents a weekly time interval during which a Vehicle is in service.
+// Times are wall clock times formatted as "15:04" in the campus time zone. An interval that ends
+// earlier in the week than it starts wraps around the end of the week.
+type VehicleActiveInterval struct {
+	StartDay  time.Weekday `json:"start_day"`
+	StartTime string       `json:"start_time"`
+	EndDay    time.Weekday `json:"end_day"`
+	EndTime   string       `json:"end_time"`
+}
+
+// VehicleSchedule represents multiple time intervals during which a Vehicle is in service.
+type VehicleSchedule []VehicleActiveInterval
+
+const minutesPerWeek = 7 * 24 * 60
+
+// minuteOfWeek returns how many minutes into the week a weekday and "15:04" time are.
+func minuteOfWeek(day time.Weekday, clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	if day < time.Sunday || day > time.Saturday {
+		return 0, fmt.Errorf("invalid weekday %d", day)
+	}
+	return int(day)*24*60 + t.Hour()*60 + t.Minute(), nil
+}
+
+// Validate returns an error if any interval in the VehicleSchedule has an invalid day or time.
+func (s VehicleSchedule) Validate() error {
+	for _, interval := range s {
+		if _, err := minuteOfWeek(interval.StartDay, interval.StartTime); err != nil {
+			return err
+		}
+		if _, err := minuteOfWeek(interval.EndDay, interval.EndTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ActiveAt returns whether the VehicleSchedule is in service at t. Intervals are interpreted in t's
+// location, so t should be in the campus time zone. An empty VehicleSchedule is always active, and
+// invalid intervals are never active.
+func (s VehicleSchedule) ActiveAt(t time.Time) bool {
+	if len(s) == 0 {
+		return true
+	}
+	now := int(t.Weekday())*24*60 + t.Hour()*60 + t.Minute()
+	for _, interval := range s {
+		start, err := minuteOfWeek(interval.StartDay, interval.StartTime)
+		if err != nil {
+			continue
+		}
+		end, err := minuteOfWeek(interval.EndDay, interval.EndTime)
+		if err != nil {
+			continue
+		}
+		// shift so that the interval starts at zero, which also handles wrapping around the week
+		if (now-start+minutesPerWeek)%minutesPerWeek < (end-start+minutesPerWeek)%minutesPerWeek {
+			return true
+		}
+	}
+	return false
+}
+
+// VehicleActivity is a Vehicle along with its latest Location, which is nil if the Vehicle has never
+// reported one.
+type VehicleActivity struct {
+	Vehicle  *Vehicle
+	Location *Location
 }
 
 // VehicleService is an interface for interacting with Vehicles.
@@ -24,7 +119,13 @@ type VehicleService interface {
 	VehicleWithTrackerID(id string) (*Vehicle, error)
 	Vehicles() ([]*Vehicle, error)
 	EnabledVehicles() ([]*Vehicle, error)
+	RecentlyActiveVehicles(within time.Duration) ([]*Vehicle, error)
+	VehiclesByRecentActivity() ([]VehicleActivity, error)
+	VehiclesModifiedSince(t time.Time) ([]*Vehicle, error)
+	VehiclesWithTag(tag string) ([]*Vehicle, error)
 	CreateVehicle(vehicle *Vehicle) error
 	DeleteVehicle(id int64) error
 	ModifyVehicle(vehicle *Vehicle) error
+	MergeVehicles(keepID, mergeID int64) error
+	RepairVehicleTimestamps() (int64, error)
 }